@@ -0,0 +1,74 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// zipModule writes the top-level files of a generated module into a zip archive at zipPath. Terraform's
+// own working files, such as the .terraform directory, the lock file and state, are left out.
+func zipModule(zipPath, dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return fmt.Errorf("failed to list %s: %w", dir, err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		if !entry.Type().IsRegular() || name == ".terraform.lock.hcl" || strings.HasPrefix(name, "terraform.tfstate") {
+			continue
+		}
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	file, err := os.Create(zipPath)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", zipPath, err)
+	}
+	defer func() { _ = file.Close() }()
+
+	archive := zip.NewWriter(file)
+	for _, name := range names {
+		if err := addZipEntry(archive, filepath.Join(dir, name)); err != nil {
+			return err
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", zipPath, err)
+	}
+	return file.Close()
+}
+
+// addZipEntry copies a file into the archive under its base name, keeping its mode
+func addZipEntry(archive *zip.Writer, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+	header, err := zip.FileInfoHeader(info)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry for %s: %w", path, err)
+	}
+	header.Method = zip.Deflate
+
+	writer, err := archive.CreateHeader(header)
+	if err != nil {
+		return fmt.Errorf("failed to create zip entry for %s: %w", path, err)
+	}
+	source, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	defer func() { _ = source.Close() }()
+	if _, err := io.Copy(writer, source); err != nil {
+		return fmt.Errorf("failed to write zip entry for %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestZipModule(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "main.tf"), []byte("main\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".gitignore"), []byte(".terraform/\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), []byte("lock\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "terraform.tfstate"), []byte("{}\n"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(dir, ".terraform", "providers"), 0755))
+
+	zipPath := filepath.Join(t.TempDir(), "module.zip")
+	require.NoError(t, zipModule(zipPath, dir))
+	assert.Equal(t, map[string]string{".gitignore": ".terraform/\n", "main.tf": "main\n"}, readZip(t, zipPath))
+
+	assert.Error(t, zipModule(zipPath, filepath.Join(dir, "missing")))
+}
+
+func TestRun_Zip(t *testing.T) {
+	moduleDir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", moduleDir)
+	require.Equal(t, 0, exitCode)
+
+	// The archive holds the same validated and formatted files, and the output directory is left untouched
+	outputDir := filepath.Join(t.TempDir(), "unused")
+	zipPath := filepath.Join(t.TempDir(), "module.zip")
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", outputDir, "--zip", zipPath)
+	require.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[info] Writing generated files to zip archive: "+zipPath)
+	assert.NoDirExists(t, outputDir)
+
+	entries := readZip(t, zipPath)
+	for _, name := range []string{"main.tf", "variables.tf", "versions.tf"} {
+		content, err := os.ReadFile(filepath.Join(moduleDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, string(content), entries[name], name)
+	}
+
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "--zip", filepath.Join(t.TempDir(), "missing", "module.zip"))
+	assert.Equal(t, 1, exitCode)
+}
+
+// readZip returns the content of every entry of a zip archive keyed by name
+func readZip(t *testing.T, path string) map[string]string {
+	t.Helper()
+	reader, err := zip.OpenReader(path)
+	require.NoError(t, err)
+	defer func() { _ = reader.Close() }()
+
+	entries := make(map[string]string, len(reader.File))
+	for _, file := range reader.File {
+		source, err := file.Open()
+		require.NoError(t, err)
+		content, err := io.ReadAll(source)
+		require.NoError(t, err)
+		_ = source.Close()
+		entries[file.Name] = string(content)
+	}
+	return entries
+}
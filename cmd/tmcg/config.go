@@ -0,0 +1,165 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+)
+
+// fileConfig is the JSON configuration read with --config
+type fileConfig struct {
+	Providers []string         `json:"providers"`
+	Resources []configResource `json:"resources"`
+	Directory string           `json:"directory"`
+	Binary    string           `json:"binary"`
+}
+
+// configResource is a resource entry of a config file: either a "resource[:mode[:label]]" string like
+// --resource takes, or an object that can also carry per-resource settings
+type configResource struct {
+	Spec      string   `json:"-"`
+	Name      string   `json:"name"`
+	Mode      string   `json:"mode"`
+	Label     string   `json:"label"`
+	KeyAttr   string   `json:"key_attr"`
+	Iteration string   `json:"iteration"`
+	Include   []string `json:"include"`
+	Exclude   []string `json:"exclude"`
+}
+
+// UnmarshalJSON reads a resource entry from either of its forms
+func (r *configResource) UnmarshalJSON(data []byte) error {
+	if err := json.Unmarshal(data, &r.Spec); err == nil {
+		return nil
+	}
+
+	type plain configResource
+	var entry plain
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&entry); err != nil {
+		return err
+	}
+	if entry.Name == "" {
+		return fmt.Errorf("resource entry %s has no name", data)
+	}
+	*r = configResource(entry)
+	return nil
+}
+
+// spec returns the entry as a --resource value
+func (r configResource) spec() string {
+	if r.Spec != "" {
+		return r.Spec
+	}
+	return strings.TrimRight(r.Name+":"+r.Mode+":"+r.Label, ":")
+}
+
+// resourceSpecs returns the resource entries of the config as --resource values
+func (c fileConfig) resourceSpecs() []string {
+	specs := make([]string, 0, len(c.Resources))
+	for _, resource := range c.Resources {
+		specs = append(specs, resource.spec())
+	}
+	return specs
+}
+
+// applyResourceSettings copies the per-resource settings of the config's object entries onto the parsed
+// resources with the same address
+func applyResourceSettings(parser *tmcgParsing.Parser, resources []tmcgParsing.Resource, entries []configResource) ([]tmcgParsing.Resource, error) {
+	for _, entry := range entries {
+		if entry.Spec != "" || (entry.KeyAttr == "" && entry.Iteration == "" && len(entry.Include) == 0 && len(entry.Exclude) == 0) {
+			continue
+		}
+		label := entry.Label
+		if label == "" {
+			label = tmcgParsing.DefaultResourceLabel
+		}
+		for index, resource := range resources {
+			if resource.Name != entry.Name || resource.BlockLabel() != label {
+				continue
+			}
+			var err error
+			if resources[index], err = parser.ParseResourceSettings(resource, entry.KeyAttr, entry.Iteration, entry.Include, entry.Exclude); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return resources, nil
+}
+
+// loadConfigs reads the config files in order: later files override the scalar fields of earlier ones and
+// append to their provider and resource lists, with providers deduplicated by namespace/name
+func loadConfigs(paths []string, mergeVersions bool) (fileConfig, error) {
+	merged := fileConfig{}
+
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return merged, fmt.Errorf("failed to read config file %s: %w", path, err)
+		}
+
+		var config fileConfig
+		decoder := json.NewDecoder(bytes.NewReader(content))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&config); err != nil {
+			return merged, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+
+		merged.Providers, err = mergeProviderSpecs(merged.Providers, config.Providers, mergeVersions)
+		if err != nil {
+			return merged, fmt.Errorf("config file %s: %w", path, err)
+		}
+		merged.Resources = append(merged.Resources, config.Resources...)
+		if config.Directory != "" {
+			merged.Directory = config.Directory
+		}
+		if config.Binary != "" {
+			merged.Binary = config.Binary
+		}
+	}
+
+	return merged, nil
+}
+
+// mergeProviderSpecs appends "namespace/name[:version]" specs to base, folding repeated providers into their
+// first occurrence. Differing versions are an error unless mergeVersions combines them into one constraint.
+func mergeProviderSpecs(base, extra []string, mergeVersions bool) ([]string, error) {
+	merged := append([]string{}, base...)
+
+	for _, spec := range extra {
+		key, version, _ := strings.Cut(strings.TrimSpace(spec), ":")
+		key, version = strings.TrimSpace(key), strings.TrimSpace(version)
+
+		index := -1
+		for i, existing := range merged {
+			existingKey, _, _ := strings.Cut(existing, ":")
+			if strings.EqualFold(strings.TrimSpace(existingKey), key) {
+				index = i
+				break
+			}
+		}
+		if index == -1 {
+			merged = append(merged, spec)
+			continue
+		}
+
+		existingKey, existingVersion, _ := strings.Cut(merged[index], ":")
+		existingVersion = strings.TrimSpace(existingVersion)
+		switch {
+		case version == "" || version == existingVersion:
+		case existingVersion == "":
+			merged[index] = existingKey + ":" + version
+		case mergeVersions:
+			merged[index] = existingKey + ":" + existingVersion + ", " + version
+		default:
+			return nil, fmt.Errorf("conflicting versions for provider %s: '%s' and '%s' (use --merge-versions to combine them)", key, existingVersion, version)
+		}
+	}
+
+	return merged, nil
+}
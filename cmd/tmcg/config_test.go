@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeConfig writes a JSON config file into dir and returns its path
+func writeConfig(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	require.NoError(t, os.WriteFile(path, []byte(content), 0644))
+	return path
+}
+
+func TestLoadConfigs(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfig(t, dir, "base.json", `{"providers": ["hashicorp/aws:>=5.0", "hashicorp/random"], "directory": "base"}`)
+	env := writeConfig(t, dir, "env.json", `{"providers": ["HashiCorp/aws", "hashicorp/random:>=3.0"], "resources": ["aws_instance:multiple"], "directory": "prod"}`)
+
+	config, err := loadConfigs([]string{base, env}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"hashicorp/aws:>=5.0", "hashicorp/random:>=3.0"}, config.Providers)
+	assert.Equal(t, []string{"aws_instance:multiple"}, config.resourceSpecs())
+	assert.Equal(t, "prod", config.Directory)
+
+	conflicting := writeConfig(t, dir, "conflicting.json", `{"providers": ["hashicorp/aws:<6.0"]}`)
+	_, err = loadConfigs([]string{base, conflicting}, false)
+	assert.ErrorContains(t, err, "conflicting versions for provider hashicorp/aws")
+
+	config, err = loadConfigs([]string{base, conflicting}, true)
+	require.NoError(t, err)
+	assert.Equal(t, "hashicorp/aws:>=5.0, <6.0", config.Providers[0])
+
+	entries := writeConfig(t, dir, "entries.json", `{"resources": ["aws_vpc", {"name": "aws_instance", "label": "web", "iteration": "count"}, {"name": "aws_eip", "mode": "multiple"}]}`)
+	config, err = loadConfigs([]string{entries}, false)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws_vpc", "aws_instance::web", "aws_eip:multiple"}, config.resourceSpecs())
+	assert.Equal(t, "count", config.Resources[1].Iteration)
+
+	unnamed := writeConfig(t, dir, "unnamed.json", `{"resources": [{"mode": "single"}]}`)
+	_, err = loadConfigs([]string{unnamed}, false)
+	assert.ErrorContains(t, err, "has no name")
+
+	unknown := writeConfig(t, dir, "unknown.json", `{"resource": ["aws_instance"]}`)
+	_, err = loadConfigs([]string{unknown}, false)
+	assert.ErrorContains(t, err, "unknown field")
+}
+
+func TestRun_MergedConfigs(t *testing.T) {
+	dir := t.TempDir()
+	outputDir := filepath.Join(dir, "module")
+	base := writeConfig(t, dir, "base.json", `{"providers": ["hashicorp/aws:>=5.0"], "directory": "unused"}`)
+	env := writeConfig(t, dir, "env.json", `{"resources": ["aws_instance:single"], "directory": "`+filepath.ToSlash(outputDir)+`"}`)
+
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "--config", base, "--config", env)
+	assert.Equal(t, 0, exitCode)
+
+	content, err := os.ReadFile(filepath.Join(outputDir, "main.tf"))
+	require.NoError(t, err)
+	assert.Regexp(t, `resource\s*"aws_instance"\s*"this"`, string(content))
+
+	versions, err := os.ReadFile(filepath.Join(outputDir, "versions.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(versions), ">=5.0")
+}
+
+func TestRun_MergedConfigVersions(t *testing.T) {
+	dir := t.TempDir()
+	base := writeConfig(t, dir, "base.json", `{"providers": ["hashicorp/aws:>=5.0"], "resources": ["aws_instance:single"]}`)
+	env := writeConfig(t, dir, "env.json", `{"providers": ["hashicorp/aws:<6.0"]}`)
+
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "--config", base, "--config", env, "--merge-versions", "-d", dir)
+	assert.Equal(t, 0, exitCode)
+
+	versions, err := os.ReadFile(filepath.Join(dir, "versions.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(versions), ">=5.0, <6.0")
+}
+
+func TestRun_ConfigResourceSettings(t *testing.T) {
+	schema := testSchema()
+	schema.Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_eip"] = &tfjson.Schema{
+		Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"domain":        {AttributeType: cty.String, Required: true},
+				"network_zone":  {AttributeType: cty.String, Optional: true},
+				"public_ipv4_n": {AttributeType: cty.Number, Required: true},
+				"tags":          {AttributeType: cty.Map(cty.String), Optional: true},
+			},
+		},
+	}
+	dir := t.TempDir()
+	config := writeConfig(t, dir, "config.json", `{
+		"providers": ["hashicorp/aws:>=5.0"],
+		"resources": [
+			{"name": "aws_instance", "mode": "multiple", "iteration": "count", "exclude": ["tags"]},
+			{"name": "aws_eip", "iteration": "for_each", "key_attr": "domain"}
+		]
+	}`)
+
+	exitCode, _ := runWithFakeTerraform(t, schema, "--config", config, "-d", dir)
+	require.Equal(t, 0, exitCode)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	main := string(content)
+	eipStart := strings.Index(main, `resource"aws_eip"`)
+	require.NotEqual(t, -1, eipStart)
+	instance, eip := main[:eipStart], main[eipStart:]
+	assert.Regexp(t, `count\s*=\s*length\(coalesce\(var\.instances, \[\]\)\)`, instance)
+	assert.Regexp(t, `ami\s*=\s*var\.instances\[count\.index\]\.ami`, instance)
+	assert.NotContains(t, instance, "tags")
+	assert.Regexp(t, `for_each\s*=\s*\{ for i in coalesce\(var\.eips, \[\]\) : i\.domain => i \}`, eip)
+	assert.Regexp(t, `tags\s*=\s*each\.value\.tags`, eip)
+
+	variables, err := os.ReadFile(filepath.Join(dir, "variables.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(variables), "# Each object is keyed by its position in the list")
+	assert.Contains(t, string(variables), "# The 'domain' field of each object is used as the for_each key and must be unique")
+
+	// Settings of a resource that cannot take them are rejected
+	invalid := writeConfig(t, dir, "invalid.json", `{"providers": ["hashicorp/aws"], "resources": [{"name": "aws_instance", "mode": "single", "iteration": "count"}]}`)
+	exitCode, mockLogger := runWithFakeTerraform(t, schema, "--config", invalid, "-d", t.TempDir())
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, strings.Join(mockLogger.messages, "\n"), "iteration and key_attr require multiple mode")
+
+	// Key attributes are checked against the schema once it is known
+	for keyAttr, message := range map[string]string{
+		"missing":       "key_attr missing of resource aws_eip is not a settable attribute of its schema, or is excluded",
+		"tags":          "key_attr tags of resource aws_eip is not a settable attribute of its schema, or is excluded",
+		"public_ipv4_n": "key_attr public_ipv4_n of resource aws_eip must be a string attribute",
+		"network_zone":  "key_attr network_zone of resource aws_eip is optional, so keys may be null; use a required attribute or --key-mode coalesce",
+	} {
+		invalid = writeConfig(t, dir, "invalid.json", fmt.Sprintf(`{"providers": ["hashicorp/aws"], "resources": [{"name": "aws_eip", "key_attr": %q, "exclude": ["tags"]}]}`, keyAttr))
+		exitCode, mockLogger = runWithFakeTerraform(t, schema, "--config", invalid, "-d", t.TempDir())
+		assert.Equal(t, 1, exitCode, keyAttr)
+		assert.Contains(t, mockLogger.messages, "[error] Invalid resource settings: "+message)
+	}
+
+	// A per-key provider needs each.key, which count iteration does not have
+	invalid = writeConfig(t, dir, "invalid.json", `{"providers": ["hashicorp/aws"], "resources": [{"name": "aws_eip", "iteration": "count"}]}`)
+	exitCode, mockLogger = runWithFakeTerraform(t, schema, "--config", invalid, "-d", t.TempDir(), "--binary", "tofu", "--provider-alias", "aws.by_region", "--per-key-provider", "aws_eip=aws.by_region")
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] Invalid generation options: resource aws_eip iterates with count, which has no each.key for --per-key-provider")
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// exitCodeDrift is returned when --diff-source or --golden finds differences from the expected module
+const exitCodeDrift = 2
+
+// runGit runs git with the given arguments and is replaced in tests
+var runGit = func(args ...string) error {
+	output, err := exec.Command("git", args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("git %s: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(string(output)))
+	}
+	return nil
+}
+
+// fetchModuleSource resolves a local path or git source to a directory, returning a cleanup function
+func fetchModuleSource(source string) (string, func(), error) {
+	noop := func() {}
+
+	// Local paths, with or without a file:// scheme
+	if !strings.HasPrefix(source, "git::") && !strings.HasSuffix(strings.SplitN(source, "?", 2)[0], ".git") {
+		path := strings.TrimPrefix(source, "file://")
+		info, err := os.Stat(path)
+		if err != nil {
+			return "", noop, fmt.Errorf("unsupported or missing module source %s: %w", source, err)
+		}
+		if !info.IsDir() {
+			return "", noop, fmt.Errorf("module source %s is not a directory", source)
+		}
+		return path, noop, nil
+	}
+
+	// Git sources follow the go-getter form: git::<url>[//subdir][?ref=<ref>]
+	repoURL := strings.TrimPrefix(source, "git::")
+	ref := ""
+	if base, query, found := strings.Cut(repoURL, "?"); found {
+		values, err := url.ParseQuery(query)
+		if err != nil {
+			return "", noop, fmt.Errorf("invalid query in module source %s: %w", source, err)
+		}
+		repoURL, ref = base, values.Get("ref")
+	}
+	subdir := ""
+	if schemeEnd := strings.Index(repoURL, "://"); schemeEnd >= 0 {
+		if idx := strings.Index(repoURL[schemeEnd+3:], "//"); idx >= 0 {
+			subdir = repoURL[schemeEnd+3+idx+2:]
+			repoURL = repoURL[:schemeEnd+3+idx]
+		}
+	}
+
+	tempDir, err := os.MkdirTemp("", "tmcg-diff-source-")
+	if err != nil {
+		return "", noop, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() { _ = os.RemoveAll(tempDir) }
+
+	// A full clone followed by a checkout works for commit SHAs as well as branches and tags
+	if err := runGit("clone", "--quiet", repoURL, tempDir); err != nil {
+		cleanup()
+		return "", noop, fmt.Errorf("failed to fetch module source %s: %w", source, err)
+	}
+	if ref != "" {
+		if err := runGit("-C", tempDir, "checkout", "--quiet", ref); err != nil {
+			cleanup()
+			return "", noop, fmt.Errorf("failed to check out %s of module source %s: %w", ref, source, err)
+		}
+	}
+	return filepath.Join(tempDir, subdir), cleanup, nil
+}
+
+// diffModule writes a line diff between the .tf files of a source module, labeled sourceLabel, and the
+// generated module and reports whether any differences were found
+func diffModule(output io.Writer, sourceLabel, sourceDir, generatedDir string) (bool, error) {
+	sourceFiles, err := readTFFiles(sourceDir)
+	if err != nil {
+		return false, err
+	}
+	generatedFiles, err := readTFFiles(generatedDir)
+	if err != nil {
+		return false, err
+	}
+
+	names := make([]string, 0, len(sourceFiles)+len(generatedFiles))
+	for name := range sourceFiles {
+		names = append(names, name)
+	}
+	for name := range generatedFiles {
+		if _, exists := sourceFiles[name]; !exists {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	drift := false
+	for _, name := range names {
+		sourceContent, inSource := sourceFiles[name]
+		generatedContent, inGenerated := generatedFiles[name]
+		if inSource && inGenerated && sourceContent == generatedContent {
+			continue
+		}
+
+		drift = true
+		_, _ = fmt.Fprintf(output, "--- %s/%s\n+++ generated/%s\n", sourceLabel, name, name)
+		for _, line := range diffLines(splitLines(sourceContent), splitLines(generatedContent)) {
+			_, _ = fmt.Fprintln(output, line)
+		}
+	}
+	return drift, nil
+}
+
+// readTFFiles reads the top-level .tf files of a directory keyed by file name
+func readTFFiles(dir string) (map[string]string, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*.tf"))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list .tf files in %s: %w", dir, err)
+	}
+
+	files := make(map[string]string, len(paths))
+	for _, path := range paths {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		files[filepath.Base(path)] = string(content)
+	}
+	return files, nil
+}
+
+// splitLines splits content into lines, ignoring a trailing newline
+func splitLines(content string) []string {
+	if content == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(content, "\n"), "\n")
+}
+
+// diffLines returns the removed ("-") and added ("+") lines needed to turn a into b
+func diffLines(a, b []string) []string {
+	// Trim the common prefix and suffix to keep the table small
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+	a, b = a[prefix:len(a)-suffix], b[prefix:len(b)-suffix]
+
+	// Longest common subsequence table
+	lcs := make([][]int, len(a)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if a[i] == b[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var lines []string
+	i, j := 0, 0
+	for i < len(a) || j < len(b) {
+		switch {
+		case i < len(a) && j < len(b) && a[i] == b[j]:
+			i, j = i+1, j+1
+		case i < len(a) && (j == len(b) || lcs[i+1][j] >= lcs[i][j+1]):
+			lines = append(lines, "-"+a[i])
+			i++
+		default:
+			lines = append(lines, "+"+b[j])
+			j++
+		}
+	}
+	return lines
+}
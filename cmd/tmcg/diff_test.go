@@ -0,0 +1,117 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiffModule(t *testing.T) {
+	sourceDir, generatedDir := t.TempDir(), t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "main.tf"), []byte("a\nb\nc\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(generatedDir, "main.tf"), []byte("a\nB\nc\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "versions.tf"), []byte("same\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(generatedDir, "versions.tf"), []byte("same\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(generatedDir, "variables.tf"), []byte("new\n"), 0644))
+
+	var output bytes.Buffer
+	drift, err := diffModule(&output, "source", sourceDir, generatedDir)
+	require.NoError(t, err)
+	assert.True(t, drift)
+	assert.Equal(t, "--- source/main.tf\n+++ generated/main.tf\n-b\n+B\n--- source/variables.tf\n+++ generated/variables.tf\n+new\n", output.String())
+}
+
+func TestRun_DiffSource(t *testing.T) {
+	sourceDir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", sourceDir)
+	require.Equal(t, 0, exitCode)
+
+	// Freshly generated output matches the published module
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", t.TempDir(), "--diff-source", sourceDir)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[info] Generated files match module source: "+sourceDir)
+
+	// Comparing against the output directory itself reports the drift instead of overwriting it
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "main.tf"), []byte("# hand edit\n"), 0644))
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", sourceDir, "--diff-source", sourceDir)
+	assert.Equal(t, exitCodeDrift, exitCode)
+	content, err := os.ReadFile(filepath.Join(sourceDir, "main.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, "# hand edit\n", string(content))
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", sourceDir)
+	require.Equal(t, 0, exitCode)
+
+	// A hand edit in the published module is reported as drift
+	require.NoError(t, os.WriteFile(filepath.Join(sourceDir, "variables.tf"), []byte("# stale\n"), 0644))
+	exitCode, mockLogger = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", t.TempDir(), "--diff-source", "file://"+sourceDir)
+	assert.Equal(t, exitCodeDrift, exitCode)
+	assert.Contains(t, mockLogger.messages, "[warn] Generated files differ from module source: file://"+sourceDir)
+
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", t.TempDir(), "--diff-source", filepath.Join(sourceDir, "missing"))
+	assert.Equal(t, 1, exitCode)
+}
+
+func TestRun_Golden(t *testing.T) {
+	goldenDir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", goldenDir)
+	require.Equal(t, 0, exitCode)
+
+	// The output directory is left untouched while checking against the goldens
+	outputDir := filepath.Join(t.TempDir(), "unused")
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", outputDir, "--golden", goldenDir)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[info] Generated files match golden directory: "+goldenDir)
+	assert.NoDirExists(t, outputDir)
+
+	require.NoError(t, os.WriteFile(filepath.Join(goldenDir, "main.tf"), []byte("# stale\n"), 0644))
+	exitCode, mockLogger, output := runWithTerraform(t, &fakeTerraform{schema: testSchema()}, "-p", "hashicorp/aws", "-r", "aws_instance:single", "--golden", goldenDir)
+	assert.Equal(t, exitCodeDrift, exitCode)
+	assert.Contains(t, mockLogger.messages, "[warn] Generated files differ from golden directory: "+goldenDir)
+	assert.Contains(t, output, "--- golden/main.tf\n+++ generated/main.tf\n-# stale\n")
+
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "--golden", filepath.Join(goldenDir, "missing"))
+	assert.Equal(t, 1, exitCode)
+}
+
+func TestFetchModuleSourceGit(t *testing.T) {
+	originalRunGit := runGit
+	defer func() { runGit = originalRunGit }()
+
+	var calls [][]string
+	runGit = func(args ...string) error {
+		calls = append(calls, args)
+		if args[0] == "clone" {
+			return os.MkdirAll(filepath.Join(args[len(args)-1], "modules", "ec2"), 0755)
+		}
+		return nil
+	}
+
+	// Commit SHAs cannot be cloned with --branch, so the ref is checked out after cloning
+	dir, cleanup, err := fetchModuleSource("git::https://example.com/org/modules.git//modules/ec2?ref=3f4e5d6")
+	require.NoError(t, err)
+	defer cleanup()
+
+	require.Len(t, calls, 2)
+	cloneDir := calls[0][len(calls[0])-1]
+	assert.Equal(t, []string{"clone", "--quiet", "https://example.com/org/modules.git", cloneDir}, calls[0])
+	assert.Equal(t, []string{"-C", cloneDir, "checkout", "--quiet", "3f4e5d6"}, calls[1])
+	assert.Equal(t, filepath.Join(cloneDir, "modules", "ec2"), dir)
+	assert.DirExists(t, dir)
+
+	// A failed checkout removes the clone
+	runGit = func(args ...string) error {
+		if args[0] == "clone" {
+			cloneDir = args[len(args)-1]
+			return os.MkdirAll(cloneDir, 0755)
+		}
+		return errors.New("unknown revision")
+	}
+	_, _, err = fetchModuleSource("git::https://example.com/org/modules.git?ref=missing")
+	assert.ErrorContains(t, err, "failed to check out missing")
+	assert.NoDirExists(t, cloneDir)
+}
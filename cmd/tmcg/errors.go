@@ -0,0 +1,67 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"tmcg/internal/tmcg/logging"
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+)
+
+// errorReport is the machine-readable failure written to stderr with --errors-json
+type errorReport struct {
+	Step    string   `json:"step"`
+	Kind    string   `json:"kind,omitempty"`
+	Error   string   `json:"error"`
+	Details []string `json:"details"`
+}
+
+// jsonErrorLogger collects error messages for the JSON error report and forwards every other level
+type jsonErrorLogger struct {
+	next   logging.Logger
+	step   string
+	kind   string
+	errors []string
+}
+
+// Log records error messages and passes other levels to the wrapped logger
+func (l *jsonErrorLogger) Log(level string, format string, args ...interface{}) {
+	if level != "error" {
+		l.next.Log(level, format, args...)
+		return
+	}
+	l.errors = append(l.errors, fmt.Sprintf(format, args...))
+}
+
+// writeReport writes the step, the first error and any further error messages as one JSON object
+func (l *jsonErrorLogger) writeReport(output io.Writer) error {
+	report := errorReport{Step: l.step, Kind: l.kind, Details: []string{}}
+	if len(l.errors) > 0 {
+		report.Error = l.errors[0]
+		report.Details = append(report.Details, l.errors[1:]...)
+	}
+
+	data, err := json.Marshal(report)
+	if err != nil {
+		return fmt.Errorf("failed to marshal error report: %w", err)
+	}
+	_, err = fmt.Fprintln(output, string(data))
+	return err
+}
+
+// setStep records the pipeline step reported by --errors-json when the logger collects errors
+func setStep(logger logging.Logger, step string) {
+	if errorLogger, ok := logger.(*jsonErrorLogger); ok {
+		errorLogger.step = step
+	}
+}
+
+// setErrorKind records the kind of a parsing failure reported by --errors-json, such as "duplicate provider"
+func setErrorKind(logger logging.Logger, err error) {
+	var parseErr *tmcgParsing.ParseError
+	if errorLogger, ok := logger.(*jsonErrorLogger); ok && errors.As(err, &parseErr) {
+		errorLogger.kind = parseErr.Kind.Error()
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os/exec"
+	"strings"
+)
+
+// splitCommand splits a --post-hook command into arguments on whitespace, keeping single- or
+// double-quoted text together. No shell is involved, so pipes, globs and variables are not expanded.
+func splitCommand(command string) ([]string, error) {
+	args := []string{}
+	var current strings.Builder
+	inArg := false
+	var quote rune
+
+	for _, char := range command {
+		switch {
+		case quote != 0 && char == quote:
+			quote = 0
+		case quote != 0:
+			current.WriteRune(char)
+		case char == '\'' || char == '"':
+			quote, inArg = char, true
+		case char == ' ' || char == '\t' || char == '\n':
+			if inArg {
+				args = append(args, current.String())
+				current.Reset()
+				inArg = false
+			}
+		default:
+			current.WriteRune(char)
+			inArg = true
+		}
+	}
+
+	if quote != 0 {
+		return nil, fmt.Errorf("unterminated %c quote in command: %s", quote, command)
+	}
+	if inArg {
+		args = append(args, current.String())
+	}
+	if len(args) == 0 {
+		return nil, fmt.Errorf("empty command")
+	}
+	return args, nil
+}
+
+// runPostHook runs a command in dir, streaming its output, and fails if it exits non-zero
+func runPostHook(command, dir string, stdout, stderr io.Writer) error {
+	args, err := splitCommand(command)
+	if err != nil {
+		return err
+	}
+
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Dir = dir
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("post-hook '%s' failed: %w", command, err)
+	}
+	return nil
+}
@@ -0,0 +1,43 @@
+package main
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSplitCommand(t *testing.T) {
+	args, err := splitCommand(`terraform-docs markdown table --output-file "README generated.md" .`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"terraform-docs", "markdown", "table", "--output-file", "README generated.md", "."}, args)
+
+	args, err = splitCommand(`sh -c 'git add "$0"' ''`)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"sh", "-c", `git add "$0"`, ""}, args)
+
+	_, err = splitCommand(`echo "unterminated`)
+	assert.ErrorContains(t, err, "unterminated \" quote")
+
+	_, err = splitCommand("   ")
+	assert.ErrorContains(t, err, "empty command")
+}
+
+func TestRun_PostHook(t *testing.T) {
+	dir := t.TempDir()
+	exitCode, _, output := runWithFakeTerraformOutput(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", dir, "--post-hook", "ls")
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, output, "main.tf\n")
+
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", dir, "--post-hook", "true")
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[info] Running post-hook in "+dir+": true")
+
+	exitCode, mockLogger = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", dir, "--post-hook", "false")
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] Error running post-hook: post-hook 'false' failed: exit status 1")
+
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", dir, "--post-hook", filepath.Join(dir, "missing"))
+	assert.Equal(t, 1, exitCode)
+}
@@ -0,0 +1,207 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"slices"
+	"sort"
+	"strconv"
+	"strings"
+
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// selector asks the user to pick a subset of options, starting from the preselected ones
+type selector interface {
+	Select(title string, options []string, preselected []string) ([]string, error)
+}
+
+// newSelector creates the selector used by --interactive, replaced in tests
+var newSelector = func(input io.Reader, output io.Writer) selector {
+	return &lineSelector{input: bufio.NewReader(input), output: output}
+}
+
+// promptInput supplies the answers to --interactive prompts
+var promptInput io.Reader = os.Stdin
+
+// lineSelector prints numbered options and reads the selection as a line of numbers and ranges
+type lineSelector struct {
+	input  *bufio.Reader
+	output io.Writer
+}
+
+// Select prompts until the answer is a valid selection
+func (s *lineSelector) Select(title string, options []string, preselected []string) ([]string, error) {
+	_, _ = fmt.Fprintf(s.output, "%s\n", title)
+	for i, option := range options {
+		mark := " "
+		if slices.Contains(preselected, option) {
+			mark = "x"
+		}
+		_, _ = fmt.Fprintf(s.output, "  [%s] %d) %s\n", mark, i+1, option)
+	}
+
+	for {
+		_, _ = fmt.Fprint(s.output, "Select numbers or ranges (e.g., 1,3-5), 'all' or 'none'; press Enter to keep the marked items: ")
+		line, err := s.input.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			return nil, fmt.Errorf("failed to read selection: %w", err)
+		}
+		selected, parseErr := parseSelection(line, options, preselected)
+		if parseErr == nil {
+			return selected, nil
+		}
+		_, _ = fmt.Fprintf(s.output, "%v\n", parseErr)
+		if err == io.EOF {
+			return nil, parseErr
+		}
+	}
+}
+
+// parseSelection translates an answer such as "1,3-5", "all", "none" or an empty line (keep preselected)
+// into the chosen options, in the order they were offered
+func parseSelection(answer string, options []string, preselected []string) ([]string, error) {
+	answer = strings.TrimSpace(answer)
+	switch strings.ToLower(answer) {
+	case "":
+		return slices.DeleteFunc(slices.Clone(options), func(option string) bool { return !slices.Contains(preselected, option) }), nil
+	case "all":
+		return slices.Clone(options), nil
+	case "none":
+		return []string{}, nil
+	}
+
+	chosen := make([]bool, len(options))
+	for _, part := range strings.Split(answer, ",") {
+		first, last, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, err := strconv.Atoi(strings.TrimSpace(first))
+		end := start
+		if err == nil && isRange {
+			end, err = strconv.Atoi(strings.TrimSpace(last))
+		}
+		if err != nil || start < 1 || end > len(options) || start > end {
+			return nil, fmt.Errorf("invalid selection: '%s'. Use numbers between 1 and %d", strings.TrimSpace(part), len(options))
+		}
+		for i := start; i <= end; i++ {
+			chosen[i-1] = true
+		}
+	}
+
+	selected := []string{}
+	for i, option := range options {
+		if chosen[i] {
+			selected = append(selected, option)
+		}
+	}
+	return selected, nil
+}
+
+// selectResources offers the resource types of the declared providers, preselecting the --resource flags,
+// and returns the resource specs to parse: the original spec for flagged types and the default mode otherwise
+func selectResources(sel selector, schemas *tfjson.ProviderSchemas, providers map[string]tmcgParsing.Provider, resourceSpecs []string) ([]string, error) {
+	options := []string{}
+	for key := range providers {
+		providerSchema, exists := schemas.Schemas[providers[key].Address(registryHost)]
+		if !exists || providerSchema == nil {
+			continue
+		}
+		for name := range providerSchema.ResourceSchemas {
+			options = append(options, name)
+		}
+	}
+	sort.Strings(options)
+
+	preselected := make([]string, 0, len(resourceSpecs))
+	for _, spec := range resourceSpecs {
+		preselected = append(preselected, specResourceName(spec))
+	}
+
+	selected, err := sel.Select("Resources to generate:", options, preselected)
+	if err != nil {
+		return nil, err
+	}
+
+	specs := []string{}
+	for _, name := range selected {
+		flagged := false
+		for _, spec := range resourceSpecs {
+			if specResourceName(spec) == name {
+				specs = append(specs, spec)
+				flagged = true
+			}
+		}
+		if !flagged {
+			specs = append(specs, name)
+		}
+	}
+	return specs, nil
+}
+
+// specResourceName returns the resource type of a --resource spec, past any provider qualifier
+func specResourceName(spec string) string {
+	if qualifier, rest, qualified := strings.Cut(spec, "::"); qualified && strings.Contains(qualifier, "/") {
+		spec = rest
+	}
+	name, _, _ := strings.Cut(spec, ":")
+	return name
+}
+
+// selectAttributes offers the optional top-level attributes and blocks of each resource, all preselected,
+// and removes the ones left out. Required attributes and blocks are always kept.
+func selectAttributes(sel selector, schemas map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) error {
+	seen := make(map[string]bool)
+	for _, resource := range resources {
+		providerSchema, exists := schemas[resource.Provider.Address(registryHost)]
+		if !exists || providerSchema == nil || seen[resource.Name] {
+			continue
+		}
+		resourceSchema, exists := providerSchema.ResourceSchemas[resource.Name]
+		if !exists || resourceSchema == nil || resourceSchema.Block == nil {
+			continue
+		}
+		seen[resource.Name] = true
+
+		options := optionalItems(resourceSchema.Block)
+		if len(options) == 0 {
+			continue
+		}
+		selected, err := sel.Select(fmt.Sprintf("Optional attributes and blocks of %s:", resource.Name), options, options)
+		if err != nil {
+			return err
+		}
+		excludeItems(resourceSchema.Block, options, selected)
+	}
+	return nil
+}
+
+// optionalItems returns the sorted names of the optional attributes and nested blocks of a block
+func optionalItems(block *tfjson.SchemaBlock) []string {
+	items := []string{}
+	for name, attr := range block.Attributes {
+		if attr != nil && !attr.Required {
+			items = append(items, name)
+		}
+	}
+	for name, nested := range block.NestedBlocks {
+		if nested != nil && nested.MinItems == 0 {
+			items = append(items, name)
+		}
+	}
+	sort.Strings(items)
+	return items
+}
+
+// excludeItems removes the offered attributes and nested blocks that were not selected
+func excludeItems(block *tfjson.SchemaBlock, offered []string, selected []string) {
+	for _, name := range offered {
+		if slices.Contains(selected, name) {
+			continue
+		}
+		delete(block.Attributes, name)
+		delete(block.NestedBlocks, name)
+	}
+}
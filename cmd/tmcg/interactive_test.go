@@ -0,0 +1,124 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// scriptedSelector answers each prompt with the next scripted selection and records the offered options
+type scriptedSelector struct {
+	answers [][]string
+	offered [][]string
+}
+
+func (s *scriptedSelector) Select(title string, options []string, preselected []string) ([]string, error) {
+	s.offered = append(s.offered, options)
+	answer := s.answers[0]
+	s.answers = s.answers[1:]
+	return answer, nil
+}
+
+func TestParseSelection(t *testing.T) {
+	options := []string{"a", "b", "c", "d", "e"}
+	tests := []struct {
+		answer   string
+		expected []string
+		errorMsg string
+	}{
+		{"", []string{"b", "d"}, ""},
+		{"all", options, ""},
+		{" NONE ", []string{}, ""},
+		{"1, 3-4", []string{"a", "c", "d"}, ""},
+		{"5,1", []string{"a", "e"}, ""},
+		{"0", nil, "invalid selection: '0'"},
+		{"2-6", nil, "invalid selection: '2-6'"},
+		{"x", nil, "invalid selection: 'x'"},
+	}
+
+	for _, test := range tests {
+		selected, err := parseSelection(test.answer, options, []string{"d", "b"})
+		if test.errorMsg != "" {
+			assert.ErrorContains(t, err, test.errorMsg, test.answer)
+			continue
+		}
+		assert.NoError(t, err, test.answer)
+		assert.Equal(t, test.expected, selected, test.answer)
+	}
+}
+
+func TestLineSelector(t *testing.T) {
+	var output bytes.Buffer
+	sel := newSelector(strings.NewReader("9\n2\n"), &output)
+
+	selected, err := sel.Select("Resources to generate:", []string{"aws_instance", "aws_vpc"}, []string{"aws_instance"})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"aws_vpc"}, selected)
+	assert.Contains(t, output.String(), "  [x] 1) aws_instance\n  [ ] 2) aws_vpc\n")
+	assert.Contains(t, output.String(), "invalid selection: '9'")
+
+	_, err = newSelector(strings.NewReader(""), io.Discard).Select("Resources to generate:", []string{"aws_vpc"}, nil)
+	assert.Error(t, err)
+}
+
+func TestSelectAttributes(t *testing.T) {
+	schemas := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"ami":           {AttributeType: cty.String, Required: true},
+						"instance_type": {AttributeType: cty.String, Optional: true},
+						"tags":          {AttributeType: cty.Map(cty.String), Optional: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"network_interface": {NestingMode: tfjson.SchemaNestingModeList, MinItems: 1, Block: &tfjson.SchemaBlock{}},
+						"timeouts":          {NestingMode: tfjson.SchemaNestingModeSingle, Block: &tfjson.SchemaBlock{}},
+					},
+				}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Provider: tmcgParsing.Provider{NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+
+	sel := &scriptedSelector{answers: [][]string{{"tags"}}}
+	require.NoError(t, selectAttributes(sel, schemas, resources))
+	assert.Equal(t, [][]string{{"instance_type", "tags", "timeouts"}}, sel.offered)
+
+	block := schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"].Block
+	assert.Contains(t, block.Attributes, "ami")
+	assert.Contains(t, block.Attributes, "tags")
+	assert.NotContains(t, block.Attributes, "instance_type")
+	assert.Contains(t, block.NestedBlocks, "network_interface")
+	assert.NotContains(t, block.NestedBlocks, "timeouts")
+}
+
+func TestRun_Interactive(t *testing.T) {
+	originalNewSelector := newSelector
+	defer func() { newSelector = originalNewSelector }()
+	sel := &scriptedSelector{answers: [][]string{{"aws_instance"}, {}}}
+	newSelector = func(io.Reader, io.Writer) selector { return sel }
+
+	dir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws:>=5.0", "-d", dir, "--interactive")
+	assert.Equal(t, 0, exitCode)
+	assert.Equal(t, [][]string{{"aws_instance"}, {"tags"}}, sel.offered)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	assert.Regexp(t, `ami\s*=\s*each\.value\.ami`, string(content))
+	assert.NotContains(t, string(content), "tags")
+}
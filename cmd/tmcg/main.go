@@ -4,11 +4,14 @@ package main
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
 	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
+	"runtime/debug"
 	"strings"
 	"time"
 
@@ -17,10 +20,52 @@ import (
 	tmcgSchema "tmcg/internal/tmcg/schema"
 	tmcgTerraform "tmcg/internal/tmcg/terraform"
 
+	goversion "github.com/hashicorp/go-version"
 	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/spf13/pflag"
 )
 
+// terraformRunner is the subset of tfexec.Terraform used by Run
+type terraformRunner interface {
+	Init(ctx context.Context, opts ...tfexec.InitOption) error
+	ProvidersSchema(ctx context.Context) (*tfjson.ProviderSchemas, error)
+	Validate(ctx context.Context) (*tfjson.ValidateOutput, error)
+	FormatWrite(ctx context.Context, opts ...tfexec.FormatOption) error
+	Version(ctx context.Context, skipCache bool) (*goversion.Version, map[string]*goversion.Version, error)
+	WorkingDir() string
+	SetEnv(env map[string]string) error
+}
+
+// isOpenTofu reports whether a binary path names OpenTofu's tofu executable rather than Terraform
+func isOpenTofu(binary string) bool {
+	return strings.HasPrefix(filepath.Base(binary), "tofu")
+}
+
+// newTerraform creates the Terraform runner, overridable in tests
+var newTerraform = func(workingDir, execPath string) (terraformRunner, error) {
+	return tfexec.NewTerraform(workingDir, execPath)
+}
+
+// cliConfigEnv returns the current environment with TF_CLI_CONFIG_FILE set to the given path,
+// leaving out the variables terraform-exec manages itself
+func cliConfigEnv(configPath string) map[string]string {
+	env := make(map[string]string)
+	for _, entry := range os.Environ() {
+		if key, value, found := strings.Cut(entry, "="); found {
+			env[key] = value
+		}
+	}
+	for _, key := range tfexec.ProhibitedEnv(env) {
+		delete(env, key)
+	}
+	env["TF_CLI_CONFIG_FILE"] = configPath
+	return env
+}
+
+// lookPath resolves the Terraform binary, overridable in tests
+var lookPath = exec.LookPath
+
 // Custom flag to handle a slice of strings for resources and providers
 type stringSliceFlag []string
 
@@ -43,18 +88,106 @@ func (f *stringSliceFlag) Type() string {
 var (
 	resourcePtrs       stringSliceFlag
 	providerPtrs       stringSliceFlag
+	providerAliasPtrs  stringSliceFlag
+	typeOverridePtrs   stringSliceFlag
+	forEachMapPtrs     stringSliceFlag
+	resourceProvPtrs   stringSliceFlag
+	perKeyProvPtrs     stringSliceFlag
+	configPtrs         stringSliceFlag
+	configResources    []configResource
+	preconditionPtrs   stringSliceFlag
+	mergeVersions      bool
+	devOverridePtrs    stringSliceFlag
+	extraHCLPtrs       stringSliceFlag
+	toggleablePtrs     stringSliceFlag
+	resourceAliasPtrs  stringSliceFlag
+	backendConfigPtrs  stringSliceFlag
+	errorsJSON         bool
+	computedOutputs    bool
+	keepID             bool
 	workingDir         string
 	binaryPath         string
 	logLevel           string
+	jsonSummaryPath    string
+	defaultProviderVer string
+	multilineDesc      bool
+	providerBlocksFlag bool
+	groupByProvider    bool
+	lockFilePath       string
+	defaultsFromPath   string
+	onlyFile           string
+	emitGitignore      bool
+	emitMakefile       bool
+	schemaStdin        bool
+	interactive        bool
+	descriptionsPath   string
+	blockDescComments  bool
+	parallelResources  int
+	concurrency        int
+	diffSource         string
+	goldenDir          string
+	zipPath            string
+	backendName        string
+	backendFile        string
+	pruneProviders     bool
+	heredocThreshold   int
+	appendMode         bool
+	keyMode            string
+	optionalBlockDef   string
+	postHook           string
+	nonNullable        bool
+	explain            bool
+	registryHost       string
+	fileModeFlag       string
+	fromStatePath      string
+	pinResolved        bool
+	requiredMultiple   bool
+	outputID           bool
+	emptyCollections   bool
+	nullDefaultTypes   string
+	previewSchema      bool
+	emitSchemaPath     string
+	simplePluralFlag   bool
+	sectionHeaders     bool
+	globalVarSort      bool
+	attributeCase      string
+	autoTags           bool
+	tagAttributes      string
+	singleRefStyle     string
+	toggleOnNull       bool
+	keyVar             bool
+	shortIterators     bool
+	noCoalesce         bool
+	defaultsLocal      bool
+	requiredFirst      bool
+	trimProviderPrefix bool
 	helpFlag           bool
 	versionFlag        bool
 	descAsCommentsFlag bool
 )
 
+// outputWriter receives command output such as the --diff-source report and --preview-schema JSON
+var outputWriter io.Writer = os.Stdout
+
+// hookErrorWriter receives the error output of the --post-hook command
+var hookErrorWriter io.Writer = os.Stderr
+
+// explainWriter receives the --explain trace of the schema passes
+var explainWriter io.Writer = os.Stderr
+
+// schemaInput supplies the provider schema JSON read with --schema-stdin
+var schemaInput io.Reader = os.Stdin
+
+// exitCodeNoResources is returned when the run succeeds but generates no resource blocks
+const exitCodeNoResources = 3
+
 var (
 	version   = "dev"
 	commit    = "none"
 	buildDate = "unknown"
+
+	// readBuildInfo reads the module and VCS information embedded by the Go toolchain
+	readBuildInfo = debug.ReadBuildInfo
 )
 
 func main() {
@@ -65,10 +198,19 @@ func main() {
 	}
 	logger := logging.GetGlobalLogger()
 
-	Setup(os.Args, os.Stdout, os.Stderr, os.Exit, logger)
+	Setup(os.Args[1:], os.Stdout, os.Stderr, os.Exit, logger)
 }
 
 func Setup(args []string, stdout, stderr io.Writer, exitFunc func(int), logger logging.Logger) {
+	// Reset repeatable flags left over from a previous run
+	resourcePtrs, providerPtrs, providerAliasPtrs, typeOverridePtrs, forEachMapPtrs, resourceProvPtrs, devOverridePtrs, extraHCLPtrs, toggleablePtrs, perKeyProvPtrs, configPtrs, preconditionPtrs, resourceAliasPtrs, backendConfigPtrs = nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil, nil
+
+	// Dispatch subcommands, which have their own flags
+	if len(args) > 0 && args[0] == searchProvidersCommand {
+		SearchProviders(args[1:], stdout, stderr, exitFunc, logger)
+		return
+	}
+
 	// Create a new FlagSet for this run
 	flags := pflag.NewFlagSet("tmcg", pflag.ContinueOnError)
 	flags.SetOutput(stderr)
@@ -76,15 +218,87 @@ func Setup(args []string, stdout, stderr io.Writer, exitFunc func(int), logger l
 	// Define command-line flags
 	flags.VarP(&resourcePtrs, "resource", "r", "Specify Terraform resources with optional mode (e.g., --resource aws_security_group:single --resource azurerm_network_security_group:multiple)")
 	flags.VarP(&providerPtrs, "provider", "p", "Specify Terraform providers (including optional versions) using multiple --provider flags (e.g., --provider 'hashicorp/aws' --provider 'Azure/azapi:>=2.0')")
+	flags.Var(&configPtrs, "config", "Read providers, resources, directory and binary from a JSON config file; repeat to merge files in order")
+	flags.BoolVar(&mergeVersions, "merge-versions", false, "Combine differing version constraints of a provider declared more than once instead of failing")
 	flags.StringVarP(&workingDir, "directory", "d", "terraform", "The working directory for Terraform")
 	flags.StringVarP(&binaryPath, "binary", "b", "terraform", "The path to the Terraform binary")
 	flags.StringVarP(&logLevel, "log-level", "l", "info", "Set the log level")
 	flags.BoolVarP(&helpFlag, "help", "h", false, "Show usage information")
 	flags.BoolVarP(&versionFlag, "version", "v", false, "Show version information")
 	flags.BoolVar(&descAsCommentsFlag, "desc-as-comment", false, "Include description as a comment")
+	flags.StringVar(&jsonSummaryPath, "json-summary", "", "Write a JSON summary of the run to the given path")
+	flags.Var(&providerAliasPtrs, "provider-alias", "Declare a provider configuration alias (e.g., --provider-alias aws.west)")
+	flags.BoolVar(&providerBlocksFlag, "provider-blocks", false, "Generate providers.tf with a provider block per alias")
+	flags.BoolVar(&groupByProvider, "group-by-provider", false, "Write one resource file and one variables file per provider")
+	flags.StringVar(&lockFilePath, "lockfile", "", "Use the provider versions pinned in the given .terraform.lock.hcl")
+	flags.BoolVar(&pinResolved, "pin-resolved", false, "After terraform init, rewrite versions.tf with the exact provider versions init resolved")
+	flags.IntVar(&parallelResources, "parallel-resources", 0, "Number of workers rendering resources concurrently (default: 0, in order)")
+	flags.IntVar(&concurrency, "concurrency", runtime.GOMAXPROCS(0), "Maximum number of concurrent workers in any worker pool (default: GOMAXPROCS)")
+	flags.BoolVar(&blockDescComments, "block-desc-comments", false, "Write nested block descriptions as comments even without --desc-as-comment")
+	flags.StringVar(&descriptionsPath, "descriptions", "", "JSON file mapping resource.attribute to variable descriptions that replace the schema's")
+	flags.IntVar(&heredocThreshold, "heredoc-threshold", 0, "Write string defaults longer than this many characters, or spanning several lines, as heredocs (default: 0, disabled)")
+	flags.StringVar(&fromStatePath, "from-state", "", "Write moved.tf with moved and removed blocks that align the resources of a terraform show -json state with the generated addresses")
+	flags.StringVar(&defaultsFromPath, "defaults-from", "", "JSON file mapping resource.attribute to default values for optional single-mode variables")
+	flags.Var(&resourceProvPtrs, "resource-provider-alias", "Set the provider meta-argument of a resource to a declared alias (e.g., --resource-provider-alias 'aws_instance=aws.west')")
+	flags.Var(&perKeyProvPtrs, "per-key-provider", "Set the provider meta-argument of a multiple mode resource to a declared alias indexed by each.key (e.g., --per-key-provider 'aws_instance=aws.by_region')")
+	flags.Var(&resourceAliasPtrs, "resource-alias", "Name the variables of a resource after an alias instead of its type, keeping its label (e.g., --resource-alias 'aws_instance=frontend')")
+	flags.Var(&toggleablePtrs, "toggleable", "Create a single-mode resource only when var.<resource>_enabled is true (e.g., --toggleable aws_instance)")
+	flags.Var(&preconditionPtrs, "precondition", "Add a lifecycle precondition to a resource block (e.g., --precondition 'aws_instance.this:var.ami != \"\":AMI required')")
+	flags.Var(&extraHCLPtrs, "extra-hcl", "Append a raw HCL snippet to a resource block (e.g., --extra-hcl 'aws_instance=lifecycle { create_before_destroy = true }')")
+	flags.Var(&devOverridePtrs, "dev-override", "Use a local provider build via dev_overrides (e.g., --dev-override 'hashicorp/aws=/path/to/plugin/dir')")
+	flags.Var(&forEachMapPtrs, "for-each-map", "Iterate one multiple-mode resource over a map variable whose keys supply its name attribute (e.g., --for-each-map aws_instance)")
+	flags.BoolVar(&requiredFirst, "blocks-required-first", false, "Order dynamic block content with required attributes and blocks before optional ones")
+	flags.BoolVar(&shortIterators, "short-iterators", false, "Name dynamic block iterators it, it2, ... by nesting level instead of after the block")
+	flags.BoolVar(&noCoalesce, "no-coalesce", false, "Emit for_each expressions without coalesce/can null guards (requires non-null values)")
+	flags.BoolVar(&defaultsLocal, "defaults-local", false, "Centralize single-mode optional attribute defaults in a locals block that main.tf falls back to")
+	flags.BoolVar(&trimProviderPrefix, "trim-provider-prefix", false, "Prefix single-mode block variables with the resource name minus its provider prefix")
+	flags.BoolVar(&keyVar, "key-var", false, "Make every multiple-mode variable a map(object) with arbitrary keys, keeping name as a regular field")
+	flags.StringVar(&singleRefStyle, "single-ref-style", tmcgParsing.SingleRefBare, "How single-mode resources reference variables: bare, prefixed or object")
+	flags.BoolVar(&toggleOnNull, "toggle-on-null", false, "With --single-ref-style object, create each single-mode resource only when its object variable is not null")
+	flags.BoolVar(&interactive, "interactive", false, "Pick the resources and their optional attributes to generate from prompts after fetching the schema")
+	flags.BoolVar(&schemaStdin, "schema-stdin", false, "Read the provider schema from 'terraform providers schema -json' output on stdin instead of fetching it")
+	flags.BoolVar(&previewSchema, "preview-schema", false, "Print the cleaned provider schema as JSON before the final validation")
+	flags.StringVar(&emitSchemaPath, "emit-schema", "", "Write the cleaned provider schema as JSON to a file and stop without generating main.tf and variables.tf")
+	flags.BoolVar(&emptyCollections, "empty-collection-defaults", false, "Default optional single-mode list, set and map variables to empty collections instead of null")
+	flags.StringVar(&nullDefaultTypes, "null-default-types", "", "Defaults of optional single-mode primitive variables by type (e.g., 'string=empty,number=zero,bool=false')")
+	flags.BoolVar(&outputID, "output-id", false, "Generate outputs.tf exposing the id of each resource")
+	flags.BoolVar(&requiredMultiple, "required-multiple", false, "Leave out the default of multiple-mode variables so the module requires them")
+	flags.BoolVar(&nonNullable, "non-nullable", false, "Set nullable = false on multiple-mode variables and required single-mode variables")
+	flags.StringVar(&postHook, "post-hook", "", "Run a command in the output directory after the files are generated and validated")
+	flags.StringVar(&optionalBlockDef, "optional-block-default", tmcgParsing.OptionalBlockDefaultNull, "Default of optional single-mode nested block variables: null, or none to leave them without a default")
+	flags.BoolVar(&autoTags, "auto-tags", false, "Merge var.common_tags into the tag attribute of every resource that has one")
+	flags.StringVar(&tagAttributes, "tag-attributes", "tags,labels", "Candidate tag attribute names for --auto-tags, in order of preference")
+	flags.StringVar(&attributeCase, "attribute-case", tmcgParsing.AttributeCasePreserve, "Case of single-mode variable names derived from attribute names: snake or preserve")
+	flags.BoolVar(&globalVarSort, "global-var-sort", false, "Sort all variables of variables.tf by required first, then name, instead of grouping them by resource")
+	flags.BoolVar(&sectionHeaders, "section-headers", false, "Divide main.tf and variables.tf with a '# --- <resource> ---' comment before each resource's blocks")
+	flags.BoolVar(&simplePluralFlag, "simple-plural", false, "Pluralize multiple-mode variable names by appending s or es (y becomes ies) instead of using English plural rules")
+	flags.StringVar(&keyMode, "key-mode", tmcgParsing.KeyModeName, "How multiple-mode list variables are keyed in for_each: name, index or coalesce")
+	flags.BoolVar(&appendMode, "append", false, "Keep an existing main.tf and variables.tf and only add the resource and variable blocks they lack")
+	flags.BoolVar(&pruneProviders, "prune-unused-providers", false, "Leave providers that no resource uses out of versions.tf and the schema fetch")
+	flags.StringVar(&backendName, "backend", "", "Add a backend or cloud block skeleton to versions.tf: local, s3 or cloud")
+	flags.StringVar(&backendFile, "backend-file", "", "Write the --backend block to a file of its own, such as backend.tf, instead of versions.tf")
+	flags.Var(&backendConfigPtrs, "backend-config", "Set an attribute of the --backend block (e.g., --backend-config 'bucket=my-state')")
+	flags.StringVar(&goldenDir, "golden", "", "Generate into a temporary directory and diff the .tf files against a golden directory")
+	flags.StringVar(&zipPath, "zip", "", "Generate into a temporary directory and write the generated files into a zip archive")
+	flags.StringVar(&diffSource, "diff-source", "", "Generate into a temporary directory and diff the .tf files against a published module (local path or git source)")
+	flags.BoolVar(&emitGitignore, "emit-gitignore", false, "Write a standard Terraform .gitignore into the working directory if none exists")
+	flags.BoolVar(&emitMakefile, "emit-makefile", false, "Write a Makefile with init, plan, validate and fmt targets into the working directory if none exists")
+	flags.StringVar(&onlyFile, "only", "", "Generate only the given file: main, variables or versions")
+	flags.BoolVar(&multilineDesc, "multiline-desc", false, "Preserve newlines in descriptions using heredoc syntax")
+	flags.Var(&typeOverridePtrs, "type-override", "Override the variable type of an attribute (e.g., --type-override 'aws_instance.tags=map(string)')")
+	flags.BoolVar(&explain, "explain", false, "Trace to stderr why each attribute is kept, dropped or transformed by the schema passes")
+	flags.BoolVar(&keepID, "keep-id", false, "Keep an optional top-level id attribute as a variable instead of dropping it")
+	flags.BoolVar(&computedOutputs, "computed-outputs", false, "Expose computed-only attributes as outputs in outputs.tf instead of dropping them")
+	flags.BoolVar(&errorsJSON, "errors-json", false, "On failure, write a single JSON object with the step, error and details to stderr instead of error logs")
+	flags.StringVar(&registryHost, "registry-host", tmcgParsing.DefaultRegistryHost, "Registry host that provider sources resolve to, for schema lookups and versions.tf sources")
+	flags.StringVar(&fileModeFlag, "file-mode", "0644", "Octal permission mode of the generated files")
+	flags.StringVar(&defaultProviderVer, "default-provider-version", tmcgParsing.DefaultProviderVersion, "Version constraint for providers given without one")
 
 	// Update the Usage handler
 	setupUsage(stdout, flags)
+	outputWriter = stdout
+	hookErrorWriter = stderr
+	explainWriter = stderr
 
 	// Parse flags
 	if err := flags.Parse(args); err != nil {
@@ -95,7 +309,8 @@ func Setup(args []string, stdout, stderr io.Writer, exitFunc func(int), logger l
 
 	// Handle --version flag
 	if versionFlag {
-		_, _ = fmt.Fprintf(stdout, "tmcg version: %s\nCommit: %s\nBuilt on: %s\n", version, commit, buildDate)
+		buildVersion, buildCommit, builtOn := versionInfo()
+		_, _ = fmt.Fprintf(stdout, "tmcg version: %s\nCommit: %s\nBuilt on: %s\n", buildVersion, buildCommit, builtOn)
 		exitFunc(0)
 		return
 	}
@@ -107,65 +322,234 @@ func Setup(args []string, stdout, stderr io.Writer, exitFunc func(int), logger l
 		return
 	}
 
+	// Report failures as a single JSON object on stderr if requested
+	if errorsJSON {
+		errorLogger := &jsonErrorLogger{next: logger, step: "arguments"}
+		logger = errorLogger
+		exit := exitFunc
+		exitFunc = func(code int) {
+			if code != 0 && len(errorLogger.errors) > 0 {
+				if err := errorLogger.writeReport(stderr); err != nil {
+					_, _ = fmt.Fprintf(stderr, "Error writing JSON error report: %v\n", err)
+				}
+			}
+			exit(code)
+		}
+	}
+
+	// Merge the config files under the command-line flags
+	configResources = nil
+	if len(configPtrs) > 0 {
+		config, err := loadConfigs(configPtrs, mergeVersions)
+		if err != nil {
+			logger.Log("error", "Failed to load config: %v", err)
+			exitFunc(1)
+			return
+		}
+		merged, err := mergeProviderSpecs(config.Providers, providerPtrs, mergeVersions)
+		if err != nil {
+			logger.Log("error", "Failed to merge providers: %v", err)
+			exitFunc(1)
+			return
+		}
+		providerPtrs = merged
+		resourcePtrs = append(config.resourceSpecs(), resourcePtrs...)
+		configResources = config.Resources
+		if config.Directory != "" && !flags.Changed("directory") {
+			workingDir = config.Directory
+		}
+		if config.Binary != "" && !flags.Changed("binary") {
+			binaryPath = config.Binary
+		}
+	}
+
 	// Validate inputs
-	if len(resourcePtrs) == 0 || len(providerPtrs) == 0 {
+	if (len(resourcePtrs) == 0 && !interactive) || len(providerPtrs) == 0 {
 		logger.Log("error", "Missing required arguments: resources or providers")
-		flags.Usage()
+		if !errorsJSON {
+			flags.Usage()
+		}
 		exitFunc(1)
 		return
 	}
 
 	// Execute the main pipeline
-	Run(os.Exit, logger)
+	Run(exitFunc, logger)
 }
 
 func Run(exitFunc func(int), logger logging.Logger) {
+	setStep(logger, "parse")
 	logger.Log("info", "Validating provided providers and resources...")
 
 	// Parse and validate providers
 	parser := tmcgParsing.NewParser(logger)
+	if err := parser.SetDefaultVersion(defaultProviderVer); err != nil {
+		setErrorKind(logger, err)
+		logger.Log("error", "Invalid default provider version: %v", err)
+		exitFunc(1)
+		return
+	}
+	host, err := parser.ParseRegistryHost(registryHost)
+	if err != nil {
+		logger.Log("error", "Invalid registry host: %v", err)
+		exitFunc(1)
+		return
+	}
+	registryHost = host
 	providers, err := parser.ParseProviders(providerPtrs)
 	if err != nil {
+		setErrorKind(logger, err)
 		logger.Log("error", "Failed to parse providers from provided pointers: %v", err)
 		pflag.Usage()
 		exitFunc(1)
+		return
+	}
+
+	// Attach configuration aliases to the declared providers
+	if err := parser.ParseProviderAliases(providerAliasPtrs, providers); err != nil {
+		logger.Log("error", "Failed to parse provider aliases: %v", err)
+		exitFunc(1)
+		return
+	}
+
+	// Override the provider constraints with versions from the lock file
+	if lockFilePath != "" {
+		locked, err := parser.ParseLockFile(lockFilePath)
+		if err != nil {
+			logger.Log("error", "Failed to parse lock file: %v", err)
+			exitFunc(1)
+			return
+		}
+		parser.ApplyLockedVersions(providers, locked)
+	}
+
+	// Install providers under development from local plugin directories
+	devOverrides, err := parser.ParseDevOverrides(devOverridePtrs, providers)
+	if err != nil {
+		logger.Log("error", "Failed to parse dev overrides: %v", err)
+		exitFunc(1)
+		return
 	}
 
 	for _, provider := range providers {
 		logger.Log("debug", "Parsed provider: %+v", provider)
 	}
 
+	// Validate the file restriction
+	switch onlyFile {
+	case "", "main", "variables", "versions":
+	default:
+		logger.Log("error", "Invalid value for --only: %s. Expected one of: main, variables, versions", onlyFile)
+		exitFunc(1)
+		return
+	}
+	if onlyFile != "" && groupByProvider {
+		logger.Log("error", "--only cannot be combined with --group-by-provider")
+		exitFunc(1)
+		return
+	}
+	if defaultsLocal && groupByProvider {
+		logger.Log("error", "--defaults-local cannot be combined with --group-by-provider, whose files would each declare local.defaults")
+		exitFunc(1)
+		return
+	}
+	if appendMode && (groupByProvider || defaultsLocal) {
+		logger.Log("error", "--append cannot be combined with --group-by-provider or --defaults-local")
+		exitFunc(1)
+		return
+	}
+	if interactive && schemaStdin {
+		logger.Log("error", "--interactive cannot be combined with --schema-stdin, as both read stdin")
+		exitFunc(1)
+		return
+	}
+
 	// Parse and validate resources
+	if err := parser.SetSingleRefStyle(singleRefStyle); err != nil {
+		logger.Log("error", "Invalid single-mode reference style: %v", err)
+		exitFunc(1)
+		return
+	}
 	resources, err := parser.ParseResources(resourcePtrs, providers)
 	if err != nil {
+		setErrorKind(logger, err)
 		logger.Log("error", "Failed to parse resources from provided pointers and providers: %v", err)
 		pflag.Usage()
 		exitFunc(1)
+		return
+	}
+	if resources, err = applyResourceSettings(parser, resources, configResources); err != nil {
+		logger.Log("error", "Invalid resource settings in config: %v", err)
+		exitFunc(1)
+		return
 	}
 
 	for _, resource := range resources {
 		logger.Log("debug", "Parsed resource: %+v", resource)
 	}
 
+	// Providers without resources are still fetched by init, so flag or prune them. With --interactive
+	// the resources are only picked after the schema fetch.
+	if !interactive {
+		for _, key := range parser.UnusedProviders(providers, resources) {
+			if pruneProviders {
+				delete(providers, key)
+				logger.Log("warn", "Pruned provider %s, which no resource uses", key)
+				continue
+			}
+			logger.Log("warn", "Provider %s is declared but no resource uses it; use --prune-unused-providers to leave it out", key)
+		}
+	}
+
+	// Assemble the generation options
+	opts, err := terraformOptions(parser, resources)
+	if err != nil {
+		logger.Log("error", "Invalid generation options: %v", err)
+		exitFunc(1)
+		return
+	}
+
 	// Ensure the working directory exists
+	setStep(logger, "setup")
+	if goldenDir != "" {
+		if info, err := os.Stat(goldenDir); err != nil || !info.IsDir() {
+			logger.Log("error", "Golden directory %s does not exist or is not a directory", goldenDir)
+			exitFunc(1)
+			return
+		}
+	}
+	if goldenDir != "" || zipPath != "" || diffSource != "" {
+		// Leave the output directory untouched and generate where the result can be thrown away
+		tempDir, err := os.MkdirTemp("", "tmcg-output-")
+		if err != nil {
+			logger.Log("error", "Error creating temporary directory: %s", err)
+			exitFunc(1)
+			return
+		}
+		defer func() { _ = os.RemoveAll(tempDir) }()
+		workingDir = tempDir
+	}
 	err = os.MkdirAll(workingDir, 0755)
 	if err != nil {
 		logger.Log("error", "Error creating working directory: %s", err)
 		exitFunc(1)
+		return
 	}
 	logger.Log("info", "Working directory set to: %s", workingDir)
 
 	// Validate Terraform binary
 	logger.Log("debug", "Using Terraform binary: %s", binaryPath)
-	path, err := exec.LookPath(binaryPath)
+	path, err := lookPath(binaryPath)
 	if err != nil {
 		logger.Log("error", "Terraform binary not found in PATH: %s", binaryPath)
 		exitFunc(1)
+		return
 	}
 	logger.Log("debug", "Resolved Terraform binary path: %s", path)
 
 	// Start timer for execution
 	startTime := time.Now()
+	summary := newRunSummary(providers, resources)
 
 	defer func() {
 		logger.Log("info", "Execution completed in %s", time.Since(startTime))
@@ -173,77 +557,268 @@ func Run(exitFunc func(int), logger logging.Logger) {
 
 	// Step 1: Initialize Terraform
 	logger.Log("info", "Initializing Terraform in directory: %s", workingDir)
-	tf, err := tfexec.NewTerraform(workingDir, binaryPath)
+	tf, err := newTerraform(workingDir, binaryPath)
 	if err != nil {
 		logger.Log("error", "Error initializing Terraform: %s", err)
 		exitFunc(1)
+		return
+	}
+	terraform := tmcgTerraform.NewTfWithOptions(logger, opts)
+
+	// Point Terraform at a temporary CLI configuration holding the dev overrides
+	if len(devOverrides) > 0 {
+		configDir, err := os.MkdirTemp("", "tmcg-cli-config-")
+		if err != nil {
+			logger.Log("error", "Error creating directory for the Terraform CLI configuration: %s", err)
+			exitFunc(1)
+			return
+		}
+		defer func() { _ = os.RemoveAll(configDir) }()
+
+		configPath := filepath.Join(configDir, "terraformrc")
+		if err := terraform.CreateCLIConfig(configPath, devOverrides); err != nil {
+			logger.Log("error", "Error creating Terraform CLI configuration: %s", err)
+			exitFunc(1)
+			return
+		}
+		if err := tf.SetEnv(cliConfigEnv(configPath)); err != nil {
+			logger.Log("error", "Error setting the Terraform environment: %s", err)
+			exitFunc(1)
+			return
+		}
 	}
 
 	// Step 2: Create versions.tf
-	logger.Log("info", "Creating versions.tf with provider definitions...")
-	terraform := tmcgTerraform.NewTf(logger)
-	err = terraform.CreateVersionsTF(workingDir, providers)
-	if err != nil {
-		logger.Log("error", "Error creating versions.tf: %s", err)
-		exitFunc(1)
+	setStep(logger, "versions")
+	if generatesFile("versions") || !fileExists(filepath.Join(workingDir, "versions.tf")) {
+		if !generatesFile("versions") {
+			logger.Log("info", "versions.tf not found, creating it as terraform init requires it")
+		}
+		logger.Log("info", "Creating versions.tf with provider definitions...")
+		err = terraform.CreateVersionsTF(workingDir, providers)
+		if err != nil {
+			logger.Log("error", "Error creating versions.tf: %s", err)
+			exitFunc(1)
+			return
+		}
+	} else {
+		logger.Log("info", "Keeping existing versions.tf")
+	}
+
+	// Write a .gitignore for the generated module if requested
+	if emitGitignore {
+		if err := terraform.CreateGitignore(workingDir); err != nil {
+			logger.Log("error", "Error creating .gitignore: %s", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Write a Makefile for the module dev loop if requested
+	if emitMakefile {
+		if err := terraform.CreateMakefile(workingDir, binaryPath); err != nil {
+			logger.Log("error", "Error creating Makefile: %s", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Nothing else to generate when only versions.tf was requested
+	if onlyFile == "versions" {
+		logger.Log("info", "Process completed successfully.")
+		return
+	}
+
+	// Create providers.tf with aliased provider blocks if requested
+	if providerBlocksFlag && onlyFile == "" {
+		logger.Log("info", "Creating providers.tf with aliased provider blocks...")
+		if err := terraform.CreateProviderTF(workingDir, providers); err != nil {
+			logger.Log("error", "Error creating providers.tf: %s", err)
+			exitFunc(1)
+			return
+		}
 	}
 
 	// Step 3: Run terraform init
+	setStep(logger, "init")
 	logger.Log("info", "Running terraform init...")
-	err = tf.Init(context.Background(), tfexec.Upgrade(true))
+	initOptions := []tfexec.InitOption{tfexec.Upgrade(true)}
+	if backendName != "" {
+		// The scaffolded backend holds placeholders, so it is not configured until they are filled in
+		initOptions = append(initOptions, tfexec.Backend(false))
+	}
+	stopTimer := summary.timeStep(logger, "init")
+	err = tf.Init(context.Background(), initOptions...)
 	if err != nil {
 		logger.Log("error", "Error running terraform init: %s", err)
 		exitFunc(1)
+		return
+	}
+	stopTimer()
+
+	// Record the provider versions resolved by init
+	_, providerVersions, err := tf.Version(context.Background(), false)
+	if err != nil {
+		logger.Log("warn", "Unable to determine resolved provider versions: %s", err)
+	} else {
+		summary.setResolvedVersions(providerVersions)
+	}
+
+	// Pin versions.tf to the exact provider versions init resolved
+	if pinResolved && generatesFile("versions") {
+		if err := pinResolvedVersions(parser, terraform, providers, providerVersions, logger); err != nil {
+			logger.Log("error", "Error pinning resolved provider versions: %s", err)
+			exitFunc(1)
+			return
+		}
 	}
 
 	// Step 4: Fetch provider schema
-	logger.Log("info", "Fetching provider schema...")
-	schemaJSON, err := tf.ProvidersSchema(context.Background())
+	setStep(logger, "schema")
+	stopTimer = summary.timeStep(logger, "schema")
+	var schemaJSON *tfjson.ProviderSchemas
+	if schemaStdin {
+		logger.Log("info", "Reading provider schema from stdin...")
+		schemaJSON, err = readProviderSchemas(schemaInput)
+	} else {
+		logger.Log("info", "Fetching provider schema...")
+		schemaJSON, err = tf.ProvidersSchema(context.Background())
+		if err == nil && schemaJSON == nil {
+			err = fmt.Errorf("terraform returned no provider schema data")
+		}
+	}
 	if err != nil {
 		logger.Log("error", "Error fetching provider schema: %s", err)
 		exitFunc(1)
+		return
 	}
+	stopTimer()
 	logger.Log("debug", "Fetched provider schema: %+v", schemaJSON)
 
-	// Step 5: Filter the provider schema for required resources
-	logger.Log("info", "Filtering the provider schema for required resources...")
+	// Let the user pick the resources to generate, starting from the --resource flags
+	var sel selector
+	if interactive {
+		setStep(logger, "interactive")
+		sel = newSelector(promptInput, outputWriter)
+		resourceSpecs, err := selectResources(sel, schemaJSON, providers, resourcePtrs)
+		if err != nil {
+			logger.Log("error", "Error selecting resources: %s", err)
+			exitFunc(1)
+			return
+		}
+		if resources, err = parser.ParseResources(resourceSpecs, providers); err != nil {
+			setErrorKind(logger, err)
+			logger.Log("error", "Failed to parse the selected resources: %v", err)
+			exitFunc(1)
+			return
+		}
+		if resources, err = applyResourceSettings(parser, resources, configResources); err != nil {
+			logger.Log("error", "Invalid resource settings in config: %v", err)
+			exitFunc(1)
+			return
+		}
+		if opts, err = terraformOptions(parser, resources); err != nil {
+			logger.Log("error", "Invalid generation options for the selected resources: %v", err)
+			exitFunc(1)
+			return
+		}
+		terraform = tmcgTerraform.NewTfWithOptions(logger, opts)
+		summary.Resources = newRunSummary(providers, resources).Resources
+	}
+
+	// Generate outputs.tf before computed-only attributes such as id are removed
+	if (outputID || computedOutputs) && onlyFile == "" && emitSchemaPath == "" && schemaJSON != nil {
+		if err := terraform.CreateOutputsTF(workingDir, schemaJSON.Schemas, resources); err != nil {
+			logger.Log("error", "Error creating outputs.tf: %s", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Align the resources of an existing state with the generated addresses
+	if fromStatePath != "" && onlyFile == "" && emitSchemaPath == "" {
+		if err := terraform.CreateMovedTF(workingDir, resources); err != nil {
+			logger.Log("error", "Error creating moved.tf: %s", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Step 5 and 6: Filter the provider schema for required resources and remove computed-only attributes
+	logger.Log("info", "Preparing the provider schema for the required resources...")
 	err = logging.InitLogger("info")
 	if err != nil {
 		fmt.Println("Failed to initialize logger:", err)
 		os.Exit(1)
 	}
 	schemaManager := tmcgSchema.NewSchemaManager(logging.GetGlobalLogger())
-	filteredSchema := schemaManager.FilterSchema(schemaJSON, resources)
-	logger.Log("debug", "Filtered provider schema: %+v", filteredSchema)
-
-	// Step 6: Remove computed-only attributes from the filtered schema
-	logger.Log("info", "Removing computed-only attributes from the filtered schema...")
-	cleanedSchema := schemaManager.RemoveComputedAttributes(filteredSchema)
-	logger.Log("debug", "Cleaned provider schema: %+v", cleanedSchema)
-
-	// // Step 7: Generate main.tf
-	logger.Log("info", "Generating main.tf...")
-	err = terraform.CreateMainTF(workingDir, cleanedSchema.Schemas, resources)
+	if explain {
+		schemaManager.SetExplainWriter(explainWriter)
+	}
+	stopTimer = summary.timeStep(logger, "prepare")
+	cleanedSchema, err := schemaManager.Prepare(schemaJSON, resources, tmcgSchema.PrepareOptions{KeepID: keepID})
 	if err != nil {
-		logger.Log("error", "Error creating main.tf: %s", err)
+		logger.Log("error", "Error preparing the provider schema: %s", err)
 		exitFunc(1)
+		return
+	}
+	stopTimer()
+	logger.Log("debug", "Cleaned provider schema: %+v", cleanedSchema)
+
+	// Hand the cleaned schema to external tooling instead of generating HCL if requested
+	if emitSchemaPath != "" {
+		logger.Log("info", "Writing cleaned provider schema to: %s", emitSchemaPath)
+		if err := writeSchemaJSON(emitSchemaPath, cleanedSchema); err != nil {
+			logger.Log("error", "Error writing cleaned schema: %v", err)
+			exitFunc(1)
+			return
+		}
+		logger.Log("info", "Process completed successfully.")
+		return
 	}
 
-	// Step 8: Generate variables.tf
-	logger.Log("info", "Generating variables.tf...")
-	err = terraform.CreateVariablesTF(workingDir, cleanedSchema.Schemas, resources, descAsCommentsFlag)
+	// Let the user leave out optional attributes and blocks of the selected resources
+	if interactive {
+		setStep(logger, "interactive")
+		if err := selectAttributes(sel, cleanedSchema.Schemas, resources); err != nil {
+			logger.Log("error", "Error selecting attributes: %s", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Step 7 and 8: Generate main.tf and variables.tf
+	setStep(logger, "generate")
+	if err := terraform.ValidateKeyAttributes(cleanedSchema.Schemas, resources); err != nil {
+		logger.Log("error", "Invalid resource settings: %s", err)
+		exitFunc(1)
+		return
+	}
+	err = generateConfiguration(terraform, cleanedSchema.Schemas, resources, summary, logger)
 	if err != nil {
-		logger.Log("error", "Error creating variables.tf: %s", err)
+		logger.Log("error", "Error generating configuration: %s", err)
 		exitFunc(1)
+		return
+	}
+
+	// Detect runs that succeeded but produced no resource blocks
+	if terraform.CountResourceBlocks(cleanedSchema.Schemas, resources) == 0 {
+		logger.Log("error", "No resource blocks were generated. Check that the resource names exist in the provider schema.")
+		exitFunc(exitCodeNoResources)
+		return
 	}
 
 	// Step 9: Run terraform validate
+	setStep(logger, "validate")
 	logger.Log("info", "Running terraform validate...")
+	stopTimer = summary.timeStep(logger, "validate")
 	validationErrors, err := terraform.RunTerraformValidate(tf)
 	if err != nil {
 		logger.Log("error", "Error running terraform validate: %s", err)
 		exitFunc(1)
+		return
 	}
+	stopTimer()
 	logger.Log("debug", "Validation output: %+v", validationErrors)
 
 	// Step 10: Remove invalid attributes from the cleaned schema
@@ -252,32 +827,41 @@ func Run(exitFunc func(int), logger logging.Logger) {
 		cleanedSchema = schemaManager.RemoveInvalidAttributesFromSchema(cleanedSchema.Schemas, validationErrors)
 		logger.Log("info", "Invalid attributes removed. Regenerating main.tf and variables.tf...")
 
-		// Regenerate main.tf
-		err = terraform.CreateMainTF(workingDir, cleanedSchema.Schemas, resources)
+		// Regenerate main.tf and variables.tf
+		err = generateConfiguration(terraform, cleanedSchema.Schemas, resources, summary, logger)
 		if err != nil {
-			logger.Log("error", "Error creating main.tf after cleaning schema: %s", err)
+			logger.Log("error", "Error generating configuration after cleaning schema: %s", err)
 			exitFunc(1)
+			return
 		}
+	} else {
+		logger.Log("info", "No invalid attributes found, no need to modify the schema.")
+	}
 
-		// Regenerate variables.tf
-		err = terraform.CreateVariablesTF(workingDir, cleanedSchema.Schemas, resources, descAsCommentsFlag)
+	// Print the schema the renderers used if requested
+	if previewSchema {
+		schemaJSON, err := json.MarshalIndent(cleanedSchema, "", "  ")
 		if err != nil {
-			logger.Log("error", "Error creating variables.tf after cleaning schema: %s", err)
+			logger.Log("error", "Error marshaling cleaned schema: %v", err)
 			exitFunc(1)
+			return
 		}
-	} else {
-		logger.Log("info", "No invalid attributes found, no need to modify the schema.")
+		_, _ = fmt.Fprintln(outputWriter, string(schemaJSON))
 	}
 
 	// Step 11: Run final terraform validate
 	logger.Log("info", "Running terraform validate...")
+	stopTimer = summary.timeStep(logger, "validate")
 	validationErrors, err = terraform.RunTerraformValidate(tf)
 	if err != nil {
 		logger.Log("error", "Error running terraform validate: %s", err)
 		exitFunc(1)
+		return
 	}
+	stopTimer()
 
 	// Check and log validation errors
+	summary.setValidation(validationErrors)
 	if len(validationErrors) == 0 {
 		logger.Log("info", "Validation completed successfully with no errors.")
 	} else {
@@ -291,15 +875,424 @@ func Run(exitFunc func(int), logger logging.Logger) {
 	}
 
 	// Step 12: Run terraform fmt
+	setStep(logger, "format")
 	logger.Log("info", "Running terraform fmt on directory: %s", workingDir)
+	stopTimer = summary.timeStep(logger, "format")
 	err = terraform.RunTerraformFmt(tf.WorkingDir(), tf.FormatWrite)
 	if err != nil {
 		logger.Log("error", "Error running terraform fmt: %v", err)
 		exitFunc(1)
+		return
+	}
+	stopTimer()
+
+	// Surface attributes that were silently dropped because terraform validate rejected them
+	if removed := schemaManager.RemovedInvalidAttributes(); len(removed) > 0 {
+		logger.Log("warn", "Removed %d attribute(s) that terraform validate reported cannot be set:", len(removed))
+		for _, path := range removed {
+			logger.Log("warn", "  - %s", path)
+		}
+	}
+
+	// Consolidate the resources and blocks that were left out of the generated files
+	if skipped := terraform.Skipped(); len(skipped) > 0 {
+		logger.Log("warn", "Skipped %d item(s) during generation:", len(skipped))
+		for _, item := range skipped {
+			logger.Log("warn", "  - %s", item)
+		}
+	}
+
+	// Hand the generated module to the user's command
+	setStep(logger, "post-hook")
+	if postHook != "" {
+		logger.Log("info", "Running post-hook in %s: %s", workingDir, postHook)
+		if err := runPostHook(postHook, workingDir, outputWriter, hookErrorWriter); err != nil {
+			logger.Log("error", "Error running post-hook: %v", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Archive the validated and formatted module if requested
+	setStep(logger, "zip")
+	if zipPath != "" {
+		logger.Log("info", "Writing generated files to zip archive: %s", zipPath)
+		if err := zipModule(zipPath, workingDir); err != nil {
+			logger.Log("error", "Error writing zip archive: %v", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Write the JSON summary if requested
+	setStep(logger, "summary")
+	if jsonSummaryPath != "" {
+		summary.setResources(cleanedSchema.Schemas)
+		summary.ComputedAttributesRemoved = schemaManager.RemovedComputedAttributes()
+		summary.InvalidAttributesRemoved = schemaManager.RemovedInvalidAttributes()
+		summary.Skipped = terraform.Skipped()
+		summary.ElapsedSeconds = time.Since(startTime).Seconds()
+		logger.Log("info", "Writing JSON summary to: %s", jsonSummaryPath)
+		if err := summary.writeJSON(jsonSummaryPath); err != nil {
+			logger.Log("error", "Error writing JSON summary: %v", err)
+			exitFunc(1)
+			return
+		}
+	}
+
+	// Compare the generated module against the published one if requested
+	setStep(logger, "diff")
+	if diffSource != "" {
+		logger.Log("info", "Comparing generated files against module source: %s", diffSource)
+		sourceDir, cleanup, err := fetchModuleSource(diffSource)
+		if err != nil {
+			logger.Log("error", "Error fetching module source: %v", err)
+			exitFunc(1)
+			return
+		}
+		defer cleanup()
+
+		drift, err := diffModule(outputWriter, "source", sourceDir, workingDir)
+		if err != nil {
+			logger.Log("error", "Error comparing against module source: %v", err)
+			exitFunc(1)
+			return
+		}
+		if drift {
+			logger.Log("warn", "Generated files differ from module source: %s", diffSource)
+			exitFunc(exitCodeDrift)
+			return
+		}
+		logger.Log("info", "Generated files match module source: %s", diffSource)
+	}
+	if goldenDir != "" {
+		logger.Log("info", "Comparing generated files against golden directory: %s", goldenDir)
+		drift, err := diffModule(outputWriter, "golden", goldenDir, workingDir)
+		if err != nil {
+			logger.Log("error", "Error comparing against golden directory: %v", err)
+			exitFunc(1)
+			return
+		}
+		if drift {
+			logger.Log("warn", "Generated files differ from golden directory: %s", goldenDir)
+			exitFunc(exitCodeDrift)
+			return
+		}
+		logger.Log("info", "Generated files match golden directory: %s", goldenDir)
 	}
 	logger.Log("info", "Process completed successfully.")
 }
 
+// writeSchemaJSON writes provider schemas as indented JSON to path
+func writeSchemaJSON(path string, schemas *tfjson.ProviderSchemas) error {
+	data, err := json.MarshalIndent(schemas, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal schema: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write %s: %w", path, err)
+	}
+	return nil
+}
+
+// readProviderSchemas decodes the output of 'terraform providers schema -json', rejecting documents
+// that are not provider schemas
+func readProviderSchemas(input io.Reader) (*tfjson.ProviderSchemas, error) {
+	var schemas *tfjson.ProviderSchemas
+	if err := json.NewDecoder(input).Decode(&schemas); err != nil {
+		return nil, fmt.Errorf("stdin is not a 'terraform providers schema -json' document: %w", err)
+	}
+	if schemas == nil {
+		return nil, fmt.Errorf("stdin is not a 'terraform providers schema -json' document: provider schema data is nil")
+	}
+	return schemas, nil
+}
+
+// versionInfo returns the version, commit and build date set via ldflags, falling back to the module
+// version and VCS stamping that Go embeds in the binary for values the ldflags left unset
+func versionInfo() (string, string, string) {
+	buildVersion, buildCommit, builtOn := version, commit, buildDate
+	info, ok := readBuildInfo()
+	if !ok {
+		return buildVersion, buildCommit, builtOn
+	}
+
+	if buildVersion == "dev" && info.Main.Version != "" && info.Main.Version != "(devel)" {
+		buildVersion = info.Main.Version
+	}
+	settings := make(map[string]string, len(info.Settings))
+	for _, setting := range info.Settings {
+		settings[setting.Key] = setting.Value
+	}
+	if buildCommit == "none" && settings["vcs.revision"] != "" {
+		buildCommit = settings["vcs.revision"]
+		if settings["vcs.modified"] == "true" {
+			buildCommit += "-dirty"
+		}
+	}
+	if builtOn == "unknown" && settings["vcs.time"] != "" {
+		builtOn = settings["vcs.time"]
+	}
+	return buildVersion, buildCommit, builtOn
+}
+
+// terraformOptions builds the generation options from the command-line flags
+func terraformOptions(parser *tmcgParsing.Parser, resources []tmcgParsing.Resource) (tmcgTerraform.Options, error) {
+	opts := tmcgTerraform.Options{}
+
+	if defaultsFromPath != "" {
+		defaults, err := parser.ParseDefaultsFile(defaultsFromPath)
+		if err != nil {
+			return opts, err
+		}
+		opts.Defaults = defaults
+	}
+
+	if descriptionsPath != "" {
+		descriptions, err := parser.ParseDescriptionsFile(descriptionsPath)
+		if err != nil {
+			return opts, err
+		}
+		opts.Descriptions = descriptions
+	}
+
+	if fromStatePath != "" {
+		stateResources, err := parser.ParseStateFile(fromStatePath)
+		if err != nil {
+			return opts, err
+		}
+		opts.StateResources = stateResources
+	}
+
+	typeOverrides, err := parser.ParseTypeOverrides(typeOverridePtrs)
+	if err != nil {
+		return opts, err
+	}
+	opts.TypeOverrides = typeOverrides
+	opts.MultilineDescriptions = multilineDesc
+	opts.BlockDescComments = blockDescComments
+	opts.Append = appendMode
+	opts.NonNullable = nonNullable
+	opts.RequiredMultiple = requiredMultiple
+	opts.RegistryHost = registryHost
+
+	fileMode, err := parser.ParseFileMode(fileModeFlag)
+	if err != nil {
+		return opts, err
+	}
+	opts.FileMode = fileMode
+
+	backend, err := parser.ParseBackend(backendName)
+	if err != nil {
+		return opts, err
+	}
+	opts.Backend = backend
+
+	if backend == "" && (backendFile != "" || len(backendConfigPtrs) > 0) {
+		return opts, fmt.Errorf("--backend-file and --backend-config require --backend")
+	}
+	if opts.BackendFile, err = parser.ParseBackendFile(backendFile); err != nil {
+		return opts, err
+	}
+	if opts.BackendConfig, err = parser.ParseBackendConfig(backendConfigPtrs); err != nil {
+		return opts, err
+	}
+
+	if heredocThreshold < 0 {
+		return opts, fmt.Errorf("invalid --heredoc-threshold value %d. Expected zero or a positive number of characters", heredocThreshold)
+	}
+	opts.HeredocThreshold = heredocThreshold
+
+	if parallelResources < 0 {
+		return opts, fmt.Errorf("invalid --parallel-resources value %d. Expected zero or a positive number of workers", parallelResources)
+	}
+	opts.ParallelResources = parallelResources
+
+	if concurrency < 1 {
+		return opts, fmt.Errorf("invalid --concurrency value %d. Expected a positive number of workers", concurrency)
+	}
+	opts.Concurrency = concurrency
+	opts.EmptyCollectionDefaults = emptyCollections
+	opts.SingleRefStyle = singleRefStyle
+	opts.KeyVar = keyVar
+	opts.ComputedOutputs = computedOutputs
+	opts.SimplePlural = simplePluralFlag
+	opts.SectionHeaders = sectionHeaders
+	if globalVarSort && sectionHeaders {
+		return opts, fmt.Errorf("--global-var-sort cannot be combined with --section-headers, as variables are no longer grouped by resource")
+	}
+	opts.GlobalVarSort = globalVarSort
+
+	if opts.AttributeCase, err = parser.ParseAttributeCase(attributeCase); err != nil {
+		return opts, err
+	}
+
+	mode, err := parser.ParseKeyMode(keyMode)
+	if err != nil {
+		return opts, err
+	}
+	opts.KeyMode = mode
+
+	blockDefault, err := parser.ParseOptionalBlockDefault(optionalBlockDef)
+	if err != nil {
+		return opts, err
+	}
+	opts.OptionalBlockDefault = blockDefault
+	opts.ShortIterators = shortIterators
+	opts.NoCoalesce = noCoalesce
+	opts.DefaultsLocal = defaultsLocal
+	opts.BlocksRequiredFirst = requiredFirst
+	opts.TrimProviderPrefix = trimProviderPrefix
+
+	nullDefaults, err := parser.ParseNullDefaultTypes(nullDefaultTypes)
+	if err != nil {
+		return opts, err
+	}
+	opts.NullDefaultTypes = nullDefaults
+
+	tagNames, err := parser.ParseTagAttributes(tagAttributes)
+	if err != nil {
+		return opts, err
+	}
+	opts.AutoTags = autoTags
+	opts.TagAttributes = tagNames
+
+	forEachMap, err := parser.ParseForEachMap(forEachMapPtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	opts.ForEachMap = forEachMap
+
+	resourceProviders, err := parser.ParseResourceProviderAliases(resourceProvPtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	opts.ResourceProviders = resourceProviders
+
+	perKeyProviders, err := parser.ParsePerKeyProviders(perKeyProvPtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	for name := range perKeyProviders {
+		if _, exists := resourceProviders[name]; exists {
+			return opts, fmt.Errorf("resource %s cannot use both --resource-provider-alias and --per-key-provider", name)
+		}
+	}
+	for _, resource := range resources {
+		if _, exists := perKeyProviders[resource.Name]; exists && resource.Iteration == tmcgParsing.IterationCount {
+			return opts, fmt.Errorf("resource %s iterates with count, which has no each.key for --per-key-provider", resource.Name)
+		}
+	}
+	if len(perKeyProviders) > 0 && !isOpenTofu(binaryPath) {
+		return opts, fmt.Errorf("--per-key-provider needs OpenTofu 1.9 or later (--binary tofu), as Terraform rejects provider references indexed by each.key")
+	}
+	opts.PerKeyProviders = perKeyProviders
+
+	extraHCL, err := parser.ParseExtraHCL(extraHCLPtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	opts.ExtraHCL = extraHCL
+
+	preconditions, err := parser.ParsePreconditions(preconditionPtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	opts.Preconditions = preconditions
+
+	toggleable, err := parser.ParseToggleable(toggleablePtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	opts.Toggleable = toggleable
+
+	if toggleOnNull {
+		if singleRefStyle != tmcgParsing.SingleRefObject {
+			return opts, fmt.Errorf("--toggle-on-null requires --single-ref-style %s", tmcgParsing.SingleRefObject)
+		}
+		if len(toggleable) > 0 {
+			return opts, fmt.Errorf("--toggle-on-null cannot be combined with --toggleable")
+		}
+	}
+	opts.ToggleOnNull = toggleOnNull
+
+	aliases, err := parser.ParseResourceAliases(resourceAliasPtrs, resources)
+	if err != nil {
+		return opts, err
+	}
+	opts.ResourceAliases = aliases
+
+	return opts, nil
+}
+
+// generateConfiguration writes the resource and variable files using the selected layout
+func generateConfiguration(terraform *tmcgTerraform.Tf, schemas map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, summary *runSummary, logger logging.Logger) error {
+	if groupByProvider {
+		logger.Log("info", "Generating per-provider resource and variable files...")
+		defer summary.timeStep(logger, "render-grouped")()
+		return terraform.CreateProviderGroupedTF(workingDir, schemas, resources, descAsCommentsFlag)
+	}
+
+	if generatesFile("main") {
+		logger.Log("info", "Generating main.tf...")
+		stopTimer := summary.timeStep(logger, "render-main")
+		if err := terraform.CreateMainTF(workingDir, schemas, resources); err != nil {
+			return fmt.Errorf("error creating main.tf: %w", err)
+		}
+		stopTimer()
+	}
+
+	if generatesFile("variables") {
+		logger.Log("info", "Generating variables.tf...")
+		stopTimer := summary.timeStep(logger, "render-variables")
+		if err := terraform.CreateVariablesTF(workingDir, schemas, resources, descAsCommentsFlag); err != nil {
+			return fmt.Errorf("error creating variables.tf: %w", err)
+		}
+		stopTimer()
+	}
+	return nil
+}
+
+// pinResolvedVersions rewrites versions.tf with the exact provider versions init resolved, read from the lock
+// file init wrote into the working directory or, without one, from the versions terraform version reports
+func pinResolvedVersions(parser *tmcgParsing.Parser, terraform *tmcgTerraform.Tf, providers map[string]tmcgParsing.Provider, providerVersions map[string]*goversion.Version, logger logging.Logger) error {
+	resolved := make(map[string]string)
+	if lockPath := filepath.Join(workingDir, ".terraform.lock.hcl"); fileExists(lockPath) {
+		locked, err := parser.ParseLockFile(lockPath)
+		if err != nil {
+			return err
+		}
+		resolved = locked
+	} else {
+		for address, version := range providerVersions {
+			if version != nil {
+				resolved[strings.TrimPrefix(strings.ToLower(address), registryHost+"/")] = version.String()
+			}
+		}
+	}
+
+	for key, provider := range providers {
+		if _, exists := resolved[provider.SourceKey()]; !exists {
+			logger.Log("warn", "No resolved version found for provider %s; keeping its constraint %s", key, provider.Version)
+		}
+	}
+	parser.ApplyLockedVersions(providers, resolved)
+
+	logger.Log("info", "Rewriting versions.tf with the resolved provider versions...")
+	return terraform.CreateVersionsTF(workingDir, providers)
+}
+
+// generatesFile reports whether the given file (main, variables or versions) should be written in this run
+func generatesFile(name string) bool {
+	return onlyFile == "" || onlyFile == name
+}
+
+// fileExists reports whether a regular file exists at the given path
+func fileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
 // Set a custom usage message
 func setupUsage(output io.Writer, flags *pflag.FlagSet) {
 	// Get the base name of the program
@@ -317,14 +1310,88 @@ Options:
   --help, -h                    Show usage information
   --version, -v                 Show version information
   --desc-as-comment             Whether to include the description as a comment in multiple mode (default: false)
+  --json-summary <path>         Write a JSON summary of the run (providers, resources, removed attributes, validation, timing) to the given path
+  --default-provider-version <constraint>  Version constraint for providers given without one (default: ">= 0")
+  --provider-alias <name.alias> Declare a provider configuration alias, added to configuration_aliases (e.g., --provider-alias aws.west)
+  --provider-blocks             Generate providers.tf with a provider block for each configuration alias (default: false)
+  --group-by-provider           Write one resource file and one variables file per provider (e.g., aws.tf and aws_variables.tf) instead of main.tf and variables.tf (default: false)
+  --lockfile <path>             Use the exact provider versions pinned in a .terraform.lock.hcl file, overriding --provider constraints
+  --defaults-from <path>        JSON file mapping resource.attribute to the default value of optional single-mode variables (e.g., {"aws_instance.instance_type": "t3.micro"})
+  --type-override <path=type>   Override the variable type of an attribute (e.g., --type-override 'aws_instance.tags=map(string)')
+  --multiline-desc              Preserve newlines in single-mode variable descriptions using heredoc syntax (<<-EOT ... EOT) (default: false)
+  --only <file>                 Generate only the given file (main, variables or versions); the schema is still fetched as needed
+  --emit-gitignore              Write a standard Terraform .gitignore into the working directory unless one already exists (default: false)
+  --diff-source <source>        Generate into a temporary directory instead of --directory and diff the .tf files against a published module (local path, file://, or git::<url>[//subdir][?ref=<ref>]); exits 2 on drift
+  --output-id                   Generate outputs.tf with the id of each resource (a map of ids in multiple mode) (default: false)
+  --empty-collection-defaults   Default optional single-mode list/set variables and optional repeated nested blocks to [] and map variables to {} instead of null (default: false)
+  --for-each-map <resource>     Iterate one multiple-mode resource over a map(object) variable instead of a list; the map key sets its name attribute, which is dropped from the object (e.g., --for-each-map aws_instance)
+  --resource-provider-alias <resource=name.alias>  Set the provider meta-argument of a resource to a declared alias (e.g., --resource-provider-alias 'aws_instance=aws.west')
+  --preview-schema              Print the cleaned provider schema (after computed and invalid attribute removal) as JSON to stdout (default: false)
+  --single-ref-style <style>    How single-mode resources reference variables: bare (var.ami), prefixed (var.aws_instance_ami) or object (var.aws_instance.ami) (default: "bare")
+  --key-var                     Make every multiple-mode variable map(object) with arbitrary keys, iterating with coalesce(var.x, {}); unlike --for-each-map, name stays a regular field of the object (default: false)
+  --short-iterators             Set iterator = it (it2, it3, ... for deeper levels) on dynamic blocks and reference it.value instead of the block name (default: false)
+  --no-coalesce                 Emit for_each = var.x and flatten([...]) without coalesce/can null guards; only safe when values are never null (default: false)
+  --trim-provider-prefix        Name single-mode block variables after the resource without its provider prefix (e.g., instance_root_block_device for aws_instance) (default: false)
+  --dev-override <namespace/name=dir>  Install a provider from a local plugin directory via dev_overrides in a temporary CLI config; its versions.tf entry has no version (e.g., --dev-override 'hashicorp/aws=/path/to/plugin/dir')
+  --extra-hcl <resource=hcl>   Append a raw, syntax-checked HCL snippet to a resource block after the generated attributes (e.g., --extra-hcl 'aws_instance=lifecycle { create_before_destroy = true }')
+  --toggleable <resource>       Add count = var.<resource>_enabled ? 1 : 0 and a bool variable (default true) to a single-mode resource (e.g., --toggleable aws_instance)
+  --errors-json                 On failure, write {"step": ..., "kind": ..., "error": ..., "details": [...]} to stderr instead of error log lines; exit codes are unchanged (default: false)
+  --null-default-types <spec>   Defaults of optional single-mode string/number/bool variables instead of null: string=empty|null, number=zero|null, bool=false|true|null (e.g., 'string=empty,number=zero')
+  --computed-outputs            Expose computed-only attributes, which cannot be set, as outputs in outputs.tf instead of dropping them (default: false)
+  --per-key-provider <resource=name.alias>  Set the provider meta-argument of a multiple mode resource to a declared alias indexed by each.key; OpenTofu 1.9+ only, so --binary must be tofu (e.g., --per-key-provider 'aws_instance=aws.by_region')
+  --defaults-local              Put the defaults of optional single-mode attributes in a locals { defaults = {...} } block and reference coalesce(var.x, local.defaults.x) in main.tf (default: false)
+  --config <file>               Read providers, resources, directory and binary from a JSON config file; repeat to merge files in order (later scalars win, lists append)
+  --merge-versions              Combine differing version constraints of a provider declared in several config files instead of failing (default: false)
+  --blocks-required-first       Order the content of dynamic blocks in main.tf with required attributes and blocks first, then optional ones, each alphabetically (default: false)
+  --emit-makefile               Write a Makefile with init, plan, validate and fmt targets using the --binary path into the working directory unless one already exists (default: false)
+  --schema-stdin                Read the provider schema from 'terraform providers schema -json' output on stdin instead of running the command (default: false)
+  --precondition <type.label:condition:message>  Add a lifecycle precondition to a resource block; the condition ends at the last colon (e.g., --precondition 'aws_instance.this:var.ami != "":AMI required')
+  --interactive                 After fetching the schema, prompt for the resources (preselecting --resource flags) and their optional attributes and blocks to generate; --resource becomes optional (default: false)
+  --descriptions <file>         JSON file mapping resource.attribute (or resource.block.attribute) to variable descriptions that replace or supply the schema's; unknown paths warn
+  --keep-id                     Keep an optional top-level id attribute as a variable instead of dropping it with a warning (default: false)
+  --block-desc-comments         Write nested block descriptions as comments in object types even without --desc-as-comment (default: false)
+  --parallel-resources <n>      Render resources with n concurrent workers; output is identical to rendering them in order (default: 0, in order)
+  --golden <dir>                Generate into a temporary directory instead of --directory and diff the .tf files against a golden directory; exits 2 on mismatch
+  --backend <local|s3|cloud>    Add a backend or cloud block skeleton with placeholder values to versions.tf; terraform init then runs with -backend=false
+  --backend-file <file>         Write the --backend block into a terraform block of its own in the given file (e.g., backend.tf) instead of versions.tf
+  --backend-config <attr=value> Set an attribute of the --backend block, replacing its placeholder (e.g., --backend-config 'bucket=my-state'); repeatable
+  --prune-unused-providers      Leave declared providers that no resource uses out of versions.tf and the schema fetch instead of only warning (default: false)
+  --heredoc-threshold <n>       Write --defaults-from string defaults longer than n characters or spanning several lines as <<-EOT heredocs, which always end with a newline (default: 0, disabled)
+  --append                      Keep an existing main.tf and variables.tf and only append the resource blocks (by type and label) and variables they lack (default: false)
+  --key-mode <name|index|coalesce>  How multiple-mode list variables are keyed in for_each: i.name, the list index, or i.name falling back to the index when null (default: name)
+  --post-hook <command>         Run a command in the output directory after the files are generated and validated; fails the run if it exits non-zero. Arguments are split on whitespace and quotes, without a shell
+  --non-nullable                Set nullable = false on multiple-mode variables (which then default to [] or {}) and on required single-mode variables; attributes inside object() types cannot carry nullable (default: false)
+  --explain                     Trace to stderr why each attribute is kept, dropped or transformed by the schema passes (default: false)
+  --registry-host <host>        Registry host that provider sources resolve to, for schema lookups and versions.tf sources (default: "registry.terraform.io")
+  --from-state <path>           Write moved.tf with moved and removed blocks that align the resources of a terraform show -json state with the generated addresses
+  --concurrency <n>             Maximum number of concurrent workers in any worker pool, such as --parallel-resources (default: GOMAXPROCS)
+  --pin-resolved                After terraform init, rewrite versions.tf with the exact provider versions init resolved (default: false)
+  --required-multiple           Leave out the default of multiple-mode variables so the module requires them (default: false)
+  --file-mode <mode>            Octal permission mode of the generated files (default: "0644")
+  --zip <file>                  Generate into a temporary directory and write the validated, formatted files into a zip archive instead of --directory
+  --optional-block-default <null|none>  Default of optional single-mode nested block variables: null, or none to leave them without one (default: null)
+  --emit-schema <file>          Write the cleaned provider schema (after filtering and computed attribute removal) as JSON to a file and stop without generating main.tf and variables.tf
+  --simple-plural               Name multiple-mode variables by appending s or es (y becomes ies) instead of using English plural rules (default: false)
+  --auto-tags                   Merge var.common_tags into the tag attribute (tags, labels, ...) of every resource that has one (default: false)
+  --tag-attributes <names>      Candidate tag attribute names for --auto-tags, in order of preference (default: tags,labels)
+  --resource-alias <resource=alias>  Name the variables of a resource after an alias instead of its type, keeping its label (e.g., 'aws_instance=frontend'); repeatable
+  --section-headers             Divide main.tf and variables.tf with a '# --- <resource> ---' comment before each resource's blocks (default: false)
+  --toggle-on-null              With --single-ref-style object, add count = var.<resource> == null ? 0 : 1 to single-mode resources and default their object variable to null (default: false)
+  --global-var-sort             Sort all variables of variables.tf by required first, then name, instead of grouping them by resource (default: false)
+  --attribute-case <snake|preserve>  Case of single-mode variable names derived from attribute names; the HCL attributes keep the schema names (default: "preserve")
 
 Example:
   %s --provider 'hashicorp/aws:>=3.0' --resource aws_security_group --provider 'Azure/azapi:<2' --resource azapi_resource
 
 Note:
   - Specify multiple resources and providers by using multiple --resource and --provider flags respectively.
+  - Only one single-mode resource type is allowed with --single-ref-style bare; prefixed and object styles allow several.
+  - The same type may be single-mode under several labels (aws_instance:single:web, aws_instance:single:db); its variables are then label-prefixed (web_ami, db_ami).
+  - Give a resource a custom block label with a third segment (e.g., --resource aws_instance:multiple:web); type and label must be unique.
   - You can include provider versions in the --provider flag (e.g., --provider "provider_namespace/provider_name:version").
-`, programName, programName); err != nil {
+  - A provider published under a different source names it after '=' (e.g., --provider 'hashicorp/aws=myorg/aws-fork:>=1.0'); resources match the name on the left.
+  - Run '%s search-providers <query> [--timeout 10s] [--limit 20]' to look up namespace/name and latest version in the public Terraform Registry.
+`, programName, programName, programName); err != nil {
 			fmt.Fprintf(os.Stderr, "Error writing usage information: %v\n", err)
 		}
 
@@ -2,14 +2,23 @@ package main
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime/debug"
 	"strings"
 	"testing"
 
+	goversion "github.com/hashicorp/go-version"
+	"github.com/hashicorp/terraform-exec/tfexec"
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/spf13/pflag"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
 )
 
 type MockLogger struct {
@@ -189,13 +198,87 @@ Options:
   --help, -h                    Show usage information
   --version, -v                 Show version information
   --desc-as-comment             Whether to include the description as a comment in multiple mode (default: false)
+  --json-summary <path>         Write a JSON summary of the run (providers, resources, removed attributes, validation, timing) to the given path
+  --default-provider-version <constraint>  Version constraint for providers given without one (default: ">= 0")
+  --provider-alias <name.alias> Declare a provider configuration alias, added to configuration_aliases (e.g., --provider-alias aws.west)
+  --provider-blocks             Generate providers.tf with a provider block for each configuration alias (default: false)
+  --group-by-provider           Write one resource file and one variables file per provider (e.g., aws.tf and aws_variables.tf) instead of main.tf and variables.tf (default: false)
+  --lockfile <path>             Use the exact provider versions pinned in a .terraform.lock.hcl file, overriding --provider constraints
+  --defaults-from <path>        JSON file mapping resource.attribute to the default value of optional single-mode variables (e.g., {"aws_instance.instance_type": "t3.micro"})
+  --type-override <path=type>   Override the variable type of an attribute (e.g., --type-override 'aws_instance.tags=map(string)')
+  --multiline-desc              Preserve newlines in single-mode variable descriptions using heredoc syntax (<<-EOT ... EOT) (default: false)
+  --only <file>                 Generate only the given file (main, variables or versions); the schema is still fetched as needed
+  --emit-gitignore              Write a standard Terraform .gitignore into the working directory unless one already exists (default: false)
+  --diff-source <source>        Generate into a temporary directory instead of --directory and diff the .tf files against a published module (local path, file://, or git::<url>[//subdir][?ref=<ref>]); exits 2 on drift
+  --output-id                   Generate outputs.tf with the id of each resource (a map of ids in multiple mode) (default: false)
+  --empty-collection-defaults   Default optional single-mode list/set variables and optional repeated nested blocks to [] and map variables to {} instead of null (default: false)
+  --for-each-map <resource>     Iterate one multiple-mode resource over a map(object) variable instead of a list; the map key sets its name attribute, which is dropped from the object (e.g., --for-each-map aws_instance)
+  --resource-provider-alias <resource=name.alias>  Set the provider meta-argument of a resource to a declared alias (e.g., --resource-provider-alias 'aws_instance=aws.west')
+  --preview-schema              Print the cleaned provider schema (after computed and invalid attribute removal) as JSON to stdout (default: false)
+  --single-ref-style <style>    How single-mode resources reference variables: bare (var.ami), prefixed (var.aws_instance_ami) or object (var.aws_instance.ami) (default: "bare")
+  --key-var                     Make every multiple-mode variable map(object) with arbitrary keys, iterating with coalesce(var.x, {}); unlike --for-each-map, name stays a regular field of the object (default: false)
+  --short-iterators             Set iterator = it (it2, it3, ... for deeper levels) on dynamic blocks and reference it.value instead of the block name (default: false)
+  --no-coalesce                 Emit for_each = var.x and flatten([...]) without coalesce/can null guards; only safe when values are never null (default: false)
+  --trim-provider-prefix        Name single-mode block variables after the resource without its provider prefix (e.g., instance_root_block_device for aws_instance) (default: false)
+  --dev-override <namespace/name=dir>  Install a provider from a local plugin directory via dev_overrides in a temporary CLI config; its versions.tf entry has no version (e.g., --dev-override 'hashicorp/aws=/path/to/plugin/dir')
+  --extra-hcl <resource=hcl>   Append a raw, syntax-checked HCL snippet to a resource block after the generated attributes (e.g., --extra-hcl 'aws_instance=lifecycle { create_before_destroy = true }')
+  --toggleable <resource>       Add count = var.<resource>_enabled ? 1 : 0 and a bool variable (default true) to a single-mode resource (e.g., --toggleable aws_instance)
+  --errors-json                 On failure, write {"step": ..., "kind": ..., "error": ..., "details": [...]} to stderr instead of error log lines; exit codes are unchanged (default: false)
+  --null-default-types <spec>   Defaults of optional single-mode string/number/bool variables instead of null: string=empty|null, number=zero|null, bool=false|true|null (e.g., 'string=empty,number=zero')
+  --computed-outputs            Expose computed-only attributes, which cannot be set, as outputs in outputs.tf instead of dropping them (default: false)
+  --per-key-provider <resource=name.alias>  Set the provider meta-argument of a multiple mode resource to a declared alias indexed by each.key; OpenTofu 1.9+ only, so --binary must be tofu (e.g., --per-key-provider 'aws_instance=aws.by_region')
+  --defaults-local              Put the defaults of optional single-mode attributes in a locals { defaults = {...} } block and reference coalesce(var.x, local.defaults.x) in main.tf (default: false)
+  --config <file>               Read providers, resources, directory and binary from a JSON config file; repeat to merge files in order (later scalars win, lists append)
+  --merge-versions              Combine differing version constraints of a provider declared in several config files instead of failing (default: false)
+  --blocks-required-first       Order the content of dynamic blocks in main.tf with required attributes and blocks first, then optional ones, each alphabetically (default: false)
+  --emit-makefile               Write a Makefile with init, plan, validate and fmt targets using the --binary path into the working directory unless one already exists (default: false)
+  --schema-stdin                Read the provider schema from 'terraform providers schema -json' output on stdin instead of running the command (default: false)
+  --precondition <type.label:condition:message>  Add a lifecycle precondition to a resource block; the condition ends at the last colon (e.g., --precondition 'aws_instance.this:var.ami != "":AMI required')
+  --interactive                 After fetching the schema, prompt for the resources (preselecting --resource flags) and their optional attributes and blocks to generate; --resource becomes optional (default: false)
+  --descriptions <file>         JSON file mapping resource.attribute (or resource.block.attribute) to variable descriptions that replace or supply the schema's; unknown paths warn
+  --keep-id                     Keep an optional top-level id attribute as a variable instead of dropping it with a warning (default: false)
+  --block-desc-comments         Write nested block descriptions as comments in object types even without --desc-as-comment (default: false)
+  --parallel-resources <n>      Render resources with n concurrent workers; output is identical to rendering them in order (default: 0, in order)
+  --golden <dir>                Generate into a temporary directory instead of --directory and diff the .tf files against a golden directory; exits 2 on mismatch
+  --backend <local|s3|cloud>    Add a backend or cloud block skeleton with placeholder values to versions.tf; terraform init then runs with -backend=false
+  --backend-file <file>         Write the --backend block into a terraform block of its own in the given file (e.g., backend.tf) instead of versions.tf
+  --backend-config <attr=value> Set an attribute of the --backend block, replacing its placeholder (e.g., --backend-config 'bucket=my-state'); repeatable
+  --prune-unused-providers      Leave declared providers that no resource uses out of versions.tf and the schema fetch instead of only warning (default: false)
+  --heredoc-threshold <n>       Write --defaults-from string defaults longer than n characters or spanning several lines as <<-EOT heredocs, which always end with a newline (default: 0, disabled)
+  --append                      Keep an existing main.tf and variables.tf and only append the resource blocks (by type and label) and variables they lack (default: false)
+  --key-mode <name|index|coalesce>  How multiple-mode list variables are keyed in for_each: i.name, the list index, or i.name falling back to the index when null (default: name)
+  --post-hook <command>         Run a command in the output directory after the files are generated and validated; fails the run if it exits non-zero. Arguments are split on whitespace and quotes, without a shell
+  --non-nullable                Set nullable = false on multiple-mode variables (which then default to [] or {}) and on required single-mode variables; attributes inside object() types cannot carry nullable (default: false)
+  --explain                     Trace to stderr why each attribute is kept, dropped or transformed by the schema passes (default: false)
+  --registry-host <host>        Registry host that provider sources resolve to, for schema lookups and versions.tf sources (default: "registry.terraform.io")
+  --from-state <path>           Write moved.tf with moved and removed blocks that align the resources of a terraform show -json state with the generated addresses
+  --concurrency <n>             Maximum number of concurrent workers in any worker pool, such as --parallel-resources (default: GOMAXPROCS)
+  --pin-resolved                After terraform init, rewrite versions.tf with the exact provider versions init resolved (default: false)
+  --required-multiple           Leave out the default of multiple-mode variables so the module requires them (default: false)
+  --file-mode <mode>            Octal permission mode of the generated files (default: "0644")
+  --zip <file>                  Generate into a temporary directory and write the validated, formatted files into a zip archive instead of --directory
+  --optional-block-default <null|none>  Default of optional single-mode nested block variables: null, or none to leave them without one (default: null)
+  --emit-schema <file>          Write the cleaned provider schema (after filtering and computed attribute removal) as JSON to a file and stop without generating main.tf and variables.tf
+  --simple-plural               Name multiple-mode variables by appending s or es (y becomes ies) instead of using English plural rules (default: false)
+  --auto-tags                   Merge var.common_tags into the tag attribute (tags, labels, ...) of every resource that has one (default: false)
+  --tag-attributes <names>      Candidate tag attribute names for --auto-tags, in order of preference (default: tags,labels)
+  --resource-alias <resource=alias>  Name the variables of a resource after an alias instead of its type, keeping its label (e.g., 'aws_instance=frontend'); repeatable
+  --section-headers             Divide main.tf and variables.tf with a '# --- <resource> ---' comment before each resource's blocks (default: false)
+  --toggle-on-null              With --single-ref-style object, add count = var.<resource> == null ? 0 : 1 to single-mode resources and default their object variable to null (default: false)
+  --global-var-sort             Sort all variables of variables.tf by required first, then name, instead of grouping them by resource (default: false)
+  --attribute-case <snake|preserve>  Case of single-mode variable names derived from attribute names; the HCL attributes keep the schema names (default: "preserve")
 
 Example:
   tmcg.test --provider 'hashicorp/aws:>=3.0' --resource aws_security_group --provider 'Azure/azapi:<2' --resource azapi_resource
 
 Note:
   - Specify multiple resources and providers by using multiple --resource and --provider flags respectively.
+  - Only one single-mode resource type is allowed with --single-ref-style bare; prefixed and object styles allow several.
+  - The same type may be single-mode under several labels (aws_instance:single:web, aws_instance:single:db); its variables are then label-prefixed (web_ami, db_ami).
+  - Give a resource a custom block label with a third segment (e.g., --resource aws_instance:multiple:web); type and label must be unique.
   - You can include provider versions in the --provider flag (e.g., --provider "provider_namespace/provider_name:version").
+  - A provider published under a different source names it after '=' (e.g., --provider 'hashicorp/aws=myorg/aws-fork:>=1.0'); resources match the name on the left.
+  - Run 'tmcg.test search-providers <query> [--timeout 10s] [--limit 20]' to look up namespace/name and latest version in the public Terraform Registry.
 `
 
 	// Check if the output contains the expected substring
@@ -230,3 +313,562 @@ func TestSetupUsage_ErrorHandling(t *testing.T) {
 	// If no panic occurred, ensure the test passes
 	t.Logf("setupUsage gracefully handled the write error")
 }
+
+// fakeTerraform is an in-memory terraformRunner returning a canned schema
+type fakeTerraform struct {
+	workingDir      string
+	schema          *tfjson.ProviderSchemas
+	versions        map[string]*goversion.Version
+	validateOutputs []*tfjson.ValidateOutput // returned by successive Validate calls before reporting valid
+	unconfigurable  []string                 // "type.attribute" paths Validate rejects when main.tf sets them
+	env             map[string]string
+	cliConfig       string // content of TF_CLI_CONFIG_FILE when SetEnv was called
+}
+
+func (f *fakeTerraform) SetEnv(env map[string]string) error {
+	f.env = env
+	if path, ok := env["TF_CLI_CONFIG_FILE"]; ok {
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		f.cliConfig = string(content)
+	}
+	return nil
+}
+
+func (f *fakeTerraform) Init(ctx context.Context, opts ...tfexec.InitOption) error {
+	return nil
+}
+
+func (f *fakeTerraform) ProvidersSchema(ctx context.Context) (*tfjson.ProviderSchemas, error) {
+	return f.schema, nil
+}
+
+func (f *fakeTerraform) Validate(ctx context.Context) (*tfjson.ValidateOutput, error) {
+	if len(f.validateOutputs) > 0 {
+		output := f.validateOutputs[0]
+		f.validateOutputs = f.validateOutputs[1:]
+		return output, nil
+	}
+
+	// Like Terraform, reject values for attributes only the provider can set
+	content, _ := os.ReadFile(filepath.Join(f.workingDir, "main.tf"))
+	output := &tfjson.ValidateOutput{Valid: true}
+	for _, path := range f.unconfigurable {
+		resourceType, attribute, _ := strings.Cut(path, ".")
+		if !regexp.MustCompile(`(?m)^\s*` + regexp.QuoteMeta(attribute) + `\s*=`).Match(content) {
+			continue
+		}
+		snippetContext := fmt.Sprintf("resource %q %q", resourceType, "this")
+		output.Valid = false
+		output.Diagnostics = append(output.Diagnostics, tfjson.Diagnostic{
+			Severity: tfjson.DiagnosticSeverityError,
+			Summary:  "Value for unconfigurable attribute",
+			Detail:   fmt.Sprintf("Can't configure a value for %q: its value will be decided automatically.", attribute),
+			Snippet:  &tfjson.DiagnosticSnippet{Context: &snippetContext},
+		})
+	}
+	return output, nil
+}
+
+func (f *fakeTerraform) FormatWrite(ctx context.Context, opts ...tfexec.FormatOption) error {
+	return nil
+}
+
+func (f *fakeTerraform) Version(ctx context.Context, skipCache bool) (*goversion.Version, map[string]*goversion.Version, error) {
+	return goversion.Must(goversion.NewVersion("1.8.5")), f.versions, nil
+}
+
+func (f *fakeTerraform) WorkingDir() string {
+	return f.workingDir
+}
+
+// testSchema returns a minimal aws_instance provider schema
+func testSchema() *tfjson.ProviderSchemas {
+	return &tfjson.ProviderSchemas{
+		FormatVersion: "1.0",
+		Schemas: map[string]*tfjson.ProviderSchema{
+			"registry.terraform.io/hashicorp/aws": {
+				ResourceSchemas: map[string]*tfjson.Schema{
+					"aws_instance": {
+						Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"ami":       {AttributeType: cty.String, Required: true},
+								"tags":      {AttributeType: cty.Map(cty.String), Optional: true},
+								"public_ip": {AttributeType: cty.String, Computed: true},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+// runWithFakeTerraform runs Setup against a fake Terraform and returns the exit code
+func runWithFakeTerraform(t *testing.T, schema *tfjson.ProviderSchemas, args ...string) (int, *MockLogger) {
+	t.Helper()
+	exitCode, mockLogger, _ := runWithFakeTerraformOutput(t, schema, args...)
+	return exitCode, mockLogger
+}
+
+// runWithFakeTerraformOutput runs Setup against a fake Terraform and also returns what was written to stdout
+func runWithFakeTerraformOutput(t *testing.T, schema *tfjson.ProviderSchemas, args ...string) (int, *MockLogger, string) {
+	t.Helper()
+	return runWithTerraform(t, &fakeTerraform{
+		schema: schema,
+		versions: map[string]*goversion.Version{
+			"registry.terraform.io/hashicorp/aws": goversion.Must(goversion.NewVersion("5.1.0")),
+		},
+	}, args...)
+}
+
+// runWithTerraform runs Setup against the given fake Terraform, which is bound to the working directory
+func runWithTerraform(t *testing.T, fake *fakeTerraform, args ...string) (int, *MockLogger, string) {
+	t.Helper()
+
+	originalNewTerraform, originalLookPath := newTerraform, lookPath
+	defer func() { newTerraform, lookPath = originalNewTerraform, originalLookPath }()
+
+	lookPath = func(file string) (string, error) { return "/usr/bin/" + file, nil }
+	newTerraform = func(dir, execPath string) (terraformRunner, error) {
+		fake.workingDir = dir
+		return fake, nil
+	}
+
+	var stdout, stderr bytes.Buffer
+	exitCode := 0
+	mockLogger := &MockLogger{}
+	Setup(args, &stdout, &stderr, func(code int) { exitCode = code }, mockLogger)
+	return exitCode, mockLogger, stdout.String()
+}
+
+func TestRun_DefaultProviderVersion(t *testing.T) {
+	dir := t.TempDir()
+
+	exitCode, _ := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws",
+		"-r", "aws_instance:single",
+		"-d", dir,
+		"--default-provider-version", ">= 1.0",
+	)
+	assert.Equal(t, 0, exitCode)
+
+	content, err := os.ReadFile(filepath.Join(dir, "versions.tf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `version = ">= 1.0"`)
+}
+
+// TestRun_PinResolved tests that versions.tf is rewritten with the exact versions from the lock file
+// or, without one, from terraform version.
+func TestRun_PinResolved(t *testing.T) {
+	dir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws:>=3.0",
+		"-r", "aws_instance:single",
+		"-d", dir,
+		"--pin-resolved",
+	)
+	assert.Equal(t, 0, exitCode)
+	content, err := os.ReadFile(filepath.Join(dir, "versions.tf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `version = "5.1.0"`)
+
+	dir = t.TempDir()
+	lockFile := `provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.31.0"
+  constraints = ">= 3.0"
+}
+`
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, ".terraform.lock.hcl"), []byte(lockFile), 0644))
+	exitCode, _ = runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws:>=3.0",
+		"-r", "aws_instance:single",
+		"-d", dir,
+		"--pin-resolved",
+	)
+	assert.Equal(t, 0, exitCode)
+	content, err = os.ReadFile(filepath.Join(dir, "versions.tf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), `version = "5.31.0"`)
+}
+
+func TestRun_NoResourcesGenerated(t *testing.T) {
+	dir := t.TempDir()
+
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws",
+		"-r", "aws_bogus",
+		"-r", "aws_also_bogus:single",
+		"-d", dir,
+	)
+	assert.Equal(t, exitCodeNoResources, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] No resource blocks were generated. Check that the resource names exist in the provider schema.")
+}
+
+func TestRun_Only(t *testing.T) {
+	tests := []struct {
+		only      string
+		written   []string
+		untouched []string
+	}{
+		{"variables", []string{"variables.tf"}, []string{"main.tf", "versions.tf"}},
+		{"main", []string{"main.tf"}, []string{"variables.tf", "versions.tf"}},
+		{"versions", []string{"versions.tf"}, []string{"main.tf", "variables.tf"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.only, func(t *testing.T) {
+			dir := t.TempDir()
+			for _, name := range []string{"main.tf", "variables.tf", "versions.tf"} {
+				assert.NoError(t, os.WriteFile(filepath.Join(dir, name), []byte("# hand-tuned\n"), 0644))
+			}
+
+			exitCode, _ := runWithFakeTerraform(t, testSchema(),
+				"-p", "hashicorp/aws",
+				"-r", "aws_instance:single",
+				"-d", dir,
+				"--only", test.only,
+			)
+			assert.Equal(t, 0, exitCode)
+
+			for _, name := range test.written {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				assert.NoError(t, err)
+				assert.NotEqual(t, "# hand-tuned\n", string(content), name)
+			}
+			for _, name := range test.untouched {
+				content, err := os.ReadFile(filepath.Join(dir, name))
+				assert.NoError(t, err)
+				assert.Equal(t, "# hand-tuned\n", string(content), name)
+			}
+		})
+	}
+}
+
+func TestRun_OnlyCreatesMissingVersionsTF(t *testing.T) {
+	dir := t.TempDir()
+
+	exitCode, _ := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws",
+		"-r", "aws_instance:single",
+		"-d", dir,
+		"--only", "variables",
+	)
+	assert.Equal(t, 0, exitCode)
+	assert.FileExists(t, filepath.Join(dir, "versions.tf"))
+	assert.FileExists(t, filepath.Join(dir, "variables.tf"))
+	assert.NoFileExists(t, filepath.Join(dir, "main.tf"))
+}
+
+func TestRun_OnlyInvalid(t *testing.T) {
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws",
+		"-r", "aws_instance",
+		"-d", t.TempDir(),
+		"--only", "outputs",
+	)
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] Invalid value for --only: outputs. Expected one of: main, variables, versions")
+}
+
+func TestRun_PreviewSchema(t *testing.T) {
+	exitCode, _, output := runWithFakeTerraformOutput(t, testSchema(),
+		"-p", "hashicorp/aws",
+		"-r", "aws_instance:single",
+		"-d", t.TempDir(),
+		"--preview-schema",
+	)
+	assert.Equal(t, 0, exitCode)
+
+	var preview tfjson.ProviderSchemas
+	assert.NoError(t, json.Unmarshal([]byte(output), &preview))
+	resourceSchema := preview.Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"]
+	if assert.NotNil(t, resourceSchema) {
+		assert.Contains(t, resourceSchema.Block.Attributes, "ami")
+		assert.NotContains(t, resourceSchema.Block.Attributes, "public_ip")
+	}
+}
+
+func TestRun_EmitSchema(t *testing.T) {
+	dir := t.TempDir()
+	schemaPath := filepath.Join(t.TempDir(), "schema.json")
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws",
+		"-r", "aws_instance:single",
+		"-d", dir,
+		"--emit-schema", schemaPath,
+	)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[info] Writing cleaned provider schema to: "+schemaPath)
+
+	content, err := os.ReadFile(schemaPath)
+	require.NoError(t, err)
+	var emitted tfjson.ProviderSchemas
+	require.NoError(t, json.Unmarshal(content, &emitted))
+	resourceSchema := emitted.Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"]
+	if assert.NotNil(t, resourceSchema) {
+		assert.Contains(t, resourceSchema.Block.Attributes, "ami")
+		assert.NotContains(t, resourceSchema.Block.Attributes, "public_ip")
+	}
+
+	// No HCL is generated beyond the versions.tf init needs
+	assert.FileExists(t, filepath.Join(dir, "versions.tf"))
+	assert.NoFileExists(t, filepath.Join(dir, "main.tf"))
+	assert.NoFileExists(t, filepath.Join(dir, "variables.tf"))
+
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", t.TempDir(), "--emit-schema", filepath.Join(dir, "missing", "schema.json"))
+	assert.Equal(t, 1, exitCode)
+}
+
+func TestRun_ReportsRemovedInvalidAttributes(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(t.TempDir(), "summary.json")
+	snippetContext := `resource "aws_instance" "this"`
+
+	exitCode, mockLogger, _ := runWithTerraform(t, &fakeTerraform{
+		schema: testSchema(),
+		validateOutputs: []*tfjson.ValidateOutput{{
+			Diagnostics: []tfjson.Diagnostic{{
+				Severity: tfjson.DiagnosticSeverityError,
+				Summary:  "Value for unconfigurable attribute",
+				Detail:   `Can't configure a value for "tags": its value will be decided automatically.`,
+				Snippet:  &tfjson.DiagnosticSnippet{Context: &snippetContext},
+			}},
+		}},
+	}, "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", dir, "--json-summary", summaryPath)
+	assert.Equal(t, 0, exitCode)
+
+	assert.Contains(t, mockLogger.messages, "[warn] Removed 1 attribute(s) that terraform validate reported cannot be set:")
+	assert.Contains(t, mockLogger.messages, "[warn]   - aws_instance.tags")
+
+	content, err := os.ReadFile(summaryPath)
+	assert.NoError(t, err)
+	var summary runSummary
+	assert.NoError(t, json.Unmarshal(content, &summary))
+	assert.Equal(t, []string{"aws_instance.tags"}, summary.InvalidAttributesRemoved)
+}
+
+func TestRun_PerKeyProviderNeedsOpenTofu(t *testing.T) {
+	args := []string{"-p", "hashicorp/aws", "-r", "aws_instance:multiple", "--provider-alias", "aws.by_region", "--per-key-provider", "aws_instance=aws.by_region"}
+
+	// Terraform rejects provider references indexed by each.key
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), append(args, "-d", t.TempDir())...)
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] Invalid generation options: --per-key-provider needs OpenTofu 1.9 or later (--binary tofu), as Terraform rejects provider references indexed by each.key")
+
+	dir := t.TempDir()
+	exitCode, _ = runWithFakeTerraform(t, testSchema(), append(args, "-d", dir, "--binary", "/opt/bin/tofu")...)
+	assert.Equal(t, 0, exitCode)
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	assert.Regexp(t, `provider\s*=\s*aws\.by_region\[each\.key\]`, string(content))
+}
+
+func TestRun_DevOverride(t *testing.T) {
+	dir := t.TempDir()
+	pluginDir := t.TempDir()
+	fake := &fakeTerraform{schema: testSchema()}
+
+	exitCode, _, _ := runWithTerraform(t, fake, "-p", "hashicorp/aws:>=5.0", "-r", "aws_instance", "-d", dir, "--dev-override", "hashicorp/aws="+pluginDir)
+	assert.Equal(t, 0, exitCode)
+
+	configPath := fake.env["TF_CLI_CONFIG_FILE"]
+	assert.NotEmpty(t, configPath)
+	assert.Contains(t, fake.cliConfig, fmt.Sprintf("\"hashicorp/aws\" = %q", pluginDir))
+	assert.NoFileExists(t, configPath, "the temporary CLI configuration should be removed after the run")
+
+	versions, err := os.ReadFile(filepath.Join(dir, "versions.tf"))
+	assert.NoError(t, err)
+	assert.NotContains(t, string(versions), "version =")
+
+	// Terraform matches dev_overrides by source, so a renamed provider is keyed by its source
+	schema := testSchema()
+	schema.Schemas["registry.terraform.io/myorg/aws-fork"] = schema.Schemas["registry.terraform.io/hashicorp/aws"]
+	delete(schema.Schemas, "registry.terraform.io/hashicorp/aws")
+	fake = &fakeTerraform{schema: schema}
+	exitCode, _, _ = runWithTerraform(t, fake, "-p", "hashicorp/aws=myorg/aws-fork", "-r", "aws_instance", "-d", t.TempDir(), "--dev-override", "hashicorp/aws="+pluginDir)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, fake.cliConfig, fmt.Sprintf("\"myorg/aws-fork\" = %q", pluginDir))
+	assert.NotContains(t, fake.cliConfig, "hashicorp/aws")
+}
+
+func TestRun_ExtraHCL(t *testing.T) {
+	dir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance", "-d", dir, "--extra-hcl", "aws_instance=lifecycle { create_before_destroy = true }")
+	assert.Equal(t, 0, exitCode)
+
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "lifecycle { create_before_destroy = true }")
+
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-r", "aws_instance", "-d", t.TempDir(), "--extra-hcl", "aws_instance=lifecycle {")
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, strings.Join(mockLogger.messages, "\n"), "invalid extra HCL for 'aws_instance'")
+}
+
+func TestSetup_ErrorsJSON(t *testing.T) {
+	var stdout, stderr bytes.Buffer
+	exitCode := 0
+	mockLogger := &MockLogger{}
+
+	Setup([]string{"--errors-json", "-r", "aws_instance"}, &stdout, &stderr, func(code int) { exitCode = code }, mockLogger)
+	assert.Equal(t, 1, exitCode)
+	assert.JSONEq(t, `{"step": "arguments", "error": "Missing required arguments: resources or providers", "details": []}`, stderr.String())
+	assert.Empty(t, stdout.String(), "usage should not be printed in JSON error mode")
+	for _, message := range mockLogger.messages {
+		assert.False(t, strings.HasPrefix(message, "[error]"), "unexpected error log: %s", message)
+	}
+
+	stderr.Reset()
+	Setup([]string{"--errors-json", "-p", "hashicorp/aws", "-r", "aws_instance:invalid"}, &stdout, &stderr, func(code int) { exitCode = code }, &MockLogger{})
+	assert.Equal(t, 1, exitCode)
+
+	var report errorReport
+	assert.NoError(t, json.Unmarshal(stderr.Bytes(), &report))
+	assert.Equal(t, "parse", report.Step)
+	assert.Equal(t, "invalid resource format", report.Kind)
+	assert.Contains(t, report.Error, "invalid mode for resource 'aws_instance'")
+}
+
+func TestSetup_VersionFromBuildInfo(t *testing.T) {
+	originalReadBuildInfo := readBuildInfo
+	defer func() { readBuildInfo = originalReadBuildInfo }()
+	readBuildInfo = func() (*debug.BuildInfo, bool) {
+		return &debug.BuildInfo{
+			Main: debug.Module{Path: "tmcg", Version: "v1.2.3"},
+			Settings: []debug.BuildSetting{
+				{Key: "vcs.revision", Value: "0123456789abcdef"},
+				{Key: "vcs.time", Value: "2024-05-01T10:00:00Z"},
+				{Key: "vcs.modified", Value: "true"},
+			},
+		}, true
+	}
+
+	var stdout, stderr bytes.Buffer
+	Setup([]string{"--version"}, &stdout, &stderr, func(int) {}, &MockLogger{})
+	assert.Equal(t, "tmcg version: v1.2.3\nCommit: 0123456789abcdef-dirty\nBuilt on: 2024-05-01T10:00:00Z\n", stdout.String())
+
+	// Values set via ldflags take precedence over the build info
+	originalCommit := commit
+	defer func() { commit = originalCommit }()
+	commit = "abc1234"
+	stdout.Reset()
+	Setup([]string{"--version"}, &stdout, &stderr, func(int) {}, &MockLogger{})
+	assert.Contains(t, stdout.String(), "Commit: abc1234\n")
+}
+
+func TestRun_ComputedOutputs(t *testing.T) {
+	for _, enabled := range []bool{false, true} {
+		t.Run(fmt.Sprintf("outputs=%t", enabled), func(t *testing.T) {
+			dir := t.TempDir()
+			summaryPath := filepath.Join(t.TempDir(), "summary.json")
+			args := []string{"-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", dir, "--json-summary", summaryPath}
+			if enabled {
+				args = append(args, "--computed-outputs")
+			}
+			// The fake validator rejects a value for the computed-only attribute as Terraform does
+			exitCode, _, _ := runWithTerraform(t, &fakeTerraform{schema: testSchema(), unconfigurable: []string{"aws_instance.public_ip"}}, args...)
+			assert.Equal(t, 0, exitCode)
+
+			variables, err := os.ReadFile(filepath.Join(dir, "variables.tf"))
+			require.NoError(t, err)
+			assert.NotContains(t, string(variables), "public_ip")
+			summaryContent, err := os.ReadFile(summaryPath)
+			require.NoError(t, err)
+			var summary runSummary
+			require.NoError(t, json.Unmarshal(summaryContent, &summary))
+			assert.Empty(t, summary.InvalidAttributesRemoved)
+
+			outputs, err := os.ReadFile(filepath.Join(dir, "outputs.tf"))
+			if enabled {
+				require.NoError(t, err)
+				assert.Regexp(t, `output\s*"aws_instance_public_ip"\s*\{[^}]*value\s*=\s*aws_instance\.this\.public_ip`, string(outputs))
+			} else {
+				assert.True(t, os.IsNotExist(err))
+			}
+		})
+	}
+}
+
+func TestRun_SchemaStdin(t *testing.T) {
+	originalSchemaInput := schemaInput
+	defer func() { schemaInput = originalSchemaInput }()
+
+	schemaJSON, err := json.Marshal(testSchema())
+	require.NoError(t, err)
+	schemaInput = bytes.NewReader(schemaJSON)
+
+	// The fake Terraform has no schema, so generation must use the one read from stdin
+	dir := t.TempDir()
+	exitCode, _, _ := runWithTerraform(t, &fakeTerraform{}, "-p", "hashicorp/aws:>=5.0", "-r", "aws_instance:single", "-d", dir, "--schema-stdin")
+	assert.Equal(t, 0, exitCode)
+	content, err := os.ReadFile(filepath.Join(dir, "main.tf"))
+	require.NoError(t, err)
+	assert.Regexp(t, `ami\s*=\s*var\.ami`, string(content))
+
+	schemaInput = bytes.NewBufferString(`{"resources": []}`)
+	exitCode, mockLogger, _ := runWithTerraform(t, &fakeTerraform{}, "-p", "hashicorp/aws:>=5.0", "-r", "aws_instance:single", "-d", t.TempDir(), "--schema-stdin")
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] Error fetching provider schema: stdin is not a 'terraform providers schema -json' document: unexpected provider schema data, format version is missing")
+}
+
+func TestRun_EmptySchemaDocument(t *testing.T) {
+	// A document without providers fails the run instead of generating nothing
+	exitCode, mockLogger := runWithFakeTerraform(t, &tfjson.ProviderSchemas{FormatVersion: "1.0"}, "-p", "hashicorp/aws", "-r", "aws_instance:single", "-d", t.TempDir())
+	assert.Equal(t, 1, exitCode)
+	assert.Contains(t, mockLogger.messages, "[error] Error preparing the provider schema: no provider schemas to prepare: the schema document is empty, malformed or has no providers")
+}
+
+func TestRun_UnusedProviders(t *testing.T) {
+	dir := t.TempDir()
+	exitCode, mockLogger := runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-p", "hashicorp/random", "-r", "aws_instance:single", "-d", dir)
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[warn] Provider hashicorp/random is declared but no resource uses it; use --prune-unused-providers to leave it out")
+	content, err := os.ReadFile(filepath.Join(dir, "versions.tf"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "hashicorp/random")
+
+	dir = t.TempDir()
+	exitCode, mockLogger = runWithFakeTerraform(t, testSchema(), "-p", "hashicorp/aws", "-p", "hashicorp/random", "-r", "aws_instance:single", "-d", dir, "--prune-unused-providers")
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[warn] Pruned provider hashicorp/random, which no resource uses")
+	content, err = os.ReadFile(filepath.Join(dir, "versions.tf"))
+	require.NoError(t, err)
+	assert.NotContains(t, string(content), "hashicorp/random")
+}
+
+func TestRun_Append(t *testing.T) {
+	schema := testSchema()
+	schema.Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_vpc"] = &tfjson.Schema{Block: &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{"cidr_block": {AttributeType: cty.String, Required: true}},
+	}}
+
+	dir := t.TempDir()
+	exitCode, _ := runWithFakeTerraform(t, schema, "-p", "hashicorp/aws", "-r", "aws_instance:multiple", "-d", dir)
+	require.Equal(t, 0, exitCode)
+
+	// A hand edit to the existing module must survive the second run
+	mainPath := filepath.Join(dir, "main.tf")
+	content, err := os.ReadFile(mainPath)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(mainPath, append([]byte("# managed by hand\n"), content...), 0644))
+
+	exitCode, mockLogger := runWithFakeTerraform(t, schema, "-p", "hashicorp/aws", "-r", "aws_instance:multiple", "-r", "aws_vpc:multiple", "-d", dir, "--append")
+	assert.Equal(t, 0, exitCode)
+	assert.Contains(t, mockLogger.messages, "[info] Keeping resource aws_instance.this already present in main.tf")
+
+	content, err = os.ReadFile(mainPath)
+	require.NoError(t, err)
+	assert.True(t, strings.HasPrefix(string(content), "# managed by hand\n"))
+
+	// The fake fmt strips spaces
+	assert.Equal(t, 1, strings.Count(strings.ReplaceAll(string(content), " ", ""), `resource"aws_instance""this"`))
+	assert.Equal(t, 1, strings.Count(strings.ReplaceAll(string(content), " ", ""), `resource"aws_vpc""this"`))
+
+	variables, err := os.ReadFile(filepath.Join(dir, "variables.tf"))
+	require.NoError(t, err)
+	assert.Equal(t, 1, strings.Count(strings.ReplaceAll(string(variables), " ", ""), `variable"instances"`))
+	assert.Equal(t, 1, strings.Count(strings.ReplaceAll(string(variables), " ", ""), `variable"vpcs"`))
+}
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"tmcg/internal/tmcg/logging"
+
+	"github.com/spf13/pflag"
+)
+
+// searchProvidersCommand is the subcommand that searches the public Terraform Registry
+const searchProvidersCommand = "search-providers"
+
+// registryURL is the base URL of the Terraform Registry API, replaced in tests
+var registryURL = "https://registry.terraform.io"
+
+// registryProvider is a provider entry returned by the registry's v1 providers endpoint
+type registryProvider struct {
+	Namespace   string `json:"namespace"`
+	Name        string `json:"name"`
+	Version     string `json:"version"`
+	Description string `json:"description"`
+}
+
+// SearchProviders runs "tmcg search-providers <query>" and prints matching providers with their latest version
+func SearchProviders(args []string, stdout, stderr io.Writer, exitFunc func(int), logger logging.Logger) {
+	flags := pflag.NewFlagSet(searchProvidersCommand, pflag.ContinueOnError)
+	flags.SetOutput(stderr)
+	timeout := flags.Duration("timeout", 10*time.Second, "Timeout for the registry request")
+	limit := flags.Int("limit", 20, "Maximum number of providers to list")
+
+	if err := flags.Parse(args); err != nil {
+		_, _ = fmt.Fprintf(stderr, "Error parsing flags: %v\n", err)
+		exitFunc(1)
+		return
+	}
+	query := strings.TrimSpace(strings.Join(flags.Args(), " "))
+	if query == "" {
+		logger.Log("error", "Missing search query. Usage: tmcg %s <query> [--timeout 10s] [--limit 20]", searchProvidersCommand)
+		exitFunc(1)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), *timeout)
+	defer cancel()
+
+	providers, err := searchRegistryProviders(ctx, query, *limit)
+	if err != nil {
+		logger.Log("error", "Error searching the Terraform Registry: %v", err)
+		exitFunc(1)
+		return
+	}
+	if len(providers) == 0 {
+		logger.Log("info", "No providers found matching: %s", query)
+		return
+	}
+
+	for _, provider := range providers {
+		_, _ = fmt.Fprintf(stdout, "%-40s %s\n", provider.Namespace+"/"+provider.Name, provider.Version)
+	}
+}
+
+// searchRegistryProviders queries the registry's v1 providers endpoint for providers matching query
+func searchRegistryProviders(ctx context.Context, query string, limit int) ([]registryProvider, error) {
+	values := url.Values{}
+	values.Set("q", query)
+	values.Set("limit", fmt.Sprint(limit))
+	requestURL := strings.TrimSuffix(registryURL, "/") + "/v1/providers?" + values.Encode()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, requestURL, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	request.Header.Set("Accept", "application/json")
+
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		return nil, fmt.Errorf("request to %s failed: %w", requestURL, err)
+	}
+	defer func() { _ = response.Body.Close() }()
+
+	if response.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("registry returned %s", response.Status)
+	}
+
+	var body struct {
+		Providers []registryProvider `json:"providers"`
+	}
+	if err := json.NewDecoder(response.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode registry response: %w", err)
+	}
+	return body.Providers, nil
+}
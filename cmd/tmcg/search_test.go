@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// withRegistry points the registry URL at a test server for the duration of a test
+func withRegistry(t *testing.T, handler http.HandlerFunc) {
+	t.Helper()
+	server := httptest.NewServer(handler)
+	originalURL := registryURL
+	registryURL = server.URL
+	t.Cleanup(func() {
+		registryURL = originalURL
+		server.Close()
+	})
+}
+
+func TestSearchProviders(t *testing.T) {
+	withRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/v1/providers", r.URL.Path)
+		assert.Equal(t, "aws", r.URL.Query().Get("q"))
+		assert.Equal(t, "5", r.URL.Query().Get("limit"))
+		_, _ = w.Write([]byte(`{"meta": {}, "providers": [
+			{"id": "hashicorp/aws/5.31.0", "namespace": "hashicorp", "name": "aws", "version": "5.31.0"},
+			{"id": "hashicorp/awscc/0.66.0", "namespace": "hashicorp", "name": "awscc", "version": "0.66.0"}
+		]}`))
+	})
+
+	var stdout, stderr bytes.Buffer
+	exitCode := 0
+	Setup([]string{"search-providers", "aws", "--limit", "5"}, &stdout, &stderr, func(code int) { exitCode = code }, &MockLogger{})
+
+	assert.Equal(t, 0, exitCode)
+	lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+	assert.Len(t, lines, 2)
+	assert.Equal(t, []string{"hashicorp/aws", "5.31.0"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"hashicorp/awscc", "0.66.0"}, strings.Fields(lines[1]))
+}
+
+func TestSearchProviders_Errors(t *testing.T) {
+	withRegistry(t, func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("q") == "slow" {
+			time.Sleep(200 * time.Millisecond)
+		}
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	})
+
+	tests := []struct {
+		name          string
+		args          []string
+		expectedError string
+	}{
+		{name: "API error", args: []string{"search-providers", "aws"}, expectedError: "registry returned 503 Service Unavailable"},
+		{name: "timeout", args: []string{"search-providers", "slow", "--timeout", "10ms"}, expectedError: "context deadline exceeded"},
+		{name: "missing query", args: []string{"search-providers"}, expectedError: "Missing search query"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			exitCode := 0
+			mockLogger := &MockLogger{}
+			Setup(test.args, &stdout, &stderr, func(code int) { exitCode = code }, mockLogger)
+
+			assert.Equal(t, 1, exitCode)
+			assert.Contains(t, strings.Join(mockLogger.messages, "\n"), test.expectedError)
+			assert.Empty(t, stdout.String())
+		})
+	}
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"tmcg/internal/tmcg/logging"
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+
+	goversion "github.com/hashicorp/go-version"
+	tfjson "github.com/hashicorp/terraform-json"
+)
+
+// runSummary accumulates statistics about a single tmcg run
+type runSummary struct {
+	Providers                 []providerSummary `json:"providers"`
+	Resources                 []resourceSummary `json:"resources"`
+	ComputedAttributesRemoved []string          `json:"computed_attributes_removed"`
+	InvalidAttributesRemoved  []string          `json:"invalid_attributes_removed"`
+	Skipped                   []string          `json:"skipped"`
+	Validation                validationSummary `json:"validation"`
+	Steps                     []stepTiming      `json:"steps"`
+	ElapsedSeconds            float64           `json:"elapsed_seconds"`
+}
+
+// stepTiming is the time spent in one pipeline step, summed over steps that run more than once
+type stepTiming struct {
+	Step    string  `json:"step"`
+	Seconds float64 `json:"seconds"`
+}
+
+// providerSummary describes a requested provider and the version init resolved
+type providerSummary struct {
+	Source          string `json:"source"`
+	Constraint      string `json:"constraint"`
+	ResolvedVersion string `json:"resolved_version,omitempty"`
+}
+
+// resourceSummary describes a generated resource block
+type resourceSummary struct {
+	Name           string `json:"name"`
+	Mode           string `json:"mode"`
+	Label          string `json:"label"`
+	AttributeCount int    `json:"attribute_count"`
+}
+
+// validationSummary holds the result of the final terraform validate
+type validationSummary struct {
+	Valid  bool                `json:"valid"`
+	Errors map[string][]string `json:"errors,omitempty"`
+}
+
+// newRunSummary creates a summary seeded with the parsed providers and resources
+func newRunSummary(providers map[string]tmcgParsing.Provider, resources []tmcgParsing.Resource) *runSummary {
+	keys := make([]string, 0, len(providers))
+	for key := range providers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	summary := &runSummary{
+		ComputedAttributesRemoved: []string{},
+		InvalidAttributesRemoved:  []string{},
+		Skipped:                   []string{},
+		Steps:                     []stepTiming{},
+	}
+	for _, key := range keys {
+		summary.Providers = append(summary.Providers, providerSummary{
+			Source:     providers[key].SourceKey(),
+			Constraint: providers[key].Version,
+		})
+	}
+	for _, resource := range resources {
+		summary.Resources = append(summary.Resources, resourceSummary{
+			Name:  resource.Name,
+			Mode:  resource.Mode,
+			Label: resource.BlockLabel(),
+		})
+	}
+	return summary
+}
+
+// setResolvedVersions records the provider versions reported by terraform version
+func (s *runSummary) setResolvedVersions(providerVersions map[string]*goversion.Version) {
+	for i, provider := range s.Providers {
+		if v, ok := providerVersions[registryHost+"/"+provider.Source]; ok && v != nil {
+			s.Providers[i].ResolvedVersion = v.String()
+		}
+	}
+}
+
+// setResources counts the attributes generated for each resource from the cleaned schema
+func (s *runSummary) setResources(schemas map[string]*tfjson.ProviderSchema) {
+	for i, resource := range s.Resources {
+		for _, providerSchema := range schemas {
+			if resourceSchema, ok := providerSchema.ResourceSchemas[resource.Name]; ok {
+				s.Resources[i].AttributeCount = countAttributes(resourceSchema.Block)
+				break
+			}
+		}
+	}
+}
+
+// timeStep starts timing a pipeline step. The returned function stops the timer, logs the duration at info
+// and adds it to the step's total.
+func (s *runSummary) timeStep(logger logging.Logger, step string) func() {
+	start := time.Now()
+	return func() {
+		elapsed := time.Since(start)
+		logger.Log("info", "Step %s took %s", step, elapsed)
+		for i := range s.Steps {
+			if s.Steps[i].Step == step {
+				s.Steps[i].Seconds += elapsed.Seconds()
+				return
+			}
+		}
+		s.Steps = append(s.Steps, stepTiming{Step: step, Seconds: elapsed.Seconds()})
+	}
+}
+
+// setValidation records the final validation result
+func (s *runSummary) setValidation(validationErrors map[string][]string) {
+	s.Validation = validationSummary{
+		Valid:  len(validationErrors) == 0,
+		Errors: validationErrors,
+	}
+}
+
+// writeJSON serializes the summary to the given path
+func (s *runSummary) writeJSON(path string) error {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal summary: %w", err)
+	}
+	if err := os.WriteFile(path, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write summary to %s: %w", path, err)
+	}
+	return nil
+}
+
+// countAttributes counts the attributes in a block, including those of nested blocks
+func countAttributes(block *tfjson.SchemaBlock) int {
+	if block == nil {
+		return 0
+	}
+	count := len(block.Attributes)
+	for _, nestedBlock := range block.NestedBlocks {
+		if nestedBlock != nil {
+			count += countAttributes(nestedBlock.Block)
+		}
+	}
+	return count
+}
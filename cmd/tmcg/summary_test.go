@@ -0,0 +1,48 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestJSONSummary(t *testing.T) {
+	dir := t.TempDir()
+	summaryPath := filepath.Join(dir, "summary.json")
+
+	exitCode, _ := runWithFakeTerraform(t, testSchema(),
+		"-p", "hashicorp/aws:>=5.0",
+		"-r", "aws_instance:single",
+		"-d", dir,
+		"--json-summary", summaryPath,
+	)
+	assert.Equal(t, 0, exitCode)
+
+	content, err := os.ReadFile(summaryPath)
+	require.NoError(t, err)
+
+	var summary map[string]interface{}
+	require.NoError(t, json.Unmarshal(content, &summary))
+	for _, field := range []string{"providers", "resources", "computed_attributes_removed", "invalid_attributes_removed", "validation", "steps", "elapsed_seconds"} {
+		assert.Contains(t, summary, field)
+	}
+
+	var parsed runSummary
+	require.NoError(t, json.Unmarshal(content, &parsed))
+	assert.Equal(t, []providerSummary{{Source: "hashicorp/aws", Constraint: ">=5.0", ResolvedVersion: "5.1.0"}}, parsed.Providers)
+	assert.Equal(t, []resourceSummary{{Name: "aws_instance", Mode: "single", Label: "this", AttributeCount: 2}}, parsed.Resources)
+	assert.Equal(t, []string{"aws_instance.public_ip"}, parsed.ComputedAttributesRemoved)
+	assert.Empty(t, parsed.InvalidAttributesRemoved)
+	assert.True(t, parsed.Validation.Valid)
+
+	steps := make([]string, 0, len(parsed.Steps))
+	for _, step := range parsed.Steps {
+		steps = append(steps, step.Step)
+		assert.GreaterOrEqual(t, step.Seconds, 0.0, step.Step)
+	}
+	assert.Equal(t, []string{"init", "schema", "prepare", "render-main", "render-variables", "validate", "format"}, steps)
+}
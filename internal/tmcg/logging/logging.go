@@ -75,7 +75,7 @@ func NewLoggerWithConfig(level string, config zap.Config) (*RealLogger, error) {
 	config.Level = zap.NewAtomicLevelAt(lvl)
 
 	logger, err := config.Build(
-		zap.AddCallerSkip(1),
+		zap.AddCallerSkip(callerSkip),
 		zap.AddStacktrace(zapcore.PanicLevel),
 	)
 	if err != nil {
@@ -88,8 +88,17 @@ func NewLoggerWithConfig(level string, config zap.Config) (*RealLogger, error) {
 	}, nil
 }
 
+// callerSkip skips the logf frame and the Log or LogMessage frame that called it,
+// so the reported caller is the code that invoked logging
+const callerSkip = 2
+
 // Log logs a message using the specified log level
 func (r *RealLogger) Log(level string, format string, args ...interface{}) {
+	r.logf(level, format, args...)
+}
+
+// logf writes the message to zap; it must be called directly from Log or LogMessage to keep callerSkip accurate
+func (r *RealLogger) logf(level string, format string, args ...interface{}) {
 	if r.sugar == nil {
 		fmt.Fprintf(os.Stderr, "Logger not initialized. Message: "+format+"\n", args...)
 		return
@@ -127,6 +136,12 @@ func LogMessage(level string, format string, args ...interface{}) {
 		fmt.Fprintf(os.Stderr, "Global logger not initialized. Message: "+format+"\n", args...)
 		return
 	}
+
+	// Bypass RealLogger.Log so the extra frame does not shift the reported caller
+	if realLogger, ok := globalLogger.(*RealLogger); ok {
+		realLogger.logf(level, format, args...)
+		return
+	}
 	globalLogger.Log(level, format, args...)
 }
 
@@ -141,7 +156,7 @@ func GetLogLevel() string {
 // SetCustomLogger allows injecting a custom SugaredLogger for testing purposes
 func SetCustomLogger(customLogger *zap.SugaredLogger) {
 	globalLogger = &RealLogger{
-		sugar: customLogger,
+		sugar: customLogger.WithOptions(zap.AddCallerSkip(callerSkip)),
 	}
 }
 
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -267,3 +269,35 @@ func TestGetGlobalLogger(t *testing.T) {
 		assert.True(t, isRealLogger, "Expected RealLogger when globalLogger is initialized")
 	})
 }
+
+func TestLoggerCaller(t *testing.T) {
+	outputPath := filepath.Join(t.TempDir(), "log.txt")
+	config := zap.Config{
+		Encoding:    "console",
+		OutputPaths: []string{outputPath},
+		EncoderConfig: zapcore.EncoderConfig{
+			CallerKey:    "caller",
+			MessageKey:   "msg",
+			EncodeCaller: zapcore.ShortCallerEncoder,
+		},
+	}
+	logger, err := NewLoggerWithConfig("info", config)
+	assert.NoError(t, err)
+
+	originalLogger := globalLogger
+	defer SetGlobalLogger(originalLogger)
+	SetGlobalLogger(logger)
+
+	logger.Log("info", "direct call")
+	LogMessage("info", "global call")
+	assert.NoError(t, logger.sugar.Sync())
+
+	content, err := os.ReadFile(outputPath)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(string(content)), "\n")
+	assert.Len(t, lines, 2)
+	for _, line := range lines {
+		assert.Contains(t, line, "logging/logging_test.go:")
+		assert.NotContains(t, line, "logging/logging.go:")
+	}
+}
@@ -4,61 +4,281 @@ package parsing
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
 	"regexp"
+	"slices"
+	"sort"
+	"strconv"
 	"strings"
 	"tmcg/internal/tmcg/logging"
+
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/zclconf/go-cty/cty"
+	ctyjson "github.com/zclconf/go-cty/cty/json"
+)
+
+// DefaultProviderVersion is the constraint used for providers specified without a version
+const DefaultProviderVersion = ">= 0"
+
+// DefaultRegistryHost is the registry that provider sources without a hostname resolve to
+const DefaultRegistryHost = "registry.terraform.io"
+
+// Single-mode variable reference styles
+const (
+	SingleRefBare     = "bare"     // var.<attribute>
+	SingleRefPrefixed = "prefixed" // var.<resource>_<attribute>
+	SingleRefObject   = "object"   // var.<resource>.<attribute>
+)
+
+// Backends scaffolded in versions.tf
+const (
+	BackendLocal = "local"
+	BackendS3    = "s3"
+	BackendCloud = "cloud"
+)
+
+// Keys of multiple-mode list variables in for_each
+const (
+	KeyModeName     = "name"     // i.name
+	KeyModeIndex    = "index"    // the list index
+	KeyModeCoalesce = "coalesce" // i.name, or the list index when the name is null
+)
+
+// Cases of the variable names derived from attribute names
+const (
+	AttributeCaseSnake    = "snake"    // lower snake case, e.g. http_endpoint for HTTPEndpoint
+	AttributeCasePreserve = "preserve" // the attribute name as the schema spells it
 )
 
+// Iteration strategies of multiple-mode resources
+const (
+	IterationForEach = "for_each" // for_each over a list variable keyed by an attribute of its objects
+	IterationCount   = "count"    // count over a list variable, indexed by count.index
+	IterationMap     = "map"      // for_each over a map variable keyed by instance name
+)
+
+// Defaults of optional single-mode nested block variables
+const (
+	OptionalBlockDefaultNull = "null" // default = null, so the block can be left out
+	OptionalBlockDefaultNone = "none" // no default, so callers must set the variable, if only to null
+)
+
+// Kinds of provider and resource parsing failures, matched with errors.Is
+var (
+	ErrInvalidProviderFormat = errors.New("invalid provider format")
+	ErrInvalidVersion        = errors.New("invalid version")
+	ErrDuplicateProvider     = errors.New("duplicate provider")
+	ErrInvalidResourceFormat = errors.New("invalid resource format")
+	ErrSingleModeConflict    = errors.New("conflicting single-mode resources")
+	ErrNoMatchingProvider    = errors.New("no matching provider")
+	ErrDuplicateResource     = errors.New("duplicate resource")
+)
+
+// ParseError is a parsing failure of one of the kinds above, keeping the message shown to users
+type ParseError struct {
+	Kind    error
+	Message string
+}
+
+// Error returns the message shown to users
+func (e *ParseError) Error() string {
+	return e.Message
+}
+
+// Unwrap returns the kind of the failure, so errors.Is matches it
+func (e *ParseError) Unwrap() error {
+	return e.Kind
+}
+
+// parseError formats a ParseError of the given kind
+func parseError(kind error, format string, args ...interface{}) error {
+	return &ParseError{Kind: kind, Message: fmt.Sprintf(format, args...)}
+}
+
+// aliasRegex validates provider alias references in "name.alias" form
+var aliasRegex = regexp.MustCompile(`^([a-zA-Z0-9_-]+)\.([a-zA-Z][a-zA-Z0-9_-]*)$`)
+
+// versionRegex validates comma-separated version constraints, allowing a space after the operator, so
+// disjoint ranges and exclusions such as ">= 3.0, < 4.0, != 3.5.0" pass through to versions.tf verbatim
+var versionRegex = regexp.MustCompile(`^((>=|<=|>|<|!=|~>|=)? ?\d+(\.\d+){0,2})(, ?(>=|<=|>|<|!=|~>|=)? ?\d+(\.\d+){0,2})*$`)
+
 // Parser encapsulates parsing logic with logging
 type Parser struct {
-	logger logging.Logger
+	logger         logging.Logger
+	defaultVersion string
+	singleRefStyle string
 }
 
 // NewParser creates a new Parser instance
 func NewParser(logger logging.Logger) *Parser {
-	return &Parser{logger: logger}
+	return &Parser{logger: logger, defaultVersion: DefaultProviderVersion, singleRefStyle: SingleRefBare}
+}
+
+// SetSingleRefStyle sets how single-mode resources reference their variables.
+// Only the bare style limits a run to one single-mode resource, as the others cannot collide.
+func (p *Parser) SetSingleRefStyle(style string) error {
+	switch style {
+	case SingleRefBare, SingleRefPrefixed, SingleRefObject:
+		p.singleRefStyle = style
+		return nil
+	}
+	return fmt.Errorf("invalid single-mode reference style: '%s'. Use '%s', '%s' or '%s'", style, SingleRefBare, SingleRefPrefixed, SingleRefObject)
+}
+
+// SetDefaultVersion sets the constraint used for providers specified without a version
+func (p *Parser) SetDefaultVersion(version string) error {
+	version = strings.TrimSpace(version)
+	if !versionRegex.MatchString(version) {
+		return parseError(ErrInvalidVersion, "invalid default provider version format: '%s'", version)
+	}
+	p.defaultVersion = version
+	return nil
 }
 
 // Provider struct to hold provider information
 type Provider struct {
-	Namespace      string
-	Name           string
-	Version        string
-	NamespaceLower string
-	NameLower      string
+	Namespace            string
+	Name                 string
+	Version              string
+	NamespaceLower       string
+	NameLower            string
+	ConfigurationAliases []string // Aliases the module expects (e.g., "west" for aws.west)
+	Source               string   // Lowercase namespace/name the provider is published under, if not Namespace/Name
+	LocalName            string   // Local name in the module when another provider shares NameLower (e.g., someorg-aws)
+}
+
+// Local returns the name the module refers to the provider by, in required_providers, provider blocks and
+// provider meta-arguments: its LocalName if it shares its name with another provider, otherwise NameLower
+func (p Provider) Local() string {
+	if p.LocalName != "" {
+		return p.LocalName
+	}
+	return p.NameLower
+}
+
+// SourceKey returns the lowercase namespace/name the provider is installed from: its source if it has one,
+// otherwise its own namespace and name
+func (p Provider) SourceKey() string {
+	if p.Source != "" {
+		return p.Source
+	}
+	return p.NamespaceLower + "/" + p.NameLower
+}
+
+// Address returns the fully qualified source address of the provider on the given registry host,
+// which is also its key in the schema JSON (e.g., registry.terraform.io/hashicorp/aws)
+func (p Provider) Address(host string) string {
+	return host + "/" + p.SourceKey()
 }
 
 // Resource struct to hold resource information with mode
 type Resource struct {
 	Name     string   // Resource name (e.g., "aws_vpc")
 	Mode     string   // Mode: "single" or "multiple"
+	Label    string   // Optional block label; empty means the default label
 	Provider Provider // Associated Provider
+
+	// Per-resource settings from a config file; empty values defer to the command-line options
+	KeyAttr   string   // Object attribute keying a multiple-mode list in for_each instead of name
+	Iteration string   // How a multiple-mode resource iterates: IterationForEach, IterationCount or IterationMap
+	Include   []string // Top-level attributes and blocks to generate, leaving out the others
+	Exclude   []string // Top-level attributes and blocks to leave out
+}
+
+// StateResource is a managed resource instance of the root module recorded in a state
+type StateResource struct {
+	Type  string      // Resource type (e.g., "aws_instance")
+	Name  string      // Block label in the state (e.g., "web")
+	Index interface{} // Instance key: nil, a number with count or a string with for_each
+}
+
+// Address returns the address of the state resource without its instance key
+func (r StateResource) Address() string {
+	return r.Type + "." + r.Name
+}
+
+// DefaultResourceLabel is the block label used for resources without a custom label
+const DefaultResourceLabel = "this"
+
+// BlockLabel returns the label of the generated resource block
+func (r Resource) BlockLabel() string {
+	if r.Label == "" {
+		return DefaultResourceLabel
+	}
+	return r.Label
+}
+
+// TypePrefix returns the provider prefix of the resource type, such as google for google-beta's
+// google_compute_instance, falling back to the part before the first underscore
+func (r Resource) TypePrefix() string {
+	if prefix := providerTypePrefix(r.Name, r.Provider); prefix != "" {
+		return prefix
+	}
+	prefix, _, _ := strings.Cut(r.Name, "_")
+	return prefix
+}
+
+// ImpliedProvider reports whether Terraform infers the resource's provider from the part of its type name
+// before the first underscore, which is not the case for hyphenated providers such as google-beta, nor
+// for providers renamed locally because they share their name with another
+func (r Resource) ImpliedProvider() bool {
+	prefix, _, _ := strings.Cut(r.Name, "_")
+	return r.Provider.NameLower == "" || prefix == r.Provider.Local()
+}
+
+// providerTypePrefix returns the prefix under which a provider's resource types are named: its name with
+// hyphens read as underscores, or the part of the name before a hyphen (google-beta's resources are google_*).
+// It returns an empty string if the resource type matches neither.
+func providerTypePrefix(resourceName string, provider Provider) string {
+	if provider.NameLower == "" {
+		return ""
+	}
+	base, _, _ := strings.Cut(provider.NameLower, "-")
+	for _, prefix := range []string{strings.ReplaceAll(provider.NameLower, "-", "_"), base} {
+		if resourceName == prefix || strings.HasPrefix(resourceName, prefix+"_") {
+			return prefix
+		}
+	}
+	return ""
 }
 
 // ParseProviderVersion parses the provider string to extract namespace, name, and optional version
 func (p *Parser) ParseProviderVersion(provider string) (Provider, error) {
-	// Regex to validate comma-separated version constraints
-	versionRegex := regexp.MustCompile(`^((>=|<=|>|<|!=|~>)?\d+(\.\d+){0,2})(, ?(>=|<=|>|<|!=|~>)?\d+(\.\d+){0,2})*$`)
-
 	// Split by colon to separate provider and optional version
 	parts := strings.Split(provider, ":")
 	if len(parts) == 0 || len(parts) > 2 {
-		return Provider{}, fmt.Errorf("invalid provider format, expected 'namespace/name[:version]'")
+		return Provider{}, parseError(ErrInvalidProviderFormat, "invalid provider format, expected 'namespace/name[:version]'")
+	}
+
+	// Split the local namespace/name from the source it is published under, if given
+	local, source, hasSource := strings.Cut(parts[0], "=")
+	source = strings.ToLower(strings.TrimSpace(source))
+	if hasSource {
+		sourceParts := strings.Split(source, "/")
+		if len(sourceParts) != 2 || strings.TrimSpace(sourceParts[0]) == "" || strings.TrimSpace(sourceParts[1]) == "" {
+			return Provider{}, parseError(ErrInvalidProviderFormat, "invalid provider source, expected 'namespace/name=source_namespace/source_name'")
+		}
 	}
 
 	// Split namespace and name
-	nsAndNameParts := strings.Split(strings.TrimSpace(parts[0]), "/")
+	nsAndNameParts := strings.Split(strings.TrimSpace(local), "/")
 	if len(nsAndNameParts) != 2 || strings.TrimSpace(nsAndNameParts[0]) == "" || strings.TrimSpace(nsAndNameParts[1]) == "" {
-		return Provider{}, fmt.Errorf("invalid provider format, expected 'namespace/name'")
+		return Provider{}, parseError(ErrInvalidProviderFormat, "invalid provider format, expected 'namespace/name'")
 	}
 
 	// Extract version if provided, otherwise use default
-	version := ">= 0"
+	version := p.defaultVersion
 	if len(parts) == 2 {
 		version = strings.TrimSpace(parts[1])
 		if version == "" || !versionRegex.MatchString(version) {
-			return Provider{}, fmt.Errorf("invalid version format: '%s'", version)
+			return Provider{}, parseError(ErrInvalidVersion, "invalid version format: '%s'", version)
 		}
 	}
 
@@ -69,6 +289,7 @@ func (p *Parser) ParseProviderVersion(provider string) (Provider, error) {
 		Version:        version,
 		NamespaceLower: strings.ToLower(strings.TrimSpace(nsAndNameParts[0])),
 		NameLower:      strings.ToLower(strings.TrimSpace(nsAndNameParts[1])),
+		Source:         source,
 	}, nil
 }
 
@@ -77,12 +298,13 @@ func (p *Parser) ParseProviders(providerPtrs []string) (map[string]Provider, err
 	providers := make(map[string]Provider)
 
 	// Define a regex pattern for validating provider format
-	providerRegex := regexp.MustCompile(`^[a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+(:[a-zA-Z0-9.<>=~_-]+)?$`)
+	providerRegex := regexp.MustCompile(`^[a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+(=[a-zA-Z0-9_-]+/[a-zA-Z0-9_-]+)?(:[a-zA-Z0-9.<>=!~_, -]+)?$`)
+	sources := make(map[string]string)
 
 	for _, providerStr := range providerPtrs {
 		// Validate the format using regex
 		if !providerRegex.MatchString(providerStr) {
-			return nil, fmt.Errorf("invalid provider format: '%s'. Expected format: 'namespace/name[:version]'", providerStr)
+			return nil, parseError(ErrInvalidProviderFormat, "invalid provider format: '%s'. Expected format: 'namespace/name[:version]'", providerStr)
 		}
 
 		// Parse the provider version (existing logic)
@@ -94,61 +316,818 @@ func (p *Parser) ParseProviders(providerPtrs []string) (map[string]Provider, err
 		// Generate a key for the provider (namespace/name)
 		providerKey := fmt.Sprintf("%s/%s", provider.NamespaceLower, provider.NameLower)
 
-		// Check for duplicate providers
+		// Check for duplicate providers, including two local providers installed from the same source
 		if _, exists := providers[providerKey]; exists {
-			return nil, fmt.Errorf("duplicate provider found: %s", providerKey)
+			return nil, parseError(ErrDuplicateProvider, "duplicate provider found: %s", providerKey)
+		}
+		if other, exists := sources[provider.SourceKey()]; exists {
+			return nil, parseError(ErrDuplicateProvider, "providers %s and %s share the source %s", other, providerKey, provider.SourceKey())
 		}
+		sources[provider.SourceKey()] = providerKey
 
 		// Add the parsed provider to the map
 		p.logger.Log("debug", "Parsed provider: %s", providerKey)
 		providers[providerKey] = provider
 	}
 
+	if err := assignLocalNames(providers); err != nil {
+		return nil, err
+	}
 	return providers, nil
 }
 
+// assignLocalNames gives providers that share their name, such as hashicorp/aws and a fork in someorg/aws,
+// the local names namespace-name, since required_providers and provider references key them by local name
+func assignLocalNames(providers map[string]Provider) error {
+	byName := make(map[string][]string)
+	for key, provider := range providers {
+		byName[provider.NameLower] = append(byName[provider.NameLower], key)
+	}
+
+	locals := make(map[string]string, len(providers))
+	keys := make([]string, 0, len(providers))
+	for key := range providers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		provider := providers[key]
+		if len(byName[provider.NameLower]) > 1 {
+			provider.LocalName = provider.NamespaceLower + "-" + provider.NameLower
+			providers[key] = provider
+		}
+		if other, exists := locals[provider.Local()]; exists {
+			return parseError(ErrDuplicateProvider, "providers %s and %s share the local name %s", other, key, provider.Local())
+		}
+		locals[provider.Local()] = key
+	}
+	return nil
+}
+
+// ParseProviderAliases parses "name.alias" strings and attaches them as configuration aliases to the matching providers
+func (p *Parser) ParseProviderAliases(aliasPtrs []string, providers map[string]Provider) error {
+	for _, aliasStr := range aliasPtrs {
+		matches := aliasRegex.FindStringSubmatch(strings.TrimSpace(aliasStr))
+		if matches == nil {
+			return fmt.Errorf("invalid provider alias format: '%s'. Expected format: 'name.alias'", aliasStr)
+		}
+		name, alias := strings.ToLower(matches[1]), matches[2]
+
+		// Find the declared provider by its local name
+		providerKey := ""
+		for key, provider := range providers {
+			if provider.Local() == name {
+				providerKey = key
+				break
+			}
+		}
+		if providerKey == "" {
+			return fmt.Errorf("provider alias '%s' refers to undeclared provider: %s", aliasStr, name)
+		}
+
+		provider := providers[providerKey]
+		for _, existing := range provider.ConfigurationAliases {
+			if existing == alias {
+				return fmt.Errorf("duplicate provider alias found: %s.%s", name, alias)
+			}
+		}
+		provider.ConfigurationAliases = append(provider.ConfigurationAliases, alias)
+		providers[providerKey] = provider
+
+		p.logger.Log("debug", "Parsed provider alias: %s.%s", name, alias)
+	}
+
+	return nil
+}
+
+// ParseResourceProviderAliases parses "resource=name.alias" strings into a map of resource names to
+// provider references, checking that the alias is declared for the resource's provider
+func (p *Parser) ParseResourceProviderAliases(aliasPtrs []string, resources []Resource) (map[string]string, error) {
+	resourceProviders := make(map[string]string, len(aliasPtrs))
+
+	for _, aliasStr := range aliasPtrs {
+		name, reference, found := strings.Cut(aliasStr, "=")
+		name, reference = strings.TrimSpace(name), strings.TrimSpace(reference)
+		matches := aliasRegex.FindStringSubmatch(reference)
+		if !found || name == "" || matches == nil {
+			return nil, fmt.Errorf("invalid resource provider alias format: '%s'. Expected format: 'resource=name.alias'", aliasStr)
+		}
+		providerName, alias := strings.ToLower(matches[1]), matches[2]
+
+		var resource *Resource
+		for i := range resources {
+			if resources[i].Name == name {
+				resource = &resources[i]
+				break
+			}
+		}
+		if resource == nil {
+			return nil, fmt.Errorf("provider alias given for undeclared resource: %s", name)
+		}
+		if resource.Provider.Local() != providerName {
+			return nil, fmt.Errorf("provider alias %s does not belong to the provider of resource %s", reference, name)
+		}
+		if !slices.Contains(resource.Provider.ConfigurationAliases, alias) {
+			return nil, fmt.Errorf("provider alias %s is not declared; add it with --provider-alias %s.%s", reference, providerName, alias)
+		}
+		if _, exists := resourceProviders[name]; exists {
+			return nil, fmt.Errorf("duplicate provider alias for resource: %s", name)
+		}
+
+		resourceProviders[name] = providerName + "." + alias
+		p.logger.Log("debug", "Resource %s uses provider %s.%s", name, providerName, alias)
+	}
+
+	return resourceProviders, nil
+}
+
+// ParsePerKeyProviders parses "resource=name.alias" strings into a map of resource names to provider
+// references indexed by each.key, for multiple mode resources fanned out over a keyed provider alias
+func (p *Parser) ParsePerKeyProviders(aliasPtrs []string, resources []Resource) (map[string]string, error) {
+	perKeyProviders, err := p.ParseResourceProviderAliases(aliasPtrs, resources)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, resource := range resources {
+		if _, exists := perKeyProviders[resource.Name]; exists && resource.Mode != "multiple" {
+			return nil, fmt.Errorf("per-key providers require multiple mode, but resource '%s' is in %s mode", resource.Name, resource.Mode)
+		}
+	}
+
+	return perKeyProviders, nil
+}
+
+// ParseDevOverrides parses "namespace/name=/path/to/plugin/dir" strings into a map of provider source
+// addresses to absolute plugin directories, clearing the version constraint of each overridden provider.
+// A provider is named by its local name or its source; Terraform matches dev_overrides by source, so a
+// renamed provider (--provider hashicorp/aws=myorg/aws-fork) is keyed by myorg/aws-fork.
+func (p *Parser) ParseDevOverrides(overridePtrs []string, providers map[string]Provider) (map[string]string, error) {
+	overrides := make(map[string]string, len(overridePtrs))
+
+	for _, overrideStr := range overridePtrs {
+		providerKey, dir, found := strings.Cut(overrideStr, "=")
+		providerKey, dir = strings.ToLower(strings.TrimSpace(providerKey)), strings.TrimSpace(dir)
+		if !found || providerKey == "" || dir == "" {
+			return nil, fmt.Errorf("invalid dev override format: '%s'. Expected format: 'namespace/name=/path/to/plugin/dir'", overrideStr)
+		}
+
+		provider, exists := providers[providerKey]
+		if !exists {
+			for key, candidate := range providers {
+				if candidate.SourceKey() == providerKey {
+					providerKey, provider, exists = key, candidate, true
+					break
+				}
+			}
+		}
+		if !exists {
+			return nil, fmt.Errorf("dev override given for undeclared provider: %s", providerKey)
+		}
+		source := provider.SourceKey()
+		if _, exists := overrides[source]; exists {
+			return nil, fmt.Errorf("duplicate dev override for provider: %s", providerKey)
+		}
+
+		absDir, err := filepath.Abs(dir)
+		if err != nil {
+			return nil, fmt.Errorf("invalid plugin directory for provider %s: %w", providerKey, err)
+		}
+		overrides[source] = absDir
+
+		// A local build has no registry version to pin
+		provider.Version = ""
+		providers[providerKey] = provider
+		p.logger.Log("debug", "Using local plugin directory %s for provider %s", absDir, source)
+	}
+
+	return overrides, nil
+}
+
+// ParseLockFile parses a .terraform.lock.hcl file into a map of "namespace/name" keys to locked versions
+func (p *Parser) ParseLockFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read lock file %s: %w", path, err)
+	}
+
+	file, diags := hclsyntax.ParseConfig(content, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse lock file %s: %s", path, diags.Error())
+	}
+
+	body, ok := file.Body.(*hclsyntax.Body)
+	if !ok {
+		return nil, fmt.Errorf("unexpected body type in lock file %s", path)
+	}
+
+	locked := make(map[string]string)
+	for _, block := range body.Blocks {
+		if block.Type != "provider" || len(block.Labels) != 1 {
+			continue
+		}
+
+		// Strip the registry host from the source (e.g., registry.terraform.io/hashicorp/aws)
+		sourceParts := strings.Split(strings.ToLower(block.Labels[0]), "/")
+		if len(sourceParts) < 2 {
+			return nil, fmt.Errorf("invalid provider source in lock file: %s", block.Labels[0])
+		}
+		providerKey := strings.Join(sourceParts[len(sourceParts)-2:], "/")
+
+		versionAttr, exists := block.Body.Attributes["version"]
+		if !exists {
+			return nil, fmt.Errorf("missing version for provider %s in lock file", block.Labels[0])
+		}
+		value, diags := versionAttr.Expr.Value(nil)
+		if diags.HasErrors() || value.Type() != cty.String {
+			return nil, fmt.Errorf("invalid version for provider %s in lock file", block.Labels[0])
+		}
+
+		locked[providerKey] = value.AsString()
+		p.logger.Log("debug", "Parsed locked provider: %s = %s", providerKey, value.AsString())
+	}
+
+	return locked, nil
+}
+
+// ApplyLockedVersions replaces the version constraints of the given providers with their locked versions
+func (p *Parser) ApplyLockedVersions(providers map[string]Provider, locked map[string]string) {
+	keys := make(map[string]string, len(providers))
+	for providerKey, provider := range providers {
+		keys[provider.SourceKey()] = providerKey
+	}
+
+	for source, version := range locked {
+		providerKey, exists := keys[source]
+		if !exists {
+			p.logger.Log("debug", "Ignoring locked provider not requested via --provider: %s", source)
+			continue
+		}
+
+		provider := providers[providerKey]
+		p.logger.Log("info", "Using locked version %s for provider %s (was %s)", version, providerKey, provider.Version)
+		provider.Version = version
+		providers[providerKey] = provider
+	}
+}
+
+// ParseRegistryHost validates the registry hostname that provider sources resolve to, lowercased as
+// Terraform normalizes it
+func (p *Parser) ParseRegistryHost(host string) (string, error) {
+	host = strings.ToLower(strings.TrimSpace(host))
+	if host == "" || strings.ContainsAny(host, "/ \t") {
+		return "", fmt.Errorf("invalid registry host: '%s'. Expected a hostname such as '%s'", host, DefaultRegistryHost)
+	}
+	p.logger.Log("debug", "Parsed registry host: %s", host)
+	return host, nil
+}
+
+// ParseFileMode parses an octal permission mode such as "0640" for the generated files. The owner must be
+// able to read and write them, as generated files are rewritten during the run (by terraform fmt, after
+// removing invalid attributes and when pinning resolved versions).
+func (p *Parser) ParseFileMode(mode string) (os.FileMode, error) {
+	value, err := strconv.ParseUint(strings.TrimSpace(mode), 8, 32)
+	if err != nil || value > 0777 {
+		return 0, fmt.Errorf("invalid file mode: '%s'. Expected an octal mode such as '0644'", mode)
+	}
+	if value&0600 != 0600 {
+		return 0, fmt.Errorf("invalid file mode: '%s'. The owner needs read and write permission (0600), as generated files are rewritten during the run", mode)
+	}
+	p.logger.Log("debug", "Parsed file mode: %04o", value)
+	return os.FileMode(value), nil
+}
+
+// ParseBackend validates the backend to scaffold in versions.tf; an empty name means no backend
+func (p *Parser) ParseBackend(name string) (string, error) {
+	backend := strings.ToLower(strings.TrimSpace(name))
+	switch backend {
+	case "", BackendLocal, BackendS3, BackendCloud:
+		p.logger.Log("debug", "Parsed backend: %s", backend)
+		return backend, nil
+	}
+	return "", fmt.Errorf("invalid backend: '%s'. Use '%s', '%s' or '%s'", name, BackendLocal, BackendS3, BackendCloud)
+}
+
+// ParseBackendConfig parses "attribute=value" strings into the HCL expressions set on the scaffolded
+// backend block. A value that is a constant expression, such as true, 3, "text" or { name = "prod" }, is
+// kept as it is; anything else, such as my-state or eu-west-1, is quoted as a string. Names are only
+// checked to be identifiers, as each backend takes its own attributes.
+func (p *Parser) ParseBackendConfig(configPtrs []string) (map[string]string, error) {
+	config := make(map[string]string, len(configPtrs))
+
+	for _, pair := range configPtrs {
+		name, value, found := strings.Cut(pair, "=")
+		name = strings.TrimSpace(name)
+		if !found || name == "" {
+			return nil, fmt.Errorf("invalid backend config format: '%s'. Expected format: 'attribute=value'", pair)
+		}
+		if !hclsyntax.ValidIdentifier(name) {
+			return nil, fmt.Errorf("invalid backend config attribute name: '%s'", name)
+		}
+		if _, exists := config[name]; exists {
+			return nil, fmt.Errorf("duplicate backend config attribute: %s", name)
+		}
+
+		config[name] = backendExpression(strings.TrimSpace(value))
+		p.logger.Log("debug", "Parsed backend config: %s = %s", name, config[name])
+	}
+
+	return config, nil
+}
+
+// backendExpression returns value if it parses as an HCL expression without references, which backends
+// cannot evaluate, and value quoted as a string otherwise
+func backendExpression(value string) string {
+	expr, diags := hclsyntax.ParseExpression([]byte(value), "backend-config", hcl.InitialPos)
+	if value == "" || diags.HasErrors() || len(expr.Variables()) > 0 {
+		return string(hclwrite.TokensForValue(cty.StringVal(value)).Bytes())
+	}
+	return value
+}
+
+// ParseBackendFile validates the name of the file the backend block is written to instead of versions.tf;
+// an empty name keeps it in versions.tf
+func (p *Parser) ParseBackendFile(name string) (string, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", nil
+	}
+	if filepath.Base(name) != name || filepath.Ext(name) != ".tf" || name == "versions.tf" {
+		return "", fmt.Errorf("invalid backend file: '%s'. Expected a .tf file name other than versions.tf, such as backend.tf", name)
+	}
+	p.logger.Log("debug", "Parsed backend file: %s", name)
+	return name, nil
+}
+
+// ParseOptionalBlockDefault validates the default of optional single-mode nested block variables
+func (p *Parser) ParseOptionalBlockDefault(value string) (string, error) {
+	switch value {
+	case OptionalBlockDefaultNull, OptionalBlockDefaultNone:
+		p.logger.Log("debug", "Parsed optional block default: %s", value)
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid optional block default: '%s'. Use '%s' or '%s'", value, OptionalBlockDefaultNull, OptionalBlockDefaultNone)
+}
+
+// ParseAttributeCase validates the case of the variable names derived from attribute names
+func (p *Parser) ParseAttributeCase(value string) (string, error) {
+	switch value {
+	case AttributeCaseSnake, AttributeCasePreserve:
+		p.logger.Log("debug", "Parsed attribute case: %s", value)
+		return value, nil
+	}
+	return "", fmt.Errorf("invalid attribute case: '%s'. Use '%s' or '%s'", value, AttributeCaseSnake, AttributeCasePreserve)
+}
+
+// ParseKeyMode validates how multiple-mode list variables are keyed in for_each
+func (p *Parser) ParseKeyMode(mode string) (string, error) {
+	switch mode {
+	case KeyModeName, KeyModeIndex, KeyModeCoalesce:
+		p.logger.Log("debug", "Parsed key mode: %s", mode)
+		return mode, nil
+	}
+	return "", fmt.Errorf("invalid key mode: '%s'. Use '%s', '%s' or '%s'", mode, KeyModeName, KeyModeIndex, KeyModeCoalesce)
+}
+
+// ParseDefaultsFile parses a JSON file mapping "resource.attribute" keys to default values
+func (p *Parser) ParseDefaultsFile(path string) (map[string]cty.Value, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read defaults file %s: %w", path, err)
+	}
+
+	var rawDefaults map[string]json.RawMessage
+	if err := json.Unmarshal(content, &rawDefaults); err != nil {
+		return nil, fmt.Errorf("failed to parse defaults file %s: %w", path, err)
+	}
+
+	defaults := make(map[string]cty.Value, len(rawDefaults))
+	for key, raw := range rawDefaults {
+		parts := strings.Split(key, ".")
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("invalid defaults key '%s'. Expected format: 'resource.attribute'", key)
+		}
+
+		// Infer the value's type from its JSON representation
+		valueType, err := ctyjson.ImpliedType(raw)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value for '%s': %w", key, err)
+		}
+		value, err := ctyjson.Unmarshal(raw, valueType)
+		if err != nil {
+			return nil, fmt.Errorf("invalid default value for '%s': %w", key, err)
+		}
+
+		defaults[key] = value
+		p.logger.Log("debug", "Parsed default for %s", key)
+	}
+
+	return defaults, nil
+}
+
+// ParseDescriptionsFile reads a JSON file mapping "resource.attribute" (or "resource.block.attribute") paths
+// to variable descriptions
+func (p *Parser) ParseDescriptionsFile(path string) (map[string]string, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read descriptions file %s: %w", path, err)
+	}
+
+	var descriptions map[string]string
+	if err := json.Unmarshal(content, &descriptions); err != nil {
+		return nil, fmt.Errorf("failed to parse descriptions file %s: %w", path, err)
+	}
+
+	for key := range descriptions {
+		if !strings.Contains(key, ".") || strings.HasPrefix(key, ".") || strings.HasSuffix(key, ".") {
+			return nil, fmt.Errorf("invalid descriptions key '%s'. Expected format: 'resource.attribute'", key)
+		}
+		p.logger.Log("debug", "Parsed description for %s", key)
+	}
+
+	return descriptions, nil
+}
+
+// ParseStateFile reads the managed resources of the root module from "terraform show -json" output
+func (p *Parser) ParseStateFile(path string) ([]StateResource, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read state file %s: %w", path, err)
+	}
+
+	var state tfjson.State
+	if err := json.Unmarshal(content, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse state file %s: %w", path, err)
+	}
+
+	resources := []StateResource{}
+	if state.Values == nil || state.Values.RootModule == nil {
+		p.logger.Log("warn", "State file %s has no resources", path)
+		return resources, nil
+	}
+	for _, child := range state.Values.RootModule.ChildModules {
+		p.logger.Log("warn", "Ignoring the resources of %s in state file %s; only root module resources are moved", child.Address, path)
+	}
+	for _, resource := range state.Values.RootModule.Resources {
+		if resource == nil || resource.Mode != tfjson.ManagedResourceMode {
+			continue
+		}
+		resources = append(resources, StateResource{Type: resource.Type, Name: resource.Name, Index: resource.Index})
+		p.logger.Log("debug", "Parsed state resource: %s", resource.Address)
+	}
+
+	return resources, nil
+}
+
+// ParseForEachMap validates the resources that should iterate over a map variable, which must be in multiple mode
+func (p *Parser) ParseForEachMap(resourceNames []string, resources []Resource) (map[string]bool, error) {
+	forEachMap := make(map[string]bool, len(resourceNames))
+
+	for _, name := range resourceNames {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, resource := range resources {
+			if resource.Name != name {
+				continue
+			}
+			if resource.Mode != "multiple" {
+				return nil, fmt.Errorf("for_each map requires multiple mode, but resource '%s' is in %s mode", name, resource.Mode)
+			}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("for_each map given for undeclared resource: %s", name)
+		}
+
+		forEachMap[name] = true
+		p.logger.Log("debug", "Resource %s iterates over a map variable", name)
+	}
+
+	return forEachMap, nil
+}
+
+// nullDefaultKeywords maps each primitive type to the keywords it accepts in --null-default-types
+// and the literal each keyword stands for
+var nullDefaultKeywords = map[string]map[string]string{
+	"string": {"empty": `""`, "null": "null"},
+	"number": {"zero": "0", "null": "null"},
+	"bool":   {"false": "false", "true": "true", "null": "null"},
+}
+
+// ParseNullDefaultTypes parses a "type=keyword[,type=keyword...]" mapping (e.g., "string=empty,number=zero")
+// into a map of primitive types to the literal used as the default of optional attributes
+func (p *Parser) ParseNullDefaultTypes(spec string) (map[string]string, error) {
+	defaults := make(map[string]string)
+	if strings.TrimSpace(spec) == "" {
+		return defaults, nil
+	}
+
+	for _, pair := range strings.Split(spec, ",") {
+		typeName, keyword, found := strings.Cut(pair, "=")
+		typeName, keyword = strings.TrimSpace(typeName), strings.TrimSpace(keyword)
+		if !found || typeName == "" || keyword == "" {
+			return nil, fmt.Errorf("invalid null default format: '%s'. Expected format: 'type=keyword'", pair)
+		}
+
+		keywords, exists := nullDefaultKeywords[typeName]
+		if !exists {
+			return nil, fmt.Errorf("unsupported type for null default: %s. Use string, number or bool", typeName)
+		}
+		literal, exists := keywords[keyword]
+		if !exists {
+			return nil, fmt.Errorf("unsupported null default keyword for %s: %s", typeName, keyword)
+		}
+		if _, exists := defaults[typeName]; exists {
+			return nil, fmt.Errorf("duplicate null default for type: %s", typeName)
+		}
+
+		defaults[typeName] = literal
+		p.logger.Log("debug", "Optional %s attributes default to %s", typeName, literal)
+	}
+
+	return defaults, nil
+}
+
+// ParseTagAttributes parses a comma-separated list of candidate tag attribute names (e.g., "tags,labels")
+func (p *Parser) ParseTagAttributes(spec string) ([]string, error) {
+	names := []string{}
+	for _, name := range strings.Split(spec, ",") {
+		name = strings.TrimSpace(name)
+		if !hclsyntax.ValidIdentifier(name) {
+			return nil, fmt.Errorf("invalid tag attribute name: '%s'", name)
+		}
+		if slices.Contains(names, name) {
+			return nil, fmt.Errorf("duplicate tag attribute name: %s", name)
+		}
+		names = append(names, name)
+	}
+	p.logger.Log("debug", "Parsed tag attributes: %v", names)
+	return names, nil
+}
+
+// ParseToggleable validates that the named resources exist in single mode and returns them as a set
+func (p *Parser) ParseToggleable(resourceNames []string, resources []Resource) (map[string]bool, error) {
+	toggleable := make(map[string]bool, len(resourceNames))
+
+	for _, name := range resourceNames {
+		name = strings.TrimSpace(name)
+		found := false
+		for _, resource := range resources {
+			if resource.Name != name {
+				continue
+			}
+			if resource.Mode != "single" {
+				return nil, fmt.Errorf("toggleable resources require single mode, but resource '%s' is in %s mode", name, resource.Mode)
+			}
+			found = true
+		}
+		if !found {
+			return nil, fmt.Errorf("toggleable given for undeclared resource: %s", name)
+		}
+
+		toggleable[name] = true
+		p.logger.Log("debug", "Resource %s is toggled by an enabled variable", name)
+	}
+
+	return toggleable, nil
+}
+
+// ParseResourceSettings validates the per-resource settings of a config file and returns the resource
+// carrying them. Iteration and key attribute only apply to multiple mode, and include and exclude are
+// alternatives.
+func (p *Parser) ParseResourceSettings(resource Resource, keyAttr, iteration string, include, exclude []string) (Resource, error) {
+	address := resource.Name + "." + resource.BlockLabel()
+
+	switch iteration {
+	case "", IterationForEach, IterationCount, IterationMap:
+	default:
+		return resource, fmt.Errorf("invalid iteration for resource '%s': '%s'. Use '%s', '%s' or '%s'", address, iteration, IterationForEach, IterationCount, IterationMap)
+	}
+	if (iteration != "" || keyAttr != "") && resource.Mode != "multiple" {
+		return resource, fmt.Errorf("iteration and key_attr require multiple mode, but resource '%s' is in %s mode", address, resource.Mode)
+	}
+	if keyAttr != "" {
+		if !hclsyntax.ValidIdentifier(keyAttr) {
+			return resource, fmt.Errorf("invalid key_attr for resource '%s': '%s'", address, keyAttr)
+		}
+		if iteration == IterationCount {
+			return resource, fmt.Errorf("key_attr of resource '%s' has no effect with count iteration", address)
+		}
+	}
+	if len(include) > 0 && len(exclude) > 0 {
+		return resource, fmt.Errorf("resource '%s' sets both include and exclude; use one of them", address)
+	}
+	for _, name := range append(slices.Clone(include), exclude...) {
+		if !hclsyntax.ValidIdentifier(name) {
+			return resource, fmt.Errorf("invalid attribute name in include or exclude of resource '%s': '%s'", address, name)
+		}
+	}
+
+	resource.KeyAttr = keyAttr
+	resource.Iteration = iteration
+	resource.Include = include
+	resource.Exclude = exclude
+	p.logger.Log("debug", "Parsed settings of resource %s: iteration '%s', key_attr '%s', include %v, exclude %v", address, iteration, keyAttr, include, exclude)
+	return resource, nil
+}
+
+// ParseResourceAliases parses "resource=alias" strings into a map of resource names to the alias their
+// variables are named after. The resource type must be declared once, so the alias names one resource.
+func (p *Parser) ParseResourceAliases(aliasPtrs []string, resources []Resource) (map[string]string, error) {
+	aliases := make(map[string]string, len(aliasPtrs))
+	used := make(map[string]string, len(aliasPtrs))
+
+	for _, aliasStr := range aliasPtrs {
+		name, alias, found := strings.Cut(aliasStr, "=")
+		name, alias = strings.TrimSpace(name), strings.TrimSpace(alias)
+		if !found || name == "" || alias == "" {
+			return nil, fmt.Errorf("invalid resource alias format: '%s'. Expected format: 'resource=alias'", aliasStr)
+		}
+		if !hclsyntax.ValidIdentifier(alias) {
+			return nil, fmt.Errorf("invalid resource alias for '%s': %s", name, alias)
+		}
+		switch count := len(slices.DeleteFunc(slices.Clone(resources), func(resource Resource) bool { return resource.Name != name })); {
+		case count == 0:
+			return nil, fmt.Errorf("resource alias given for undeclared resource: %s", name)
+		case count > 1:
+			return nil, fmt.Errorf("resource alias for '%s' is ambiguous, as the resource is declared %d times", name, count)
+		}
+		if _, exists := aliases[name]; exists {
+			return nil, fmt.Errorf("duplicate resource alias for resource: %s", name)
+		}
+		if other, exists := used[alias]; exists {
+			return nil, fmt.Errorf("resource alias %s is given to both %s and %s", alias, other, name)
+		}
+
+		aliases[name] = alias
+		used[alias] = name
+		p.logger.Log("debug", "Parsed resource alias for %s: %s", name, alias)
+	}
+
+	return aliases, nil
+}
+
+// ParseExtraHCL parses "resource=<hcl>" strings into a map of resource names to raw HCL snippets,
+// checking that each snippet is a syntactically valid block body
+func (p *Parser) ParseExtraHCL(extraPtrs []string, resources []Resource) (map[string][]string, error) {
+	extraHCL := make(map[string][]string, len(extraPtrs))
+
+	for _, extraStr := range extraPtrs {
+		name, snippet, found := strings.Cut(extraStr, "=")
+		name, snippet = strings.TrimSpace(name), strings.TrimSpace(snippet)
+		if !found || name == "" || snippet == "" {
+			return nil, fmt.Errorf("invalid extra HCL format: '%s'. Expected format: 'resource=<hcl>'", extraStr)
+		}
+		if !slices.ContainsFunc(resources, func(resource Resource) bool { return resource.Name == name }) {
+			return nil, fmt.Errorf("extra HCL given for undeclared resource: %s", name)
+		}
+		if _, diags := hclsyntax.ParseConfig([]byte(snippet), "extra-hcl", hcl.InitialPos); diags.HasErrors() {
+			return nil, fmt.Errorf("invalid extra HCL for '%s': %s", name, diags.Error())
+		}
+
+		extraHCL[name] = append(extraHCL[name], snippet)
+		p.logger.Log("debug", "Parsed extra HCL for resource %s: %s", name, snippet)
+	}
+
+	return extraHCL, nil
+}
+
+// Precondition is a lifecycle precondition added to a generated resource block
+type Precondition struct {
+	Condition    string
+	ErrorMessage string
+}
+
+// ParsePreconditions parses "type.label:condition:error message" strings into a map of resource addresses to
+// preconditions. The condition ends at the last colon, so it may contain colons of its own.
+func (p *Parser) ParsePreconditions(preconditionPtrs []string, resources []Resource) (map[string][]Precondition, error) {
+	preconditions := make(map[string][]Precondition, len(preconditionPtrs))
+
+	for _, preconditionStr := range preconditionPtrs {
+		address, rest, found := strings.Cut(preconditionStr, ":")
+		separator := strings.LastIndex(rest, ":")
+		if !found || separator == -1 {
+			return nil, fmt.Errorf("invalid precondition format: '%s'. Expected format: 'type.label:condition:error message'", preconditionStr)
+		}
+		address = strings.TrimSpace(address)
+		condition, message := strings.TrimSpace(rest[:separator]), strings.TrimSpace(rest[separator+1:])
+		if address == "" || condition == "" || message == "" {
+			return nil, fmt.Errorf("invalid precondition format: '%s'. Expected format: 'type.label:condition:error message'", preconditionStr)
+		}
+		if !slices.ContainsFunc(resources, func(resource Resource) bool { return resource.Name+"."+resource.BlockLabel() == address }) {
+			return nil, fmt.Errorf("precondition given for undeclared resource: %s", address)
+		}
+		if _, diags := hclsyntax.ParseExpression([]byte(condition), "precondition", hcl.InitialPos); diags.HasErrors() {
+			return nil, fmt.Errorf("invalid precondition condition for '%s': %s", address, diags.Error())
+		}
+
+		preconditions[address] = append(preconditions[address], Precondition{Condition: condition, ErrorMessage: message})
+		p.logger.Log("debug", "Parsed precondition for resource %s: %s", address, condition)
+	}
+
+	return preconditions, nil
+}
+
+// ParseTypeOverrides parses "resource.attribute=type" strings into a map of attribute paths to type expressions
+func (p *Parser) ParseTypeOverrides(overridePtrs []string) (map[string]string, error) {
+	overrides := make(map[string]string, len(overridePtrs))
+
+	for _, overrideStr := range overridePtrs {
+		path, typeExpr, found := strings.Cut(overrideStr, "=")
+		path, typeExpr = strings.TrimSpace(path), strings.TrimSpace(typeExpr)
+		if !found || path == "" || typeExpr == "" || !strings.Contains(path, ".") {
+			return nil, fmt.Errorf("invalid type override format: '%s'. Expected format: 'resource.attribute=type'", overrideStr)
+		}
+
+		// Check that the type is a plausible HCL type constraint
+		expr, diags := hclsyntax.ParseExpression([]byte(typeExpr), "type-override", hcl.InitialPos)
+		if diags.HasErrors() {
+			return nil, fmt.Errorf("invalid type expression for '%s': %s", path, diags.Error())
+		}
+		if _, _, diags := typeexpr.TypeConstraintWithDefaults(expr); diags.HasErrors() {
+			return nil, fmt.Errorf("invalid type expression for '%s': %s", path, diags.Error())
+		}
+
+		if _, exists := overrides[path]; exists {
+			return nil, fmt.Errorf("duplicate type override found: %s", path)
+		}
+		overrides[path] = typeExpr
+		p.logger.Log("debug", "Parsed type override: %s = %s", path, typeExpr)
+	}
+
+	return overrides, nil
+}
+
 // ParseResources parses and validates resource strings into a slice of Resource structs
 func (p *Parser) ParseResources(resourcePtrs []string, providers map[string]Provider) ([]Resource, error) {
 	resources := []Resource{}
-	singleModeCount := 0 // Counter for resources with "single" mode
+	singleModeType := "" // Type of the resources with "single" mode
+	addresses := make(map[string]bool)
 
 	for _, resourceStr := range resourcePtrs {
-		parts := strings.Split(resourceStr, ":")
+		// A provider key before "::" binds the resource to that provider instead of guessing from its prefix.
+		// The slash tells it apart from "resource::label", which leaves the mode at its default.
+		qualifier, spec, qualified := strings.Cut(resourceStr, "::")
+		if !qualified || !strings.Contains(qualifier, "/") {
+			qualifier, spec, qualified = "", resourceStr, false
+		}
+		parts := strings.Split(spec, ":")
+		if len(parts) > 3 {
+			return nil, parseError(ErrInvalidResourceFormat, "invalid resource format: '%s'. Expected format: '[namespace/provider::]resource[:mode[:label]]'", resourceStr)
+		}
 		name := parts[0]
 		mode := "multiple" // Default mode
-		if len(parts) > 1 {
+		if len(parts) > 1 && parts[1] != "" {
 			mode = parts[1]
 		}
+		label := ""
+		if len(parts) > 2 {
+			label = parts[2]
+			if !hclsyntax.ValidIdentifier(label) {
+				return nil, parseError(ErrInvalidResourceFormat, "invalid label for resource '%s': %s", name, label)
+			}
+		}
 
 		if mode != "single" && mode != "multiple" {
-			return nil, fmt.Errorf("invalid mode for resource '%s': %s. Use 'single' or 'multiple'", name, mode)
+			return nil, parseError(ErrInvalidResourceFormat, "invalid mode for resource '%s': %s. Use 'single' or 'multiple'", name, mode)
 		}
 
-		if mode == "single" {
-			singleModeCount++
-			if singleModeCount > 1 {
-				return nil, fmt.Errorf("only one resource of type 'single' is supported, due to potentially conflicting variable names")
+		// Bare variables of different types could conflict; the same type under different labels
+		// gets label-prefixed variables instead
+		if mode == "single" && p.singleRefStyle == SingleRefBare {
+			if singleModeType != "" && singleModeType != name {
+				return nil, parseError(ErrSingleModeConflict, "only one resource of type 'single' is supported, due to potentially conflicting variable names")
 			}
+			singleModeType = name
 		}
 
-		// Identify provider for the resource based on naming convention
-		var associatedProvider Provider
-		for _, provider := range providers {
-			if strings.HasPrefix(name, provider.NameLower) {
-				associatedProvider = provider
-				break
+		// Identify provider for the resource based on naming convention, unless it was named explicitly
+		associatedProvider := matchProvider(name, providers)
+		if qualified {
+			provider, exists := providers[strings.ToLower(qualifier)]
+			if !exists {
+				return nil, parseError(ErrNoMatchingProvider, "provider '%s' of resource '%s' is not declared", qualifier, name)
 			}
+			associatedProvider = provider
 		}
 
-		if (Provider{}) == associatedProvider {
-			return nil, fmt.Errorf("no matching provider found for resource: %s", name)
+		if associatedProvider.Name == "" {
+			return nil, parseError(ErrNoMatchingProvider, "no matching provider found for resource: %s", name)
 		}
 
 		resource := Resource{
 			Name:     name,
 			Mode:     mode,
+			Label:    label,
 			Provider: associatedProvider,
 		}
+
+		// Terraform rejects two resource blocks with the same type and label
+		address := resource.Name + "." + resource.BlockLabel()
+		if addresses[address] {
+			return nil, parseError(ErrDuplicateResource, "duplicate resource address: %s. Give one of them a different label (e.g., %s:%s:other)", address, resource.Name, resource.Mode)
+		}
+		addresses[address] = true
 		resources = append(resources, resource)
 
 		p.logger.Log("debug", "Parsed resource: %s with mode: %s, associated provider: %+v", name, mode, associatedProvider)
@@ -157,6 +1136,55 @@ func (p *Parser) ParseResources(resourcePtrs []string, providers map[string]Prov
 	return resources, nil
 }
 
+// matchProvider returns the provider whose type prefix the resource name carries, preferring the provider whose
+// full name is the type prefix (google over google-beta for google_* resources)
+func matchProvider(name string, providers map[string]Provider) Provider {
+	var associatedProvider Provider
+	providerKeys := make([]string, 0, len(providers))
+	for key := range providers {
+		providerKeys = append(providerKeys, key)
+	}
+	sort.Strings(providerKeys)
+	bestMatch := 0
+	for _, key := range providerKeys {
+		provider := providers[key]
+		match := 0
+		switch providerTypePrefix(name, provider) {
+		case "":
+			continue
+		case strings.ReplaceAll(provider.NameLower, "-", "_"):
+			match = 2
+		default:
+			match = 1
+		}
+		if match > bestMatch {
+			associatedProvider, bestMatch = provider, match
+		}
+	}
+	return associatedProvider
+}
+
+// UnusedProviders returns the sorted keys of the declared providers that no resource belongs to
+func (p *Parser) UnusedProviders(providers map[string]Provider, resources []Resource) []string {
+	used := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		used[resource.Provider.NamespaceLower+"/"+resource.Provider.NameLower] = true
+	}
+
+	unused := []string{}
+	for key := range providers {
+		if !used[key] {
+			unused = append(unused, key)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// snippetAttributeRegex matches the attribute assigned at the start of a code snippet, such as user_data in
+// "user_data = <<EOF", but not heredoc content or comparisons such as "a == b"
+var snippetAttributeRegex = regexp.MustCompile(`^([a-zA-Z_][a-zA-Z0-9_-]*)[ \t]*=(?:[^=]|$)`)
+
 // ParseValidationErrorsFromJSON parses validation errors from terraform validate JSON output
 func (p *Parser) ParseValidationErrorsFromJSON(jsonOutput string) (map[string][]string, error) {
 	// Debug log: Indicate the start of JSON parsing
@@ -244,17 +1272,12 @@ func (p *Parser) ParseValidationErrorsFromJSON(jsonOutput string) (map[string][]
 				}
 			}
 
-			// Fall back to extracting from the code snippet
+			// Fall back to extracting the attribute assigned on the first line of the code snippet
 			code := strings.TrimSpace(diagnostic.Snippet.Code)
-			if code != "" {
-				// Extract the attribute name before the '=' sign, if present
-				if strings.Contains(code, "=") {
-					attribute := strings.TrimSpace(strings.Split(code, "=")[0])
-					if attribute != "" {
-						p.logger.Log("debug", "Extracted invalid attribute from code snippet: %s", attribute)
-						invalidKeys[address] = append(invalidKeys[address], attribute)
-					}
-				}
+			if matches := snippetAttributeRegex.FindStringSubmatch(code); len(matches) > 1 {
+				attribute := matches[1]
+				p.logger.Log("debug", "Extracted invalid attribute from code snippet: %s", attribute)
+				invalidKeys[address] = append(invalidKeys[address], attribute)
 			}
 		}
 	}
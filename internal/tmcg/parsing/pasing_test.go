@@ -121,6 +121,58 @@ func TestParseValidationErrorsFromJSON(t *testing.T) {
 			},
 			expectedError: false,
 		},
+		{
+			name: "Extract attribute from heredoc and indented snippets",
+			inputJSON: `{
+				"diagnostics": [
+					{
+						"severity": "error",
+						"address": "aws_instance.heredoc",
+						"summary": "",
+						"detail": "",
+						"snippet": {
+							"context": "",
+							"code": "  user_data = <<-EOT\n    export MODE=test\n  EOT"
+						}
+					},
+					{
+						"severity": "error",
+						"address": "aws_instance.indented",
+						"summary": "",
+						"detail": "",
+						"snippet": {
+							"context": "",
+							"code": "      volume_size   = 10"
+						}
+					},
+					{
+						"severity": "error",
+						"address": "aws_instance.body",
+						"summary": "",
+						"detail": "",
+						"snippet": {
+							"context": "",
+							"code": "    export MODE=test"
+						}
+					},
+					{
+						"severity": "error",
+						"address": "aws_instance.comparison",
+						"summary": "",
+						"detail": "",
+						"snippet": {
+							"context": "",
+							"code": "  var.enabled == true ? 1 : 0"
+						}
+					}
+				]
+			}`,
+			expectedKeys: map[string][]string{
+				"aws_instance.heredoc":  {"user_data"},
+				"aws_instance.indented": {"volume_size"},
+			},
+			expectedError: false,
+		},
 		{
 			name: "Diagnostics with invalid context format",
 			inputJSON: `{
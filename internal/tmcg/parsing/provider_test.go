@@ -1,6 +1,8 @@
 package parsing
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 
 	"tmcg/internal/tmcg/logging"
@@ -54,7 +56,19 @@ func TestParseProviders(t *testing.T) {
 			"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">=3.0", NamespaceLower: "hashicorp", NameLower: "aws"},
 			"azure/azapi":   {Namespace: "Azure", Name: "azapi", Version: ">= 0", NamespaceLower: "azure", NameLower: "azapi"},
 		}, false, ""},
+		{"Combined constraints", []string{"hashicorp/aws:>=5.0, <6.0"}, map[string]Provider{
+			"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">=5.0, <6.0", NamespaceLower: "hashicorp", NameLower: "aws"},
+		}, false, ""},
+		{"Constraints with an exclusion", []string{"hashicorp/aws:>= 3.0, < 4.0, != 3.5.0", "hashicorp/random:= 3.6.0"}, map[string]Provider{
+			"hashicorp/aws":    {Namespace: "hashicorp", Name: "aws", Version: ">= 3.0, < 4.0, != 3.5.0", NamespaceLower: "hashicorp", NameLower: "aws"},
+			"hashicorp/random": {Namespace: "hashicorp", Name: "random", Version: "= 3.6.0", NamespaceLower: "hashicorp", NameLower: "random"},
+		}, false, ""},
+		{"Source differing from the local name", []string{"hashicorp/aws=MyOrg/aws-fork:>=1.0"}, map[string]Provider{
+			"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">=1.0", NamespaceLower: "hashicorp", NameLower: "aws", Source: "myorg/aws-fork"},
+		}, false, ""},
 		{"Duplicate providers", []string{"hashicorp/aws:>=3.0", "hashicorp/aws"}, nil, true, "duplicate provider found"},
+		{"Shared source", []string{"hashicorp/aws=myorg/aws-fork", "myorg/aws-fork"}, nil, true, "share the source myorg/aws-fork"},
+		{"Incomplete source", []string{"hashicorp/aws=aws-fork"}, nil, true, "invalid provider format"},
 		{"Invalid provider format", []string{"invalidprovider"}, nil, true, "invalid provider format"},
 		{"Empty input list", []string{}, map[string]Provider{}, false, ""},
 		{"Valid regex but invalid version", []string{"hashicorp/aws:invalid-version"}, nil, true, "error parsing provider"},
@@ -77,3 +91,226 @@ func TestParseProviders(t *testing.T) {
 		})
 	}
 }
+
+// TestSetDefaultVersion tests that the default version applies only to providers without a version.
+func TestSetDefaultVersion(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	assert.Error(t, parser.SetDefaultVersion("latest"))
+	assert.NoError(t, parser.SetDefaultVersion("~> 5.0"))
+
+	providers, err := parser.ParseProviders([]string{"hashicorp/aws", "hashicorp/random:>=3.0"})
+	assert.NoError(t, err)
+	assert.Equal(t, "~> 5.0", providers["hashicorp/aws"].Version)
+	assert.Equal(t, ">=3.0", providers["hashicorp/random"].Version)
+}
+
+// TestParseProviderAliases tests attaching configuration aliases to declared providers.
+func TestParseProviderAliases(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	providers, err := parser.ParseProviders([]string{"hashicorp/aws"})
+	assert.NoError(t, err)
+
+	assert.NoError(t, parser.ParseProviderAliases([]string{"aws.west", "aws.east"}, providers))
+	assert.Equal(t, []string{"west", "east"}, providers["hashicorp/aws"].ConfigurationAliases)
+
+	err = parser.ParseProviderAliases([]string{"aws.west"}, providers)
+	assert.ErrorContains(t, err, "duplicate provider alias")
+
+	err = parser.ParseProviderAliases([]string{"google.west"}, providers)
+	assert.ErrorContains(t, err, "undeclared provider")
+
+	err = parser.ParseProviderAliases([]string{"aws"}, providers)
+	assert.ErrorContains(t, err, "invalid provider alias format")
+}
+
+// TestParseLockFile tests reading locked provider versions and applying them to providers.
+func TestParseLockFile(t *testing.T) {
+	lockFile := filepath.Join(t.TempDir(), ".terraform.lock.hcl")
+	err := os.WriteFile(lockFile, []byte(`# This file is maintained automatically by "terraform init".
+provider "registry.terraform.io/hashicorp/aws" {
+  version     = "5.31.0"
+  constraints = ">= 3.0"
+  hashes = [
+    "h1:abc=",
+  ]
+}
+
+provider "registry.terraform.io/hashicorp/random" {
+  version = "3.6.0"
+}
+`), 0644)
+	assert.NoError(t, err)
+
+	parser := NewParser(logging.GetGlobalLogger())
+	locked, err := parser.ParseLockFile(lockFile)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"hashicorp/aws": "5.31.0", "hashicorp/random": "3.6.0"}, locked)
+
+	providers, err := parser.ParseProviders([]string{"hashicorp/aws:>=3.0", "Azure/azapi"})
+	assert.NoError(t, err)
+	parser.ApplyLockedVersions(providers, locked)
+	assert.Equal(t, "5.31.0", providers["hashicorp/aws"].Version)
+	assert.Equal(t, ">= 0", providers["azure/azapi"].Version)
+	assert.NotContains(t, providers, "hashicorp/random")
+
+	_, err = parser.ParseLockFile(filepath.Join(t.TempDir(), "missing.hcl"))
+	assert.ErrorContains(t, err, "failed to read lock file")
+}
+
+// TestParseResourceProviderAliases tests binding resources to declared provider aliases.
+func TestParseResourceProviderAliases(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	aws := Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws", ConfigurationAliases: []string{"west"}}
+	resources := []Resource{{Name: "aws_instance", Mode: "single", Provider: aws}}
+
+	resourceProviders, err := parser.ParseResourceProviderAliases([]string{"aws_instance=aws.west"}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"aws_instance": "aws.west"}, resourceProviders)
+
+	_, err = parser.ParseResourceProviderAliases([]string{"aws_instance=aws.east"}, resources)
+	assert.ErrorContains(t, err, "is not declared")
+
+	_, err = parser.ParseResourceProviderAliases([]string{"aws_instance=google.west"}, resources)
+	assert.ErrorContains(t, err, "does not belong to the provider")
+
+	_, err = parser.ParseResourceProviderAliases([]string{"aws_vpc=aws.west"}, resources)
+	assert.ErrorContains(t, err, "undeclared resource")
+
+	_, err = parser.ParseResourceProviderAliases([]string{"aws_instance"}, resources)
+	assert.ErrorContains(t, err, "invalid resource provider alias format")
+}
+
+// TestParsePerKeyProviders tests that per-key providers are limited to multiple mode resources.
+func TestParsePerKeyProviders(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	aws := Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws", ConfigurationAliases: []string{"by_region"}}
+	resources := []Resource{{Name: "aws_instance", Mode: "multiple", Provider: aws}, {Name: "aws_vpc", Mode: "single", Provider: aws}}
+
+	perKeyProviders, err := parser.ParsePerKeyProviders([]string{"aws_instance=aws.by_region"}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"aws_instance": "aws.by_region"}, perKeyProviders)
+
+	_, err = parser.ParsePerKeyProviders([]string{"aws_vpc=aws.by_region"}, resources)
+	assert.ErrorContains(t, err, "require multiple mode")
+
+	_, err = parser.ParsePerKeyProviders([]string{"aws_instance=aws.west"}, resources)
+	assert.ErrorContains(t, err, "is not declared")
+}
+
+// TestParseDevOverrides tests mapping providers to local plugin directories.
+func TestParseDevOverrides(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	providers := map[string]Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 5.0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+
+	pluginDir := t.TempDir()
+	overrides, err := parser.ParseDevOverrides([]string{"HashiCorp/aws=" + pluginDir}, providers)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"hashicorp/aws": pluginDir}, overrides)
+	assert.Empty(t, providers["hashicorp/aws"].Version)
+
+	// A renamed provider is keyed by its source, and can be named by either
+	renamed := map[string]Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Source: "myorg/aws-fork", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+	for _, name := range []string{"hashicorp/aws", "myorg/aws-fork"} {
+		overrides, err = parser.ParseDevOverrides([]string{name + "=" + pluginDir}, renamed)
+		assert.NoError(t, err)
+		assert.Equal(t, map[string]string{"myorg/aws-fork": pluginDir}, overrides)
+	}
+	_, err = parser.ParseDevOverrides([]string{"hashicorp/aws=" + pluginDir, "myorg/aws-fork=/other"}, renamed)
+	assert.ErrorContains(t, err, "duplicate dev override")
+
+	_, err = parser.ParseDevOverrides([]string{"hashicorp/google=" + pluginDir}, providers)
+	assert.ErrorContains(t, err, "undeclared provider")
+
+	_, err = parser.ParseDevOverrides([]string{"hashicorp/aws"}, providers)
+	assert.ErrorContains(t, err, "invalid dev override format")
+
+	_, err = parser.ParseDevOverrides([]string{"hashicorp/aws=" + pluginDir, "hashicorp/aws=/other"}, providers)
+	assert.ErrorContains(t, err, "duplicate dev override")
+}
+
+// TestUnusedProviders tests finding declared providers that no resource belongs to.
+func TestUnusedProviders(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	providers, err := parser.ParseProviders([]string{"hashicorp/aws", "hashicorp/random", "hashicorp/tls"})
+	assert.NoError(t, err)
+	resources, err := parser.ParseResources([]string{"aws_instance"}, providers)
+	assert.NoError(t, err)
+
+	assert.Equal(t, []string{"hashicorp/random", "hashicorp/tls"}, parser.UnusedProviders(providers, resources))
+	assert.Empty(t, parser.UnusedProviders(map[string]Provider{}, resources))
+}
+
+// TestParseProvidersSharedName tests that providers sharing a name in different namespaces get distinct
+// local names, which aliases then refer to.
+func TestParseProvidersSharedName(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	providers, err := parser.ParseProviders([]string{"hashicorp/aws", "someorg/aws", "hashicorp/random"})
+	assert.NoError(t, err)
+	assert.Equal(t, "hashicorp-aws", providers["hashicorp/aws"].Local())
+	assert.Equal(t, "someorg-aws", providers["someorg/aws"].Local())
+	assert.Equal(t, "random", providers["hashicorp/random"].Local())
+
+	assert.NoError(t, parser.ParseProviderAliases([]string{"someorg-aws.west"}, providers))
+	assert.Equal(t, []string{"west"}, providers["someorg/aws"].ConfigurationAliases)
+	assert.ErrorContains(t, parser.ParseProviderAliases([]string{"aws.west"}, providers), "undeclared provider: aws")
+
+	resources, err := parser.ParseResources([]string{"someorg/aws::aws_instance"}, providers)
+	assert.NoError(t, err)
+	assert.False(t, resources[0].ImpliedProvider())
+
+	// A local name must not collide with the name of another provider
+	_, err = parser.ParseProviders([]string{"hashicorp/aws", "someorg/aws", "hashicorp/someorg-aws"})
+	assert.ErrorIs(t, err, ErrDuplicateProvider)
+	assert.ErrorContains(t, err, "share the local name someorg-aws")
+}
+
+// TestParseErrorKinds tests that provider and resource parsing failures match their kind with errors.Is
+// while keeping their messages.
+func TestParseErrorKinds(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	providers := map[string]Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+
+	_, err := parser.ParseProviders([]string{"invalidprovider"})
+	assert.ErrorIs(t, err, ErrInvalidProviderFormat)
+	assert.EqualError(t, err, "invalid provider format: 'invalidprovider'. Expected format: 'namespace/name[:version]'")
+
+	_, err = parser.ParseProviders([]string{"hashicorp/aws:invalid-version"})
+	assert.ErrorIs(t, err, ErrInvalidVersion)
+	assert.EqualError(t, err, "error parsing provider 'hashicorp/aws:invalid-version': invalid version format: 'invalid-version'")
+
+	_, err = parser.ParseProviders([]string{"hashicorp/aws", "HashiCorp/AWS"})
+	assert.ErrorIs(t, err, ErrDuplicateProvider)
+
+	_, err = parser.ParseProviderVersion("namespace/")
+	assert.ErrorIs(t, err, ErrInvalidProviderFormat)
+
+	_, err = parser.ParseResources([]string{"aws_instance:invalid"}, providers)
+	assert.ErrorIs(t, err, ErrInvalidResourceFormat)
+
+	_, err = parser.ParseResources([]string{"aws_instance:single", "aws_vpc:single"}, providers)
+	assert.ErrorIs(t, err, ErrSingleModeConflict)
+
+	_, err = parser.ParseResources([]string{"google_compute_instance"}, providers)
+	assert.ErrorIs(t, err, ErrNoMatchingProvider)
+	assert.EqualError(t, err, "no matching provider found for resource: google_compute_instance")
+
+	_, err = parser.ParseResources([]string{"hashicorp/google::google_compute_instance"}, providers)
+	assert.ErrorIs(t, err, ErrNoMatchingProvider)
+
+	_, err = parser.ParseResources([]string{"aws_instance", "aws_instance"}, providers)
+	assert.ErrorIs(t, err, ErrDuplicateResource)
+	assert.NotErrorIs(t, err, ErrInvalidResourceFormat)
+
+	var parseErr *ParseError
+	assert.ErrorAs(t, err, &parseErr)
+	assert.Equal(t, ErrDuplicateResource, parseErr.Kind)
+}
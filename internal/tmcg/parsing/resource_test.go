@@ -1,10 +1,14 @@
 package parsing
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 	"tmcg/internal/tmcg/logging"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // TestParseResources tests the ParseResources function.
@@ -75,3 +79,468 @@ func TestParseResources(t *testing.T) {
 		})
 	}
 }
+
+// TestParseDefaultsFile tests parsing per-attribute defaults from JSON.
+func TestParseDefaultsFile(t *testing.T) {
+	dir := t.TempDir()
+	parser := NewParser(logging.GetGlobalLogger())
+
+	validPath := filepath.Join(dir, "defaults.json")
+	assert.NoError(t, os.WriteFile(validPath, []byte(`{"aws_instance.instance_type": "t3.micro", "aws_instance.monitoring": true, "aws_instance.tags": {"team": "core"}}`), 0644))
+	defaults, err := parser.ParseDefaultsFile(validPath)
+	assert.NoError(t, err)
+	assert.Equal(t, cty.StringVal("t3.micro"), defaults["aws_instance.instance_type"])
+	assert.Equal(t, cty.True, defaults["aws_instance.monitoring"])
+	assert.Equal(t, cty.ObjectVal(map[string]cty.Value{"team": cty.StringVal("core")}), defaults["aws_instance.tags"])
+
+	invalidKeyPath := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalidKeyPath, []byte(`{"instance_type": "t3.micro"}`), 0644))
+	_, err = parser.ParseDefaultsFile(invalidKeyPath)
+	assert.ErrorContains(t, err, "invalid defaults key")
+}
+
+// TestParseKeyMode tests validating the for_each key mode.
+func TestParseKeyMode(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	for _, input := range []string{KeyModeName, KeyModeIndex, KeyModeCoalesce} {
+		mode, err := parser.ParseKeyMode(input)
+		assert.NoError(t, err)
+		assert.Equal(t, input, mode)
+	}
+
+	_, err := parser.ParseKeyMode("id")
+	assert.ErrorContains(t, err, "invalid key mode: 'id'")
+}
+
+// TestParseOptionalBlockDefault tests validating the default of optional single-mode nested block variables.
+func TestParseOptionalBlockDefault(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	for _, input := range []string{OptionalBlockDefaultNull, OptionalBlockDefaultNone} {
+		value, err := parser.ParseOptionalBlockDefault(input)
+		assert.NoError(t, err)
+		assert.Equal(t, input, value)
+	}
+
+	_, err := parser.ParseOptionalBlockDefault("empty")
+	assert.ErrorContains(t, err, "invalid optional block default: 'empty'")
+}
+
+// TestParseTagAttributes tests parsing the candidate tag attribute names.
+func TestParseTagAttributes(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	names, err := parser.ParseTagAttributes("tags, labels,tags_all")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"tags", "labels", "tags_all"}, names)
+
+	_, err = parser.ParseTagAttributes("tags,,labels")
+	assert.ErrorContains(t, err, "invalid tag attribute name: ''")
+
+	_, err = parser.ParseTagAttributes("tags,tags")
+	assert.ErrorContains(t, err, "duplicate tag attribute name: tags")
+}
+
+// TestParseResourceAliases tests parsing the aliases that name the variables of resources.
+func TestParseResourceAliases(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	resources := []Resource{
+		{Name: "aws_instance", Mode: "single"},
+		{Name: "aws_vpc", Mode: "multiple"},
+		{Name: "aws_subnet", Mode: "multiple", Label: "public"},
+		{Name: "aws_subnet", Mode: "multiple", Label: "private"},
+	}
+
+	aliases, err := parser.ParseResourceAliases([]string{"aws_instance=frontend", " aws_vpc = networks "}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"aws_instance": "frontend", "aws_vpc": "networks"}, aliases)
+
+	for input, expected := range map[string]string{
+		"aws_instance":            "invalid resource alias format",
+		"aws_instance=front-end!": "invalid resource alias for 'aws_instance'",
+		"aws_s3_bucket=storage":   "resource alias given for undeclared resource: aws_s3_bucket",
+		"aws_subnet=subnets":      "resource alias for 'aws_subnet' is ambiguous, as the resource is declared 2 times",
+	} {
+		_, err := parser.ParseResourceAliases([]string{input}, resources)
+		assert.ErrorContains(t, err, expected, input)
+	}
+
+	_, err = parser.ParseResourceAliases([]string{"aws_instance=frontend", "aws_instance=web"}, resources)
+	assert.ErrorContains(t, err, "duplicate resource alias for resource: aws_instance")
+
+	_, err = parser.ParseResourceAliases([]string{"aws_instance=frontend", "aws_vpc=frontend"}, resources)
+	assert.ErrorContains(t, err, "resource alias frontend is given to both aws_instance and aws_vpc")
+}
+
+// TestParseBackend tests validating the backend to scaffold.
+func TestParseBackend(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	for input, expected := range map[string]string{"": "", "local": BackendLocal, "S3": BackendS3, " cloud ": BackendCloud} {
+		backend, err := parser.ParseBackend(input)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, backend)
+	}
+
+	_, err := parser.ParseBackend("gcs")
+	assert.ErrorContains(t, err, "invalid backend: 'gcs'")
+}
+
+// TestParseBackendConfig tests parsing backend attributes into HCL expressions, and the backend file name.
+func TestParseBackendConfig(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	config, err := parser.ParseBackendConfig([]string{
+		"bucket=my-state", " region = eu-west-1", "key=a=b", "encrypt=true", "retries=3", `profile="prod"`,
+		`workspaces={ tags = ["app"] }`, "prefix=${var.env}", "empty=",
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		"bucket":     `"my-state"`,
+		"region":     `"eu-west-1"`,
+		"key":        `"a=b"`,
+		"encrypt":    "true",
+		"retries":    "3",
+		"profile":    `"prod"`,
+		"workspaces": `{ tags = ["app"] }`,
+		"prefix":     `"$${var.env}"`,
+		"empty":      `""`,
+	}, config)
+
+	_, err = parser.ParseBackendConfig([]string{"bucket"})
+	assert.ErrorContains(t, err, "invalid backend config format")
+
+	_, err = parser.ParseBackendConfig([]string{"bucket.name=x"})
+	assert.ErrorContains(t, err, "invalid backend config attribute name")
+
+	_, err = parser.ParseBackendConfig([]string{"bucket=a", "bucket=b"})
+	assert.ErrorContains(t, err, "duplicate backend config attribute")
+
+	file, err := parser.ParseBackendFile("backend.tf")
+	assert.NoError(t, err)
+	assert.Equal(t, "backend.tf", file)
+
+	for _, invalid := range []string{"backend", "../backend.tf", "versions.tf"} {
+		_, err = parser.ParseBackendFile(invalid)
+		assert.ErrorContains(t, err, "invalid backend file", invalid)
+	}
+}
+
+// TestParseAttributeCase tests validating the case of variable names derived from attributes.
+func TestParseAttributeCase(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	for _, valid := range []string{AttributeCaseSnake, AttributeCasePreserve} {
+		value, err := parser.ParseAttributeCase(valid)
+		assert.NoError(t, err)
+		assert.Equal(t, valid, value)
+	}
+
+	_, err := parser.ParseAttributeCase("camel")
+	assert.ErrorContains(t, err, "invalid attribute case")
+}
+
+// TestParseRegistryHost tests that registry hosts are lowercased and must not contain a path.
+func TestParseRegistryHost(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	host, err := parser.ParseRegistryHost(" Terraform.Example.com ")
+	assert.NoError(t, err)
+	assert.Equal(t, "terraform.example.com", host)
+
+	for _, input := range []string{"", "https://terraform.example.com", "terraform.example.com/hashicorp"} {
+		_, err := parser.ParseRegistryHost(input)
+		assert.ErrorContains(t, err, "invalid registry host")
+	}
+}
+
+// TestParseFileMode tests parsing octal permission modes for the generated files.
+func TestParseFileMode(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	mode, err := parser.ParseFileMode("0640")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), mode)
+
+	mode, err = parser.ParseFileMode("644")
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), mode)
+
+	for _, input := range []string{"", "0648", "rw-r--r--", "01777", "-1"} {
+		_, err := parser.ParseFileMode(input)
+		assert.ErrorContains(t, err, "invalid file mode")
+	}
+	// Generated files are rewritten during the run, so the owner must be able to read and write them
+	for _, input := range []string{"0", "0000", "0444", "0440", "0200"} {
+		_, err := parser.ParseFileMode(input)
+		assert.ErrorContains(t, err, "The owner needs read and write permission", input)
+	}
+}
+
+// TestParseDescriptionsFile tests parsing custom variable descriptions from a JSON file.
+func TestParseDescriptionsFile(t *testing.T) {
+	dir := t.TempDir()
+	parser := NewParser(logging.GetGlobalLogger())
+
+	validPath := filepath.Join(dir, "descriptions.json")
+	assert.NoError(t, os.WriteFile(validPath, []byte(`{"aws_instance.ami": "Image ID", "aws_instance.ebs_block_device.volume_size": "Size in GiB"}`), 0644))
+	descriptions, err := parser.ParseDescriptionsFile(validPath)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"aws_instance.ami": "Image ID", "aws_instance.ebs_block_device.volume_size": "Size in GiB"}, descriptions)
+
+	invalidKeyPath := filepath.Join(dir, "invalid.json")
+	assert.NoError(t, os.WriteFile(invalidKeyPath, []byte(`{"ami": "Image ID"}`), 0644))
+	_, err = parser.ParseDescriptionsFile(invalidKeyPath)
+	assert.ErrorContains(t, err, "invalid descriptions key")
+
+	nonStringPath := filepath.Join(dir, "non-string.json")
+	assert.NoError(t, os.WriteFile(nonStringPath, []byte(`{"aws_instance.ami": 1}`), 0644))
+	_, err = parser.ParseDescriptionsFile(nonStringPath)
+	assert.ErrorContains(t, err, "failed to parse descriptions file")
+}
+
+// TestParseTypeOverrides tests parsing and validating per-attribute type overrides.
+func TestParseTypeOverrides(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	overrides, err := parser.ParseTypeOverrides([]string{"aws_instance.tags=map(string)", "aws_instance.ebs_block_device.tags = map(any)"})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"aws_instance.tags": "map(string)", "aws_instance.ebs_block_device.tags": "map(any)"}, overrides)
+
+	_, err = parser.ParseTypeOverrides([]string{"aws_instance.tags=mapping(string)"})
+	assert.ErrorContains(t, err, "invalid type expression")
+
+	_, err = parser.ParseTypeOverrides([]string{"tags=map(string)"})
+	assert.ErrorContains(t, err, "invalid type override format")
+
+	_, err = parser.ParseTypeOverrides([]string{"aws_instance.tags=map(string)", "aws_instance.tags=any"})
+	assert.ErrorContains(t, err, "duplicate type override")
+}
+
+// TestParseResourcesHyphenatedProvider tests that resources match hyphenated providers such as google-beta.
+func TestParseResourcesHyphenatedProvider(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	googleBeta := Provider{Namespace: "hashicorp", Name: "google-beta", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "google-beta"}
+	google := Provider{Namespace: "hashicorp", Name: "google", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "google"}
+
+	resources, err := parser.ParseResources([]string{"google_compute_instance"}, map[string]Provider{"hashicorp/google-beta": googleBeta})
+	require.NoError(t, err)
+	assert.Equal(t, "google-beta", resources[0].Provider.NameLower)
+	assert.Equal(t, "google", resources[0].TypePrefix())
+	assert.False(t, resources[0].ImpliedProvider())
+
+	// The provider named by the prefix wins when both are declared
+	resources, err = parser.ParseResources([]string{"google_compute_instance"}, map[string]Provider{"hashicorp/google-beta": googleBeta, "hashicorp/google": google})
+	require.NoError(t, err)
+	assert.Equal(t, "google", resources[0].Provider.NameLower)
+	assert.True(t, resources[0].ImpliedProvider())
+
+	// Prefixes only match whole underscore-separated words
+	awscc := Provider{Namespace: "hashicorp", Name: "awscc", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "awscc"}
+	aws := Provider{Namespace: "hashicorp", Name: "aws", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources, err = parser.ParseResources([]string{"awscc_s3_bucket", "aws_s3_bucket"}, map[string]Provider{"hashicorp/aws": aws, "hashicorp/awscc": awscc})
+	require.NoError(t, err)
+	assert.Equal(t, "awscc", resources[0].Provider.NameLower)
+	assert.Equal(t, "aws", resources[1].Provider.NameLower)
+}
+
+// TestParseResourcesQualifiedProvider tests that a provider key before "::" binds the resource to that provider.
+func TestParseResourcesQualifiedProvider(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	googleBeta := Provider{Namespace: "hashicorp", Name: "google-beta", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "google-beta"}
+	google := Provider{Namespace: "hashicorp", Name: "google", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "google"}
+	providers := map[string]Provider{"hashicorp/google-beta": googleBeta, "hashicorp/google": google}
+
+	resources, err := parser.ParseResources([]string{"HashiCorp/Google-Beta::google_compute_instance:single:beta", "google_compute_instance"}, providers)
+	require.NoError(t, err)
+	assert.Equal(t, "google_compute_instance", resources[0].Name)
+	assert.Equal(t, "single", resources[0].Mode)
+	assert.Equal(t, "beta", resources[0].Label)
+	assert.Equal(t, "google-beta", resources[0].Provider.NameLower)
+	assert.False(t, resources[0].ImpliedProvider())
+	assert.Equal(t, "google", resources[1].Provider.NameLower)
+
+	_, err = parser.ParseResources([]string{"hashicorp/aws::google_compute_instance"}, providers)
+	assert.ErrorContains(t, err, "provider 'hashicorp/aws' of resource 'google_compute_instance' is not declared")
+
+	// Without a slash the part before "::" is still the resource type, with the default mode
+	resources, err = parser.ParseResources([]string{"google_compute_instance::web"}, providers)
+	require.NoError(t, err)
+	assert.Equal(t, "multiple", resources[0].Mode)
+	assert.Equal(t, "web", resources[0].Label)
+}
+
+// TestParseResourcesLabels tests custom labels and the uniqueness of type and label pairs.
+func TestParseResourcesLabels(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	providers := map[string]Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+
+	resources, err := parser.ParseResources([]string{"aws_instance:multiple:web", "aws_instance:single", "aws_vpc::web"}, providers)
+	assert.NoError(t, err)
+	assert.Equal(t, "web", resources[0].BlockLabel())
+	assert.Equal(t, "this", resources[1].BlockLabel())
+	assert.Equal(t, "multiple", resources[2].Mode)
+	assert.Equal(t, "web", resources[2].BlockLabel())
+
+	_, err = parser.ParseResources([]string{"aws_instance:multiple:web", "aws_instance:single:web"}, providers)
+	assert.ErrorContains(t, err, "duplicate resource address: aws_instance.web")
+
+	_, err = parser.ParseResources([]string{"aws_instance", "aws_instance:single"}, providers)
+	assert.ErrorContains(t, err, "duplicate resource address: aws_instance.this")
+
+	resources, err = parser.ParseResources([]string{"aws_instance:single:web", "aws_instance:single:db"}, providers)
+	assert.NoError(t, err)
+	assert.Len(t, resources, 2)
+
+	_, err = parser.ParseResources([]string{"aws_instance:multiple:1web"}, providers)
+	assert.ErrorContains(t, err, "invalid label")
+}
+
+// TestParseForEachMap tests that map iteration is only allowed for declared multiple-mode resources.
+func TestParseForEachMap(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	resources := []Resource{{Name: "aws_instance", Mode: "multiple"}, {Name: "aws_vpc", Mode: "single"}}
+
+	forEachMap, err := parser.ParseForEachMap([]string{"aws_instance"}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"aws_instance": true}, forEachMap)
+
+	_, err = parser.ParseForEachMap([]string{"aws_vpc"}, resources)
+	assert.ErrorContains(t, err, "requires multiple mode")
+
+	_, err = parser.ParseForEachMap([]string{"aws_subnet"}, resources)
+	assert.ErrorContains(t, err, "undeclared resource")
+}
+
+// TestSetSingleRefStyle tests that only the bare style limits runs to one single-mode resource.
+func TestSetSingleRefStyle(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	providers := map[string]Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+
+	assert.Error(t, parser.SetSingleRefStyle("nested"))
+
+	_, err := parser.ParseResources([]string{"aws_instance:single", "aws_vpc:single"}, providers)
+	assert.ErrorContains(t, err, "only one resource of type 'single' is supported")
+
+	for _, style := range []string{SingleRefPrefixed, SingleRefObject} {
+		assert.NoError(t, parser.SetSingleRefStyle(style))
+		resources, err := parser.ParseResources([]string{"aws_instance:single", "aws_vpc:single"}, providers)
+		assert.NoError(t, err)
+		assert.Len(t, resources, 2)
+	}
+}
+
+// TestParseExtraHCL tests validating raw HCL snippets for declared resources.
+func TestParseExtraHCL(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	resources := []Resource{{Name: "aws_instance", Mode: "multiple"}}
+
+	extraHCL, err := parser.ParseExtraHCL([]string{`aws_instance=tags = { foo = "bar" }`, "aws_instance=lifecycle { create_before_destroy = true }"}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]string{"aws_instance": {`tags = { foo = "bar" }`, "lifecycle { create_before_destroy = true }"}}, extraHCL)
+
+	_, err = parser.ParseExtraHCL([]string{"aws_instance=tags = {"}, resources)
+	assert.ErrorContains(t, err, "invalid extra HCL for 'aws_instance'")
+
+	_, err = parser.ParseExtraHCL([]string{"aws_vpc=count = 1"}, resources)
+	assert.ErrorContains(t, err, "undeclared resource")
+
+	_, err = parser.ParseExtraHCL([]string{"aws_instance"}, resources)
+	assert.ErrorContains(t, err, "invalid extra HCL format")
+}
+
+// TestParseToggleable tests that only declared single-mode resources can be toggled.
+func TestParseToggleable(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	resources := []Resource{{Name: "aws_instance", Mode: "single"}, {Name: "aws_vpc", Mode: "multiple"}}
+
+	toggleable, err := parser.ParseToggleable([]string{"aws_instance"}, resources)
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]bool{"aws_instance": true}, toggleable)
+
+	_, err = parser.ParseToggleable([]string{"aws_vpc"}, resources)
+	assert.ErrorContains(t, err, "require single mode")
+
+	_, err = parser.ParseToggleable([]string{"aws_subnet"}, resources)
+	assert.ErrorContains(t, err, "undeclared resource")
+}
+
+// TestParseResourceSettings tests validating the per-resource settings of a config file.
+func TestParseResourceSettings(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	multiple := Resource{Name: "aws_instance", Mode: "multiple"}
+
+	resource, err := parser.ParseResourceSettings(multiple, "ami", IterationMap, nil, []string{"tags"})
+	assert.NoError(t, err)
+	assert.Equal(t, Resource{Name: "aws_instance", Mode: "multiple", KeyAttr: "ami", Iteration: IterationMap, Exclude: []string{"tags"}}, resource)
+
+	_, err = parser.ParseResourceSettings(multiple, "", "loop", nil, nil)
+	assert.ErrorContains(t, err, "invalid iteration")
+
+	_, err = parser.ParseResourceSettings(Resource{Name: "aws_instance", Mode: "single"}, "", IterationCount, nil, nil)
+	assert.ErrorContains(t, err, "require multiple mode")
+
+	_, err = parser.ParseResourceSettings(multiple, "ami", IterationCount, nil, nil)
+	assert.ErrorContains(t, err, "no effect with count")
+
+	_, err = parser.ParseResourceSettings(multiple, "1ami", "", nil, nil)
+	assert.ErrorContains(t, err, "invalid key_attr")
+
+	_, err = parser.ParseResourceSettings(multiple, "", "", []string{"ami"}, []string{"tags"})
+	assert.ErrorContains(t, err, "both include and exclude")
+}
+
+// TestParseNullDefaultTypes tests mapping primitive types to default literals.
+func TestParseNullDefaultTypes(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+
+	defaults, err := parser.ParseNullDefaultTypes("string=empty, number=zero,bool=false")
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{"string": `""`, "number": "0", "bool": "false"}, defaults)
+
+	defaults, err = parser.ParseNullDefaultTypes("")
+	assert.NoError(t, err)
+	assert.Empty(t, defaults)
+
+	_, err = parser.ParseNullDefaultTypes("list=empty")
+	assert.ErrorContains(t, err, "unsupported type")
+
+	_, err = parser.ParseNullDefaultTypes("number=empty")
+	assert.ErrorContains(t, err, "unsupported null default keyword for number")
+
+	_, err = parser.ParseNullDefaultTypes("string=empty,string=null")
+	assert.ErrorContains(t, err, "duplicate null default")
+
+	_, err = parser.ParseNullDefaultTypes("string")
+	assert.ErrorContains(t, err, "invalid null default format")
+}
+
+// TestParsePreconditions tests parsing lifecycle preconditions addressed by resource type and label.
+func TestParsePreconditions(t *testing.T) {
+	parser := NewParser(logging.GetGlobalLogger())
+	resources := []Resource{{Name: "aws_instance", Mode: "single"}, {Name: "aws_vpc", Mode: "multiple", Label: "main"}}
+
+	preconditions, err := parser.ParsePreconditions([]string{
+		`aws_instance.this:var.ami != "" :AMI required`,
+		`aws_vpc.main:var.enabled ? true : length(var.vpcs) == 0:No VPCs when disabled`,
+	}, resources)
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]Precondition{
+		"aws_instance.this": {{Condition: `var.ami != ""`, ErrorMessage: "AMI required"}},
+		"aws_vpc.main":      {{Condition: "var.enabled ? true : length(var.vpcs) == 0", ErrorMessage: "No VPCs when disabled"}},
+	}, preconditions)
+
+	_, err = parser.ParsePreconditions([]string{"aws_vpc.this:true:message"}, resources)
+	assert.ErrorContains(t, err, "undeclared resource")
+
+	_, err = parser.ParsePreconditions([]string{"aws_instance.this:var.ami !=:message"}, resources)
+	assert.ErrorContains(t, err, "invalid precondition condition")
+
+	_, err = parser.ParsePreconditions([]string{"aws_instance.this:true"}, resources)
+	assert.ErrorContains(t, err, "invalid precondition format")
+}
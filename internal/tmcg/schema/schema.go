@@ -3,6 +3,9 @@
 package schema
 
 import (
+	"fmt"
+	"io"
+	"sort"
 	"strings"
 
 	"tmcg/internal/tmcg/logging"
@@ -14,6 +17,23 @@ import (
 // SchemaManager is responsible for managing and filtering schemas.
 type SchemaManager struct {
 	logger logging.Logger
+
+	// removedComputed and removedInvalid record the "resource.attribute" paths
+	// dropped by RemoveComputedAttributes and RemoveInvalidAttributesFromSchema.
+	removedComputed []string
+	removedInvalid  []string
+
+	// keepID makes RemoveComputedAttributes keep a settable top-level id attribute.
+	keepID bool
+
+	// explain receives a trace of why each attribute is kept, dropped or transformed, when set.
+	explain io.Writer
+}
+
+// PrepareOptions selects the optional behavior of Prepare.
+type PrepareOptions struct {
+	// KeepID keeps a settable top-level id attribute instead of dropping it.
+	KeepID bool
 }
 
 // NewSchemaManager creates a new instance of SchemaManager.
@@ -21,32 +41,94 @@ func NewSchemaManager(logger logging.Logger) *SchemaManager {
 	return &SchemaManager{logger: logger}
 }
 
-// FilterSchema filters the fetched JSON schema for only the required resources.
+// SetKeepID controls whether RemoveComputedAttributes keeps a top-level id attribute that is
+// optional, usually optional and computed, instead of dropping it with a warning.
+func (sm *SchemaManager) SetKeepID(keep bool) {
+	sm.keepID = keep
+}
+
+// SetExplainWriter makes the schema passes write one line per decision about a resource or attribute
+// to w, such as: stage=computed path=aws_instance.arn decision=dropped reason="computed-only".
+func (sm *SchemaManager) SetExplainWriter(w io.Writer) {
+	sm.explain = w
+}
+
+// Prepare runs the passes the CLI applies before generating files: it filters the provider schemas for the
+// resources and removes computed-only attributes. The options apply to this call only, and settings made
+// with SetKeepID are restored afterwards. Like RemoveComputedAttributes, it modifies the resource schemas of
+// providerSchemas in place. Unlike the passes themselves, it reports a nil or provider-less schema document
+// as an error.
+func (sm *SchemaManager) Prepare(providerSchemas *tfjson.ProviderSchemas, resources []parsing.Resource, opts PrepareOptions) (*tfjson.ProviderSchemas, error) {
+	if providerSchemas == nil || len(providerSchemas.Schemas) == 0 {
+		return nil, fmt.Errorf("no provider schemas to prepare: the schema document is empty, malformed or has no providers")
+	}
+
+	keepID := sm.keepID
+	defer func() { sm.keepID = keepID }()
+	sm.keepID = opts.KeepID
+	return sm.RemoveComputedAttributes(sm.FilterSchema(providerSchemas, resources)), nil
+}
+
+// FilterSchema filters the fetched JSON schema for only the required resources. A nil or provider-less
+// document is logged as an error and yields an empty result, which callers cannot tell apart from a
+// document without the resources; use Prepare to have it reported as an error.
 func (sm *SchemaManager) FilterSchema(providerSchemas *tfjson.ProviderSchemas, resources []parsing.Resource) *tfjson.ProviderSchemas {
 	sm.logger.Log("info", "Starting to filter provider schemas for required resources...")
 
+	// A malformed schema document can decode to nothing, which leaves nothing to filter
+	if providerSchemas == nil {
+		sm.logger.Log("error", "No provider schemas to filter: the schema document is empty or malformed")
+		return &tfjson.ProviderSchemas{Schemas: make(map[string]*tfjson.ProviderSchema)}
+	}
+	if len(providerSchemas.Schemas) == 0 {
+		sm.logger.Log("error", "No provider schemas to filter: the schema document has no providers")
+	}
+
 	filteredProviderSchemas := &tfjson.ProviderSchemas{
 		FormatVersion: providerSchemas.FormatVersion,
 		Schemas:       make(map[string]*tfjson.ProviderSchema),
 	}
 
-	// Create a set of required resources for quick lookup.
+	// Create sets of the required resources and their providers for quick lookup.
+	// Resources without provider details make every provider eligible.
 	requiredResources := make(map[string]bool)
+	requestedProviders := make(map[string]bool)
+	anyProvider := false
 	for _, resource := range resources {
 		requiredResources[resource.Name] = true
+		if resource.Provider.NameLower == "" {
+			anyProvider = true
+			continue
+		}
+		requestedProviders[resource.Provider.SourceKey()] = true
 	}
 
 	// Iterate over the provider schemas to filter only those required resources.
 	for providerKey, providerSchema := range providerSchemas.Schemas {
-		// Initialize a new ProviderSchema to hold filtered resources.
+		if providerSchema == nil {
+			sm.logger.Log("warn", "Skipping provider without a schema: %s", providerKey)
+			continue
+		}
+
+		// Skip providers pulled in as dependencies, which can have large schemas, so that
+		// this and every later pass only handles the providers of the requested resources.
+		if !anyProvider && !requestedProviders[providerSource(providerKey)] {
+			sm.logger.Log("debug", "Skipping provider not referenced by any requested resource: %s", providerKey)
+			sm.trace("filter", providerKey, "dropped", "provider not referenced by any requested resource")
+			continue
+		}
+
+		// Initialize a new ProviderSchema to hold filtered resources, keeping the provider configuration schema.
 		filteredProviderSchema := &tfjson.ProviderSchema{
+			ConfigSchema:    providerSchema.ConfigSchema,
 			ResourceSchemas: make(map[string]*tfjson.Schema),
 		}
 
-		for resourceName, resourceSchema := range providerSchema.ResourceSchemas {
-			if _, exists := requiredResources[resourceName]; exists {
+		for resourceName := range requiredResources {
+			if resourceSchema, exists := providerSchema.ResourceSchemas[resourceName]; exists && resourceSchema != nil {
 				filteredProviderSchema.ResourceSchemas[resourceName] = resourceSchema
 				sm.logger.Log("debug", "Included resource: %s", resourceName)
+				sm.trace("filter", resourceName, "kept", "requested resource of "+providerKey)
 			}
 		}
 
@@ -60,27 +142,32 @@ func (sm *SchemaManager) FilterSchema(providerSchemas *tfjson.ProviderSchemas, r
 	return filteredProviderSchemas
 }
 
-// RemoveComputedAttributes removes attributes that are computed and not optional or required.
+// RemoveComputedAttributes removes attributes that are computed and not optional or required. A nil
+// document is logged as an error and yields an empty result, as in FilterSchema.
 func (sm *SchemaManager) RemoveComputedAttributes(providerSchemas *tfjson.ProviderSchemas) *tfjson.ProviderSchemas {
+	if providerSchemas == nil {
+		sm.logger.Log("error", "No provider schemas to remove computed attributes from: the schema document is empty or malformed")
+		return &tfjson.ProviderSchemas{Schemas: make(map[string]*tfjson.ProviderSchema)}
+	}
+
 	for _, providerSchema := range providerSchemas.Schemas {
-		for _, resourceSchema := range providerSchema.ResourceSchemas {
-			block := resourceSchema.Block
-			if block == nil {
+		if providerSchema == nil {
+			continue
+		}
+		for resourceName, resourceSchema := range providerSchema.ResourceSchemas {
+			if resourceSchema == nil || resourceSchema.Block == nil {
 				continue
 			}
+			block := resourceSchema.Block
 
 			// Remove computed-only attributes from top-level attributes.
-			for attrName, attrSchema := range block.Attributes {
-				if attrSchema.Computed && !attrSchema.Optional && !attrSchema.Required {
-					delete(block.Attributes, attrName)
-					sm.logger.Log("debug", "Removed computed-only attribute: %s", attrName)
-				}
+			for _, attrName := range sortedAttributeNames(block.Attributes) {
+				sm.removeComputedAttribute(block, attrName, block.Attributes[attrName], resourceName+"."+attrName)
 			}
+			sm.removeIDAttribute(block, resourceName)
 
 			// Recursively remove computed-only attributes from nested blocks.
-			for _, nestedBlock := range block.NestedBlocks {
-				sm.RemoveComputedAttributesFromBlock(nestedBlock.Block)
-			}
+			sm.removeComputedAttributesFromNestedBlocks(block, resourceName)
 		}
 	}
 	return providerSchemas
@@ -88,22 +175,92 @@ func (sm *SchemaManager) RemoveComputedAttributes(providerSchemas *tfjson.Provid
 
 // RemoveComputedAttributesFromBlock removes computed-only attributes from nested blocks recursively.
 func (sm *SchemaManager) RemoveComputedAttributesFromBlock(block *tfjson.SchemaBlock) {
+	sm.removeComputedAttributesFromBlock(block, "")
+}
+
+// removeComputedAttributesFromBlock does the work of RemoveComputedAttributesFromBlock,
+// recording removed attributes under the given path.
+func (sm *SchemaManager) removeComputedAttributesFromBlock(block *tfjson.SchemaBlock, path string) {
 	if block == nil {
 		return
 	}
 
 	// Remove computed-only attributes from this block.
-	for attrName, attrSchema := range block.Attributes {
-		if attrSchema.Computed && !attrSchema.Optional && !attrSchema.Required {
-			delete(block.Attributes, attrName)
-			sm.logger.Log("debug", "Removed computed-only attribute: %s", attrName)
-		}
+	for _, attrName := range sortedAttributeNames(block.Attributes) {
+		sm.removeComputedAttribute(block, attrName, block.Attributes[attrName], joinPath(path, attrName))
 	}
 
 	// Recursively process nested blocks.
-	for _, nestedBlock := range block.NestedBlocks {
-		sm.RemoveComputedAttributesFromBlock(nestedBlock.Block)
+	sm.removeComputedAttributesFromNestedBlocks(block, path)
+}
+
+// removeComputedAttributesFromNestedBlocks processes the nested blocks of a block and prunes the optional
+// ones left without attributes or blocks, which would otherwise render as object({}) variables and empty
+// dynamic blocks. Blocks that were empty in the schema are kept, since their presence alone can matter.
+func (sm *SchemaManager) removeComputedAttributesFromNestedBlocks(block *tfjson.SchemaBlock, path string) {
+	for blockName, nestedBlock := range block.NestedBlocks {
+		if nestedBlock == nil {
+			continue
+		}
+		blockPath := joinPath(path, blockName)
+		hadContent := blockHasContent(nestedBlock.Block)
+		sm.removeComputedAttributesFromBlock(nestedBlock.Block, blockPath)
+
+		if !hadContent || blockHasContent(nestedBlock.Block) {
+			continue
+		}
+		if nestedBlock.MinItems > 0 {
+			sm.trace("computed", blockPath, "kept", "required block left empty by computed-only attributes")
+			continue
+		}
+		delete(block.NestedBlocks, blockName)
+		sm.trace("computed", blockPath, "dropped", "optional block left empty by computed-only attributes")
+		sm.logger.Log("debug", "Removed nested block left empty: %s", blockPath)
+	}
+}
+
+// blockHasContent reports whether a block has any attributes or nested blocks.
+func blockHasContent(block *tfjson.SchemaBlock) bool {
+	return block != nil && (len(block.Attributes) > 0 || len(block.NestedBlocks) > 0)
+}
+
+// removeComputedAttribute removes a computed-only attribute from its block.
+func (sm *SchemaManager) removeComputedAttribute(block *tfjson.SchemaBlock, attrName string, attrSchema *tfjson.SchemaAttribute, path string) {
+	if attrSchema == nil {
+		return
+	}
+	switch {
+	case attrSchema.Required:
+		sm.trace("computed", path, "kept", "required")
+		return
+	case attrSchema.Optional && attrSchema.Computed:
+		sm.trace("computed", path, "kept", "optional+computed")
+		return
+	case attrSchema.Optional:
+		sm.trace("computed", path, "kept", "optional")
+		return
+	case !attrSchema.Computed:
+		sm.trace("computed", path, "kept", "neither computed, optional nor required")
+		return
+	}
+
+	delete(block.Attributes, attrName)
+	sm.removedComputed = append(sm.removedComputed, path)
+	sm.trace("computed", path, "dropped", "computed-only")
+	sm.logger.Log("debug", "Removed computed-only attribute: %s", attrName)
+}
+
+// removeIDAttribute drops a top-level id attribute that survived as optional, since a settable
+// id variable is almost never wanted. Required ids are kept as the resource cannot be created without them.
+func (sm *SchemaManager) removeIDAttribute(block *tfjson.SchemaBlock, resourceName string) {
+	attrSchema, exists := block.Attributes["id"]
+	if sm.keepID || !exists || attrSchema == nil || attrSchema.Required {
+		return
 	}
+
+	delete(block.Attributes, "id")
+	sm.trace("computed", resourceName+".id", "dropped", "optional top-level id; --keep-id keeps it")
+	sm.logger.Log("warn", "Dropped the optional id attribute of %s; use --keep-id to generate a variable for it", resourceName)
 }
 
 // RemoveInvalidAttributesFromSchema removes invalid attributes from the schema based on validation errors.
@@ -138,9 +295,12 @@ func (sm *SchemaManager) RemoveInvalidAttributesFromSchema(cleanedSchema map[str
 
 				if _, exists := resourceSchema.Block.Attributes[attrName]; exists {
 					delete(resourceSchema.Block.Attributes, attrName)
+					sm.removedInvalid = append(sm.removedInvalid, resourceKey+"."+attrName)
 					sm.logger.Log("debug", "Removed attribute: %s from resource: %s", attrName, resourceKey)
+					sm.trace("invalid", resourceKey+"."+attrName, "dropped", "rejected by terraform validate")
 				} else {
 					sm.logger.Log("warn", "Attribute %s not found in resource %s, cannot remove", attrName, resourceKey)
+					sm.trace("invalid", resourceKey+"."+attrName, "missing", "rejected by terraform validate but not in the schema")
 				}
 			}
 		}
@@ -151,3 +311,53 @@ func (sm *SchemaManager) RemoveInvalidAttributesFromSchema(cleanedSchema map[str
 		Schemas: cleanedSchema,
 	}
 }
+
+// RemovedComputedAttributes returns the sorted paths of attributes removed as computed-only.
+func (sm *SchemaManager) RemovedComputedAttributes() []string {
+	removed := append([]string{}, sm.removedComputed...)
+	sort.Strings(removed)
+	return removed
+}
+
+// RemovedInvalidAttributes returns the sorted paths of attributes removed after validation errors.
+func (sm *SchemaManager) RemovedInvalidAttributes() []string {
+	removed := append([]string{}, sm.removedInvalid...)
+	sort.Strings(removed)
+	return removed
+}
+
+// trace writes one decision of a schema pass to the explain writer, if any
+func (sm *SchemaManager) trace(stage, path, decision, reason string) {
+	if sm.explain == nil {
+		return
+	}
+	_, _ = fmt.Fprintf(sm.explain, "stage=%s path=%s decision=%s reason=%q\n", stage, path, decision, reason)
+}
+
+// sortedAttributeNames returns the attribute names of a block in order, for a stable explain trace.
+func sortedAttributeNames(attributes map[string]*tfjson.SchemaAttribute) []string {
+	names := make([]string, 0, len(attributes))
+	for name := range attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// providerSource returns the lowercased "namespace/name" of a provider schema key such as
+// "registry.terraform.io/hashicorp/aws".
+func providerSource(providerKey string) string {
+	parts := strings.Split(strings.ToLower(providerKey), "/")
+	if len(parts) < 2 {
+		return strings.ToLower(providerKey)
+	}
+	return parts[len(parts)-2] + "/" + parts[len(parts)-1]
+}
+
+// joinPath appends name to a dotted attribute path.
+func joinPath(path, name string) string {
+	if path == "" {
+		return name
+	}
+	return path + "." + name
+}
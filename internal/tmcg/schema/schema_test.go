@@ -1,6 +1,7 @@
 package schema
 
 import (
+	"bytes"
 	"fmt"
 	"testing"
 
@@ -295,3 +296,303 @@ func TestRemoveComputedAttributesFromBlock(t *testing.T) {
 		})
 	}
 }
+
+// TestFilterSchemaKeepsConfigSchema tests that the provider configuration schema survives filtering
+func TestFilterSchemaKeepsConfigSchema(t *testing.T) {
+	manager := NewSchemaManager(&MockLogger{})
+
+	configSchema := &tfjson.Schema{
+		Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"region": {AttributeType: cty.String, Optional: true},
+			},
+		},
+	}
+	mockProviderSchemas := &tfjson.ProviderSchemas{
+		FormatVersion: "1.0",
+		Schemas: map[string]*tfjson.ProviderSchema{
+			"registry.terraform.io/hashicorp/aws": {
+				ConfigSchema: configSchema,
+				ResourceSchemas: map[string]*tfjson.Schema{
+					"aws_instance": {Block: &tfjson.SchemaBlock{}},
+					"aws_vpc":      {Block: &tfjson.SchemaBlock{}},
+				},
+			},
+			"registry.terraform.io/hashicorp/random": {
+				ConfigSchema:    &tfjson.Schema{Block: &tfjson.SchemaBlock{}},
+				ResourceSchemas: map[string]*tfjson.Schema{"random_id": {Block: &tfjson.SchemaBlock{}}},
+			},
+		},
+	}
+
+	filteredSchema := manager.FilterSchema(mockProviderSchemas, []tmcgParsing.Resource{{Name: "aws_instance"}})
+
+	assert.Len(t, filteredSchema.Schemas, 1)
+	awsSchema := filteredSchema.Schemas["registry.terraform.io/hashicorp/aws"]
+	assert.Same(t, configSchema, awsSchema.ConfigSchema)
+	assert.Len(t, awsSchema.ResourceSchemas, 1)
+	assert.Contains(t, awsSchema.ResourceSchemas, "aws_instance")
+}
+
+// TestFilterSchemaSkipsUnrequestedProviders tests that providers without requested resources are not processed,
+// even when they define a resource of the same name
+func TestFilterSchemaSkipsUnrequestedProviders(t *testing.T) {
+	mockLogger := &MockLogger{}
+	manager := NewSchemaManager(mockLogger)
+
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	mockProviderSchemas := &tfjson.ProviderSchemas{
+		FormatVersion: "1.0",
+		Schemas: map[string]*tfjson.ProviderSchema{
+			"registry.terraform.io/hashicorp/aws": {
+				ResourceSchemas: map[string]*tfjson.Schema{
+					"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+						"arn": {AttributeType: cty.String, Computed: true},
+					}}},
+				},
+			},
+			"registry.terraform.io/example/mirror": {
+				ResourceSchemas: map[string]*tfjson.Schema{
+					"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+						"id": {AttributeType: cty.String, Computed: true},
+					}}},
+				},
+			},
+		},
+	}
+
+	filteredSchema := manager.FilterSchema(mockProviderSchemas, []tmcgParsing.Resource{{Name: "aws_instance", Provider: aws}})
+	assert.Len(t, filteredSchema.Schemas, 1)
+	assert.Contains(t, filteredSchema.Schemas, "registry.terraform.io/hashicorp/aws")
+	assert.Contains(t, mockLogger.Messages, "Skipping provider not referenced by any requested resource: registry.terraform.io/example/mirror")
+
+	manager.RemoveComputedAttributes(filteredSchema)
+	assert.Equal(t, []string{"aws_instance.arn"}, manager.RemovedComputedAttributes())
+}
+
+// BenchmarkFilterSchemaUnrequestedProvider measures filtering when a large unrequested provider is present
+func BenchmarkFilterSchemaUnrequestedProvider(b *testing.B) {
+	manager := NewSchemaManager(&MockLogger{})
+
+	large := make(map[string]*tfjson.Schema, 5000)
+	for i := 0; i < 5000; i++ {
+		large[fmt.Sprintf("large_resource_%d", i)] = &tfjson.Schema{Block: &tfjson.SchemaBlock{}}
+	}
+	providerSchemas := &tfjson.ProviderSchemas{
+		Schemas: map[string]*tfjson.ProviderSchema{
+			"registry.terraform.io/hashicorp/aws": {ResourceSchemas: map[string]*tfjson.Schema{"aws_instance": {Block: &tfjson.SchemaBlock{}}}},
+			"registry.terraform.io/example/large": {ResourceSchemas: large},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+
+	for i := 0; i < b.N; i++ {
+		manager.FilterSchema(providerSchemas, resources)
+	}
+}
+
+// TestRemoveComputedAttributesPrunesEmptyBlocks tests that optional blocks left empty by removing
+// computed-only attributes are pruned, including a parent whose only content was such a block, while
+// required blocks and blocks that were empty in the schema are kept.
+func TestRemoveComputedAttributesPrunesEmptyBlocks(t *testing.T) {
+	providerSchemas := &tfjson.ProviderSchemas{
+		Schemas: map[string]*tfjson.ProviderSchema{
+			"registry.terraform.io/hashicorp/aws": {
+				ResourceSchemas: map[string]*tfjson.Schema{
+					"aws_instance": {Block: &tfjson.SchemaBlock{
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"ami": {AttributeType: cty.String, Required: true},
+						},
+						NestedBlocks: map[string]*tfjson.SchemaBlockType{
+							"capacity_reservation": {NestingMode: "list", Block: &tfjson.SchemaBlock{
+								NestedBlocks: map[string]*tfjson.SchemaBlockType{
+									"target": {NestingMode: "list", Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+										"arn": {AttributeType: cty.String, Computed: true},
+									}}},
+								},
+							}},
+							"timeouts": {NestingMode: "single", MinItems: 1, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+								"status": {AttributeType: cty.String, Computed: true},
+							}}},
+							"enclave_options": {NestingMode: "single", Block: &tfjson.SchemaBlock{}},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	var trace bytes.Buffer
+	manager := NewSchemaManager(&MockLogger{})
+	manager.SetExplainWriter(&trace)
+	block := manager.RemoveComputedAttributes(providerSchemas).Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"].Block
+
+	assert.NotContains(t, block.NestedBlocks, "capacity_reservation")
+	assert.Contains(t, block.NestedBlocks, "timeouts")
+	assert.Contains(t, block.NestedBlocks, "enclave_options")
+	assert.Equal(t, []string{"aws_instance.capacity_reservation.target.arn", "aws_instance.timeouts.status"}, manager.RemovedComputedAttributes())
+	assert.Contains(t, trace.String(), `stage=computed path=aws_instance.capacity_reservation.target decision=dropped reason="optional block left empty by computed-only attributes"`)
+	assert.Contains(t, trace.String(), `stage=computed path=aws_instance.capacity_reservation decision=dropped reason="optional block left empty by computed-only attributes"`)
+	assert.Contains(t, trace.String(), `stage=computed path=aws_instance.timeouts decision=kept reason="required block left empty by computed-only attributes"`)
+}
+
+// TestRemoveComputedAttributesDropsID tests that an optional+computed top-level id is dropped
+// unless it is kept explicitly, while nested id attributes are left alone.
+func TestRemoveComputedAttributesDropsID(t *testing.T) {
+	newSchemas := func() *tfjson.ProviderSchemas {
+		return &tfjson.ProviderSchemas{
+			Schemas: map[string]*tfjson.ProviderSchema{
+				"registry.terraform.io/hashicorp/aws": {
+					ResourceSchemas: map[string]*tfjson.Schema{
+						"aws_instance": {Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"id":  {AttributeType: cty.String, Optional: true, Computed: true},
+								"ami": {AttributeType: cty.String, Required: true},
+							},
+							NestedBlocks: map[string]*tfjson.SchemaBlockType{
+								"network_interface": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+									"id": {AttributeType: cty.String, Optional: true, Computed: true},
+								}}},
+							},
+						}},
+					},
+				},
+			},
+		}
+	}
+
+	logger := &MockLogger{}
+	manager := NewSchemaManager(logger)
+	block := manager.RemoveComputedAttributes(newSchemas()).Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"].Block
+	assert.NotContains(t, block.Attributes, "id")
+	assert.Contains(t, block.Attributes, "ami")
+	assert.Contains(t, block.NestedBlocks["network_interface"].Block.Attributes, "id")
+	assert.Contains(t, logger.Messages, "Dropped the optional id attribute of aws_instance; use --keep-id to generate a variable for it")
+
+	manager = NewSchemaManager(&MockLogger{})
+	manager.SetKeepID(true)
+	block = manager.RemoveComputedAttributes(newSchemas()).Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"].Block
+	assert.Contains(t, block.Attributes, "id")
+}
+
+// TestExplainTrace tests that the explain writer receives the reason for each attribute decision,
+// including an attribute dropped as computed-only.
+func TestExplainTrace(t *testing.T) {
+	schemas := &tfjson.ProviderSchemas{
+		Schemas: map[string]*tfjson.ProviderSchema{
+			"registry.terraform.io/hashicorp/aws": {
+				ResourceSchemas: map[string]*tfjson.Schema{
+					"aws_instance": {Block: &tfjson.SchemaBlock{
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"ami":       {AttributeType: cty.String, Required: true},
+							"arn":       {AttributeType: cty.String, Computed: true},
+							"subnet_id": {AttributeType: cty.String, Optional: true, Computed: true},
+						},
+					}},
+				},
+			},
+		},
+	}
+
+	var trace bytes.Buffer
+	manager := NewSchemaManager(&MockLogger{})
+	manager.SetExplainWriter(&trace)
+	resources := []tmcgParsing.Resource{{Name: "aws_instance"}}
+	cleaned := manager.RemoveComputedAttributes(manager.FilterSchema(schemas, resources))
+	manager.RemoveInvalidAttributesFromSchema(cleaned.Schemas, map[string][]string{"aws_instance.this": {"subnet_id"}})
+
+	assert.Equal(t, `stage=filter path=aws_instance decision=kept reason="requested resource of registry.terraform.io/hashicorp/aws"
+stage=computed path=aws_instance.ami decision=kept reason="required"
+stage=computed path=aws_instance.arn decision=dropped reason="computed-only"
+stage=computed path=aws_instance.subnet_id decision=kept reason="optional+computed"
+stage=invalid path=aws_instance.subnet_id decision=dropped reason="rejected by terraform validate"
+`, trace.String())
+}
+
+// TestPrepare tests that Prepare filters the schemas and removes computed-only attributes according to its options.
+func TestPrepare(t *testing.T) {
+	newSchemas := func() *tfjson.ProviderSchemas {
+		return &tfjson.ProviderSchemas{
+			FormatVersion: "1.0",
+			Schemas: map[string]*tfjson.ProviderSchema{
+				"registry.terraform.io/hashicorp/aws": {
+					ResourceSchemas: map[string]*tfjson.Schema{
+						"aws_instance": {Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"id":  {AttributeType: cty.String, Optional: true, Computed: true},
+								"ami": {AttributeType: cty.String, Required: true},
+								"arn": {AttributeType: cty.String, Computed: true},
+							},
+						}},
+						"aws_vpc": {Block: &tfjson.SchemaBlock{}},
+					},
+				},
+				"registry.terraform.io/hashicorp/random": {
+					ResourceSchemas: map[string]*tfjson.Schema{"random_id": {Block: &tfjson.SchemaBlock{}}},
+				},
+			},
+		}
+	}
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "single", Provider: aws}}
+
+	prepared, err := NewSchemaManager(&MockLogger{}).Prepare(newSchemas(), resources, PrepareOptions{})
+	assert.NoError(t, err)
+	assert.Equal(t, "1.0", prepared.FormatVersion)
+	assert.Len(t, prepared.Schemas, 1)
+	awsSchema := prepared.Schemas["registry.terraform.io/hashicorp/aws"]
+	assert.Len(t, awsSchema.ResourceSchemas, 1)
+	assert.Equal(t, []string{"ami"}, sortedAttributeNames(awsSchema.ResourceSchemas["aws_instance"].Block.Attributes))
+
+	manager := NewSchemaManager(&MockLogger{})
+	prepared, err = manager.Prepare(newSchemas(), resources, PrepareOptions{KeepID: true})
+	assert.NoError(t, err)
+	attributes := prepared.Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"].Block.Attributes
+	assert.Equal(t, []string{"ami", "id"}, sortedAttributeNames(attributes))
+
+	// The options do not outlive the call
+	cleaned := manager.RemoveComputedAttributes(manager.FilterSchema(newSchemas(), resources))
+	assert.Equal(t, []string{"ami"}, sortedAttributeNames(cleaned.Schemas["registry.terraform.io/hashicorp/aws"].ResourceSchemas["aws_instance"].Block.Attributes))
+}
+
+// TestFilterSchemaNil tests that a nil or provider-less schema document yields an empty result and an
+// error log instead of a panic, in both FilterSchema and RemoveComputedAttributes, and an error from Prepare.
+func TestFilterSchemaNil(t *testing.T) {
+	resources := []tmcgParsing.Resource{{Name: "aws_instance"}}
+
+	logger := &MockLogger{}
+	manager := NewSchemaManager(logger)
+	filtered := manager.FilterSchema(nil, resources)
+	assert.NotNil(t, filtered)
+	assert.Empty(t, filtered.Schemas)
+	assert.Contains(t, logger.Messages, "No provider schemas to filter: the schema document is empty or malformed")
+
+	cleaned := manager.RemoveComputedAttributes(nil)
+	assert.NotNil(t, cleaned)
+	assert.Empty(t, cleaned.Schemas)
+	assert.Contains(t, logger.Messages, "No provider schemas to remove computed attributes from: the schema document is empty or malformed")
+
+	logger = &MockLogger{}
+	manager = NewSchemaManager(logger)
+	filtered = manager.FilterSchema(&tfjson.ProviderSchemas{FormatVersion: "1.0"}, resources)
+	assert.Equal(t, &tfjson.ProviderSchemas{FormatVersion: "1.0", Schemas: map[string]*tfjson.ProviderSchema{}}, filtered)
+	assert.Contains(t, logger.Messages, "No provider schemas to filter: the schema document has no providers")
+	assert.Empty(t, manager.RemoveComputedAttributes(filtered).Schemas)
+
+	// Prepare reports what the passes only log
+	for _, document := range []*tfjson.ProviderSchemas{nil, {FormatVersion: "1.0"}} {
+		_, err := manager.Prepare(document, resources, PrepareOptions{})
+		assert.ErrorContains(t, err, "no provider schemas to prepare")
+	}
+
+	// Providers and resources without a schema are skipped
+	prepared, err := manager.Prepare(&tfjson.ProviderSchemas{Schemas: map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws":    {ResourceSchemas: map[string]*tfjson.Schema{"aws_instance": nil}},
+		"registry.terraform.io/hashicorp/random": nil,
+	}}, resources, PrepareOptions{})
+	assert.NoError(t, err)
+	assert.Empty(t, prepared.Schemas)
+}
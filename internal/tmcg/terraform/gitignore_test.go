@@ -0,0 +1,30 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateGitignore tests that a .gitignore is created once and never overwritten.
+func TestCreateGitignore(t *testing.T) {
+	dir := t.TempDir()
+	tf := NewTf(&MockLogger{})
+
+	require.NoError(t, tf.CreateGitignore(dir))
+	content, err := os.ReadFile(filepath.Join(dir, ".gitignore"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), ".terraform/\n")
+	assert.Contains(t, string(content), "*.tfstate\n")
+	assert.Contains(t, string(content), "crash.log\n")
+
+	existing := filepath.Join(t.TempDir(), ".gitignore")
+	require.NoError(t, os.WriteFile(existing, []byte("custom\n"), 0644))
+	require.NoError(t, tf.CreateGitignore(filepath.Dir(existing)))
+	content, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "custom\n", string(content))
+}
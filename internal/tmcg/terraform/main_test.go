@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"sync"
 	"testing"
 	"tmcg/internal/tmcg/logging"
 
@@ -142,3 +143,24 @@ func TestValidateTerraformBinary(t *testing.T) {
 		assert.Contains(t, err.Error(), "terraform binary not found")
 	})
 }
+
+// readFormatted reads a generated file and returns it formatted as terraform fmt would.
+func readFormatted(t *testing.T, path string) string {
+	t.Helper()
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	return string(hclwrite.Format(content))
+}
+
+// MockLogger records log messages for assertions
+type MockLogger struct {
+	Messages []string
+	mu       sync.Mutex
+}
+
+// Log stores the formatted message with its level in Messages
+func (m *MockLogger) Log(level string, format string, args ...interface{}) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.Messages = append(m.Messages, fmt.Sprintf("[%s] %s", level, fmt.Sprintf(format, args...)))
+}
@@ -6,14 +6,20 @@ import (
 	"os"
 	"path/filepath"
 	"strings"
+	"sync/atomic"
 	"testing"
+	"time"
 
 	tmcgParsing "tmcg/internal/tmcg/parsing"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
+	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 	"github.com/zclconf/go-cty/cty"
 )
 
@@ -404,3 +410,1143 @@ func TestCreateMainTF(t *testing.T) {
 		})
 	}
 }
+
+// TestCreateProviderGroupedTF tests that resources and variables are grouped into one file per provider.
+func TestCreateProviderGroupedTF(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	azurerm := tmcgParsing.Provider{Namespace: "hashicorp", Name: "azurerm", NamespaceLower: "hashicorp", NameLower: "azurerm"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "azurerm_resource_group", Mode: "multiple", Provider: azurerm},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+		"registry.terraform.io/hashicorp/azurerm": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"azurerm_resource_group": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	err := testTerraform.CreateProviderGroupedTF(dir, cleanedSchema, resources, false)
+	assert.NoError(t, err)
+
+	entries, err := os.ReadDir(dir)
+	assert.NoError(t, err)
+	names := []string{}
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	assert.Equal(t, []string{"aws.tf", "aws_variables.tf", "azurerm.tf", "azurerm_variables.tf"}, names)
+
+	awsContent := readFormatted(t, filepath.Join(dir, "aws.tf"))
+	assert.Contains(t, awsContent, `resource "aws_instance" "this"`)
+	assert.NotContains(t, awsContent, "azurerm_resource_group")
+
+	azurermVariables := readFormatted(t, filepath.Join(dir, "azurerm_variables.tf"))
+	assert.Contains(t, azurermVariables, `variable "resource_groups"`)
+	assert.NotContains(t, azurermVariables, `variable "ami"`)
+}
+
+// TestCreateMainTFMixedCaseProviderKey tests the case-insensitive fallback for provider schema keys.
+func TestCreateMainTFMixedCaseProviderKey(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "azapi_resource",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "Azure", Name: "azapi", NamespaceLower: "azure", NameLower: "azapi"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/Azure/azapi": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"azapi_resource": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"type": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	mockLogger := &MockLogger{}
+	tf := NewTf(mockLogger)
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	assert.Contains(t, readFormatted(t, filepath.Join(dir, "main.tf")), `resource "azapi_resource" "this"`)
+	assert.Contains(t, readFormatted(t, filepath.Join(dir, "variables.tf")), `variable "type"`)
+	assert.NotContains(t, mockLogger.Messages, "[warn] No schema found for provider: registry.terraform.io/azure/azapi")
+}
+
+// TestCreateMainTFCustomLabel tests that a resource's custom label is used as its block label.
+func TestCreateMainTFCustomLabel(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "multiple",
+		Label:    "web",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, NewTf(&MockLogger{}).CreateMainTF(dir, cleanedSchema, resources))
+	assert.Contains(t, readFormatted(t, filepath.Join(dir, "main.tf")), `resource "aws_instance" "web"`)
+}
+
+// TestForEachMap tests the map iteration shape in main.tf and variables.tf.
+func TestForEachMap(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_security_group",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_security_group": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name":        {AttributeType: cty.String, Optional: true},
+					"description": {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{ForEachMap: map[string]bool{"aws_security_group": true}})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "for_each    = coalesce(var.security_groups, {})")
+	assert.Contains(t, mainContent, "name        = each.key")
+	assert.Contains(t, mainContent, "description = each.value.description")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "type = map(object({")
+	assert.Contains(t, variablesContent, "description = optional(string)")
+	assert.NotContains(t, variablesContent, "name = optional(string)")
+}
+
+// TestCreateMainTFResourceProvider tests that the provider meta-argument is set for the targeted resource only.
+func TestCreateMainTFResourceProvider(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws", ConfigurationAliases: []string{"west"}}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "multiple", Provider: aws},
+		{Name: "aws_vpc", Mode: "single", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Required: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"cidr_block": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{ResourceProviders: map[string]string{"aws_instance": "aws.west"}})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, content, "resource \"aws_instance\" \"this\" {\n  for_each = { for i in coalesce(var.instances, []) : i.name => i }\n  provider = aws.west\n")
+	assert.Equal(t, 1, strings.Count(content, "provider ="))
+}
+
+// TestCreateMainTFPerKeyProvider tests that multiple mode resources index the provider alias by each.key.
+func TestCreateMainTFPerKeyProvider(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws", ConfigurationAliases: []string{"by_region"}}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "multiple", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{PerKeyProviders: map[string]string{"aws_instance": "aws.by_region"}})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, content, "resource \"aws_instance\" \"this\" {\n  for_each = { for i in coalesce(var.instances, []) : i.name => i }\n  provider = aws.by_region[each.key]\n")
+}
+
+// TestSingleRefStyles tests the bare, prefixed and object single-mode reference styles.
+func TestSingleRefStyles(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"ami": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"root_block_device": {MaxItems: 1, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+							"volume_size": {AttributeType: cty.Number, Optional: true},
+						}}},
+					},
+				}},
+			},
+		},
+	}
+
+	tests := []struct {
+		style             string
+		expectedMain      []string
+		expectedVariables []string
+	}{
+		{
+			style:             tmcgParsing.SingleRefBare,
+			expectedMain:      []string{"ami = var.ami", "for_each = can(coalesce(var.root_block_device)) ? flatten([var.root_block_device]) : []"},
+			expectedVariables: []string{"variable \"ami\" {\n  type = string\n}", `variable "root_block_device"`},
+		},
+		{
+			style:             tmcgParsing.SingleRefPrefixed,
+			expectedMain:      []string{"ami = var.aws_instance_ami", "for_each = can(coalesce(var.aws_instance_root_block_device)) ? flatten([var.aws_instance_root_block_device]) : []"},
+			expectedVariables: []string{"variable \"aws_instance_ami\" {\n  type = string\n}", `variable "aws_instance_root_block_device"`},
+		},
+		{
+			style:             tmcgParsing.SingleRefObject,
+			expectedMain:      []string{"ami = var.aws_instance.ami", "for_each = can(coalesce(var.aws_instance.root_block_device)) ? flatten([var.aws_instance.root_block_device]) : []"},
+			expectedVariables: []string{"variable \"aws_instance\" {\n  type = object({", "ami = string"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.style, func(t *testing.T) {
+			tf := NewTfWithOptions(&MockLogger{}, Options{SingleRefStyle: test.style})
+
+			dir := t.TempDir()
+			require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+			require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+			mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+			for _, expected := range test.expectedMain {
+				assert.Contains(t, mainContent, expected)
+			}
+			variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+			for _, expected := range test.expectedVariables {
+				assert.Contains(t, variablesContent, expected)
+			}
+		})
+	}
+}
+
+// TestKeyVar compares keying multiple-mode resources on the name attribute with an external map key.
+func TestKeyVar(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_security_group",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_security_group": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name":        {AttributeType: cty.String, Optional: true},
+					"description": {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name            string
+		keyVar          bool
+		expectedForEach string
+		expectedType    string
+	}{
+		{"name key", false, "for_each    = { for i in coalesce(var.security_groups, []) : i.name => i }", "type = list(object({"},
+		{"external key", true, "for_each    = coalesce(var.security_groups, {})", "type = map(object({"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := NewTfWithOptions(&MockLogger{}, Options{KeyVar: test.keyVar})
+
+			dir := t.TempDir()
+			require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+			require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+			mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+			assert.Contains(t, mainContent, test.expectedForEach)
+			assert.Contains(t, mainContent, "name        = each.value.name")
+
+			variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+			assert.Contains(t, variablesContent, test.expectedType)
+			assert.Contains(t, variablesContent, "name        = optional(string)")
+		})
+	}
+}
+
+// TestCreateMainTFShortIterators tests per-level iterator names on nested dynamic blocks.
+func TestCreateMainTFShortIterators(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"ebs_block_device": {NestingMode: "set", Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"device_name": {AttributeType: cty.String, Required: true},
+							},
+							NestedBlocks: map[string]*tfjson.SchemaBlockType{
+								"encryption": {NestingMode: "list", Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+									"kms_key_id": {AttributeType: cty.String, Optional: true},
+								}}},
+							},
+						}},
+					},
+				}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{ShortIterators: true})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, content, "iterator = it\n")
+	assert.Contains(t, content, "device_name = it.value.device_name")
+	assert.Contains(t, content, "for_each = can(coalesce(it.value.encryption)) ? flatten([it.value.encryption]) : []")
+	assert.Contains(t, content, "iterator = it2\n")
+	assert.Contains(t, content, "kms_key_id = it2.value.kms_key_id")
+	assert.NotContains(t, content, "ebs_block_device.value")
+}
+
+// TestSameTypeSingleLabels tests two single-mode instances of one type with label-prefixed variables.
+func TestSameTypeSingleLabels(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Label: "web", Provider: aws},
+		{Name: "aws_instance", Mode: "single", Label: "db", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTf(&MockLogger{})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "resource \"aws_instance\" \"web\" {\n  ami = var.web_ami\n}")
+	assert.Contains(t, mainContent, "resource \"aws_instance\" \"db\" {\n  ami = var.db_ami\n}")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, `variable "web_ami"`)
+	assert.Contains(t, variablesContent, `variable "db_ami"`)
+	assert.NotContains(t, variablesContent, `variable "ami"`)
+}
+
+// TestNoCoalesce tests that for_each expressions drop their null guards.
+func TestNoCoalesce(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_security_group",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_security_group": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"name": {AttributeType: cty.String, Optional: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"ingress": {NestingMode: tfjson.SchemaNestingModeSet, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+							"from_port": {AttributeType: cty.Number, Optional: true},
+						}}},
+					},
+				}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{NoCoalesce: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "for_each = { for i in var.security_groups : i.name => i }")
+	assert.Contains(t, mainContent, "for_each = flatten([each.value.ingress])")
+	assert.NotContains(t, mainContent, "coalesce")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "default = []")
+}
+
+// TestKeyModes tests the for_each expression of list variables for each key mode.
+func TestKeyModes(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_security_group",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_security_group": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{}, "for_each = { for i in coalesce(var.security_groups, []) : i.name => i }"},
+		{Options{KeyMode: tmcgParsing.KeyModeName}, "for_each = { for i in coalesce(var.security_groups, []) : i.name => i }"},
+		{Options{KeyMode: tmcgParsing.KeyModeIndex}, "for_each = { for idx, i in coalesce(var.security_groups, []) : idx => i }"},
+		{Options{KeyMode: tmcgParsing.KeyModeCoalesce}, "for_each = { for idx, i in coalesce(var.security_groups, []) : (i.name != null ? i.name : tostring(idx)) => i }"},
+		{Options{KeyMode: tmcgParsing.KeyModeIndex, NoCoalesce: true}, "for_each = { for idx, i in var.security_groups : idx => i }"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.opts.KeyMode, func(t *testing.T) {
+			content, err := NewTfWithOptions(&MockLogger{}, tt.opts).RenderMainTF(cleanedSchema, resources)
+			require.NoError(t, err)
+			assert.Contains(t, string(hclwrite.Format(content)), tt.expected)
+		})
+	}
+}
+
+// TestValidateKeyAttributes tests checking the key_attr of resources against the filtered schema.
+func TestValidateKeyAttributes(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_security_group": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name":        {AttributeType: cty.String, Required: true},
+					"description": {AttributeType: cty.String, Optional: true},
+					"port":        {AttributeType: cty.Number, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name      string
+		opts      Options
+		iteration string
+		keyAttr   string
+		expected  string
+	}{
+		{"required string", Options{}, "", "name", ""},
+		{"missing", Options{}, "", "vpc_id", "key_attr vpc_id of resource aws_security_group is not a settable attribute of its schema, or is excluded"},
+		{"not a string", Options{}, "", "port", "key_attr port of resource aws_security_group must be a string attribute"},
+		{"optional", Options{}, "", "description", "key_attr description of resource aws_security_group is optional, so keys may be null; use a required attribute or --key-mode coalesce"},
+		{"optional with coalesce", Options{KeyMode: tmcgParsing.KeyModeCoalesce}, "", "description", ""},
+		{"optional in a map", Options{}, tmcgParsing.IterationMap, "description", ""},
+		{"not a string in a map", Options{}, tmcgParsing.IterationMap, "port", "key_attr port of resource aws_security_group must be a string attribute"},
+		{"unused by index keys", Options{KeyMode: tmcgParsing.KeyModeIndex}, "", "port", ""},
+		{"unused by count", Options{}, tmcgParsing.IterationCount, "vpc_id", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resources := []tmcgParsing.Resource{{
+				Name:      "aws_security_group",
+				Mode:      "multiple",
+				Iteration: tt.iteration,
+				KeyAttr:   tt.keyAttr,
+				Provider:  tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+			}}
+			err := NewTfWithOptions(&MockLogger{}, tt.opts).ValidateKeyAttributes(cleanedSchema, resources)
+			if tt.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tt.expected)
+			}
+		})
+	}
+}
+
+// TestSkipped tests that skipped resources and blocks are collected once across the rendered files.
+func TestSkipped(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "multiple", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"ami": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"ebs_block_device": {NestingMode: tfjson.SchemaNestingModeList},
+					},
+				}},
+			},
+		},
+	}
+
+	logger := &MockLogger{}
+	tf := NewTf(logger)
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	expected := []string{
+		"No schema found for resource: aws_vpc with provider: hashicorp/aws",
+		"Skipping invalid nested block: aws_instance.ebs_block_device",
+	}
+	assert.Equal(t, expected, tf.Skipped())
+	assert.Contains(t, logger.Messages, "[warn] No schema found for resource: aws_vpc with provider: hashicorp/aws")
+
+	// Parallel rendering reports the same sorted list
+	tf = NewTfWithOptions(&MockLogger{}, Options{ParallelResources: 2})
+	require.NoError(t, tf.CreateMainTF(t.TempDir(), cleanedSchema, resources))
+	assert.Equal(t, expected, tf.Skipped())
+}
+
+// TestTrimProviderPrefix tests single-mode block variable names with and without the de-prefixed resource name.
+func TestTrimProviderPrefix(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"ami": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"root_block_device": {MaxItems: 1, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+							"volume_size": {AttributeType: cty.Number, Optional: true},
+						}}},
+					},
+				}},
+			},
+		},
+	}
+
+	tests := []struct {
+		name             string
+		trim             bool
+		expectedVariable string
+	}{
+		{name: "without toggle", trim: false, expectedVariable: "root_block_device"},
+		{name: "with toggle", trim: true, expectedVariable: "instance_root_block_device"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			tf := NewTfWithOptions(&MockLogger{}, Options{TrimProviderPrefix: test.trim})
+
+			dir := t.TempDir()
+			require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+			require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+			mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+			assert.Contains(t, mainContent, "ami = var.ami")
+			assert.Contains(t, mainContent, fmt.Sprintf("flatten([var.%s])", test.expectedVariable))
+
+			variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+			assert.Contains(t, variablesContent, `variable "ami"`)
+			assert.Contains(t, variablesContent, fmt.Sprintf("variable %q", test.expectedVariable))
+		})
+	}
+}
+
+// TestExtraHCL tests that raw snippets are appended to the resource body after generated attributes.
+func TestExtraHCL(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{ExtraHCL: map[string][]string{
+		"aws_instance": {"lifecycle { create_before_destroy = true }"},
+	}})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "resource \"aws_instance\" \"this\" {\n  ami = var.ami\n\n  lifecycle { create_before_destroy = true }\n}")
+
+	_, diags := hclsyntax.ParseConfig([]byte(mainContent), "main.tf", hcl.InitialPos)
+	assert.False(t, diags.HasErrors(), diags.Error())
+}
+
+// TestToggleable tests the count expression, the enabled variable and the guarded id output.
+func TestToggleable(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+					"id":  {AttributeType: cty.String, Optional: true, Computed: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{Toggleable: map[string]bool{"aws_instance": true}})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+	require.NoError(t, tf.CreateOutputsTF(dir, cleanedSchema, resources))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "resource \"aws_instance\" \"this\" {\n  count = var.aws_instance_enabled ? 1 : 0\n  ami   = var.ami")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "variable \"aws_instance_enabled\" {\n  description = \"Whether to create the aws_instance resource\"\n  type        = bool\n  default     = true\n}")
+
+	outputsContent := readFormatted(t, filepath.Join(dir, "outputs.tf"))
+	assert.Contains(t, outputsContent, "value       = try(aws_instance.this[0].id, null)")
+}
+
+// TestToggleOnNull tests the count expression of object-style single resources, their references and the
+// null default of the object variable.
+func TestToggleOnNull(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"ami": {AttributeType: cty.String, Required: true},
+						"id":  {AttributeType: cty.String, Optional: true, Computed: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"root_block_device": {NestingMode: tfjson.SchemaNestingModeList, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+							"volume_size": {AttributeType: cty.Number, Optional: true},
+						}}},
+					},
+				}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{SingleRefStyle: tmcgParsing.SingleRefObject, ToggleOnNull: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+	require.NoError(t, tf.CreateOutputsTF(dir, cleanedSchema, resources))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "resource \"aws_instance\" \"this\" {\n  count = var.aws_instance == null ? 0 : 1\n  ami   = var.aws_instance.ami\n")
+	assert.Contains(t, mainContent, "for_each = can(coalesce(var.aws_instance.root_block_device)) ? flatten([var.aws_instance.root_block_device]) : []")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "  })\n  default = null\n}")
+
+	outputsContent := readFormatted(t, filepath.Join(dir, "outputs.tf"))
+	assert.Contains(t, outputsContent, "value       = try(aws_instance.this[0].id, null)")
+
+	// Other reference styles have no object to test for null
+	tf = NewTfWithOptions(&MockLogger{}, Options{SingleRefStyle: tmcgParsing.SingleRefPrefixed, ToggleOnNull: true})
+	dir = t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	assert.NotContains(t, readFormatted(t, filepath.Join(dir, "main.tf")), "count")
+}
+
+// TestDefaultsLocal tests that single-mode optional attributes fall back to a centralized locals block.
+func TestDefaultsLocal(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":           {AttributeType: cty.String, Required: true},
+					"instance_type": {AttributeType: cty.String, Optional: true},
+					"monitoring":    {AttributeType: cty.Bool, Optional: true},
+					"cpu_count":     {AttributeType: cty.Number, Optional: true},
+					"tags":          {AttributeType: cty.Map(cty.String), Optional: true},
+					"metadata":      {AttributeType: cty.Object(map[string]cty.Type{"key": cty.String}), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{
+		DefaultsLocal: true,
+		Defaults:      map[string]cty.Value{"aws_instance.cpu_count": cty.NumberIntVal(2)},
+	})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.True(t, strings.HasPrefix(mainContent, "locals {\n  defaults = {\n"), mainContent)
+	assert.Contains(t, mainContent, "    cpu_count     = 2\n")
+	assert.Contains(t, mainContent, "    instance_type = \"\"\n")
+	assert.Contains(t, mainContent, "    monitoring    = false\n")
+	assert.Contains(t, mainContent, "    tags          = {}\n")
+	assert.NotContains(t, mainContent, "metadata =")
+	assert.Contains(t, mainContent, "ami           = var.ami\n")
+	assert.Contains(t, mainContent, "cpu_count     = coalesce(var.cpu_count, local.defaults.cpu_count)\n")
+	assert.Contains(t, mainContent, "instance_type = var.instance_type != null ? var.instance_type : local.defaults.instance_type\n")
+	assert.Contains(t, mainContent, "monitoring    = coalesce(var.monitoring, local.defaults.monitoring)\n")
+	assert.Contains(t, mainContent, "tags          = coalesce(var.tags, local.defaults.tags)\n")
+	assert.Contains(t, mainContent, "metadata      = var.metadata\n")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "variable \"cpu_count\" {\n  type    = number\n  default = null\n}")
+}
+
+// TestBlocksRequiredFirst tests that required attributes precede optional ones in dynamic block content.
+func TestBlocksRequiredFirst(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"name": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"ebs_block_device": {NestingMode: tfjson.SchemaNestingModeList, Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"delete_on_termination": {AttributeType: cty.Bool, Optional: true},
+								"device_name":           {AttributeType: cty.String, Required: true},
+								"encrypted":             {AttributeType: cty.Bool, Optional: true},
+								"volume_size":           {AttributeType: cty.Number, Required: true},
+							},
+						}},
+					},
+				}},
+			},
+		},
+	}
+
+	for _, requiredFirst := range []bool{false, true} {
+		tf := NewTfWithOptions(&MockLogger{}, Options{BlocksRequiredFirst: requiredFirst})
+		dir := t.TempDir()
+		require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+		content := readFormatted(t, filepath.Join(dir, "main.tf"))
+		order := []string{"delete_on_termination", "device_name", "encrypted", "volume_size"}
+		if requiredFirst {
+			order = []string{"device_name", "volume_size", "delete_on_termination", "encrypted"}
+		}
+		last := -1
+		for _, name := range order {
+			index := strings.Index(content, name+" ")
+			require.NotEqual(t, -1, index, name)
+			assert.Greater(t, index, last, "requiredFirst=%t: %s is out of order", requiredFirst, name)
+			last = index
+		}
+	}
+}
+
+// TestCreateMainTFHyphenatedProvider tests that google-beta resources keep their google_ variable names and
+// are bound to google-beta through the provider meta-argument.
+func TestCreateMainTFHyphenatedProvider(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "google_compute_instance",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "google-beta", NamespaceLower: "hashicorp", NameLower: "google-beta"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/google-beta": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"google_compute_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTf(&MockLogger{})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, content, "for_each = { for i in coalesce(var.compute_instances, []) : i.name => i }\n  provider = google-beta\n")
+}
+
+// TestCreateMainTFAmbiguousResourceType tests that resources whose type more than one provider exposes name
+// their provider explicitly, while unambiguous resources of the implied provider leave it out.
+func TestCreateMainTFAmbiguousResourceType(t *testing.T) {
+	google := tmcgParsing.Provider{Namespace: "hashicorp", Name: "google", NamespaceLower: "hashicorp", NameLower: "google"}
+	googleBeta := tmcgParsing.Provider{Namespace: "hashicorp", Name: "google-beta", NamespaceLower: "hashicorp", NameLower: "google-beta"}
+	resources := []tmcgParsing.Resource{
+		{Name: "google_compute_instance", Mode: "multiple", Label: "stable", Provider: google},
+		{Name: "google_compute_instance", Mode: "multiple", Label: "beta", Provider: googleBeta},
+		{Name: "google_storage_bucket", Mode: "multiple", Provider: google},
+	}
+	instanceSchema := &tfjson.Schema{Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+		"name": {AttributeType: cty.String, Required: true},
+	}}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/google": {
+			ResourceSchemas: map[string]*tfjson.Schema{"google_compute_instance": instanceSchema, "google_storage_bucket": instanceSchema},
+		},
+		"registry.terraform.io/hashicorp/google-beta": {
+			ResourceSchemas: map[string]*tfjson.Schema{"google_compute_instance": instanceSchema},
+		},
+	}
+
+	tf := NewTf(&MockLogger{})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Regexp(t, `resource "google_compute_instance" "stable" \{\n  for_each = .*\n  provider = google\n`, content)
+	assert.Regexp(t, `resource "google_compute_instance" "beta" \{\n  for_each = .*\n  provider = google-beta\n`, content)
+	assert.Regexp(t, `resource "google_storage_bucket" "this" \{\n  for_each = .*\n  name     = each.value.name\n`, content)
+	assert.Equal(t, 2, strings.Count(content, "provider ="))
+}
+
+// TestCreateMainTFSharedProviderName tests that resources of providers sharing a name in different
+// namespaces, such as hashicorp/aws and a fork in someorg/aws, name their provider by its local name.
+func TestCreateMainTFSharedProviderName(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws", LocalName: "hashicorp-aws"}
+	fork := tmcgParsing.Provider{Namespace: "someorg", Name: "aws", NamespaceLower: "someorg", NameLower: "aws", LocalName: "someorg-aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "multiple", Label: "upstream", Provider: aws},
+		{Name: "aws_instance", Mode: "multiple", Label: "fork", Provider: fork},
+	}
+	instanceSchema := &tfjson.Schema{Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+		"name": {AttributeType: cty.String, Required: true},
+	}}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {ResourceSchemas: map[string]*tfjson.Schema{"aws_instance": instanceSchema}},
+		"registry.terraform.io/someorg/aws":   {ResourceSchemas: map[string]*tfjson.Schema{"aws_instance": instanceSchema}},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, NewTf(&MockLogger{}).CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Regexp(t, `resource "aws_instance" "upstream" \{\n  for_each = .*\n  provider = hashicorp-aws\n`, content)
+	assert.Regexp(t, `resource "aws_instance" "fork" \{\n  for_each = .*\n  provider = someorg-aws\n`, content)
+}
+
+// TestPreconditions tests that preconditions are placed in a lifecycle block after the generated attributes
+// and blocks and before any extra HCL.
+func TestPreconditions(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"ami": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"timeouts": {NestingMode: tfjson.SchemaNestingModeSingle, Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"create": {AttributeType: cty.String, Optional: true},
+							},
+						}},
+					},
+				}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{
+		Preconditions: map[string][]tmcgParsing.Precondition{
+			"aws_instance.this": {
+				{Condition: `var.ami != ""`, ErrorMessage: "AMI required"},
+				{Condition: `startswith(var.ami, "ami-")`, ErrorMessage: "AMI IDs start with ami-"},
+			},
+		},
+		ExtraHCL: map[string][]string{"aws_instance": {"depends_on = []"}},
+	})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "main.tf"))
+	lifecycle := "  lifecycle {\n    precondition {\n      condition     = var.ami != \"\"\n      error_message = \"AMI required\"\n    }\n" +
+		"    precondition {\n      condition     = startswith(var.ami, \"ami-\")\n      error_message = \"AMI IDs start with ami-\"\n    }\n  }\n"
+	assert.Contains(t, content, lifecycle)
+	assert.Less(t, strings.Index(content, "dynamic \"timeouts\""), strings.Index(content, "lifecycle {"))
+	assert.Less(t, strings.Index(content, "lifecycle {"), strings.Index(content, "depends_on"))
+}
+
+// parallelTestInput builds many single and multiple mode resources with nested blocks for
+// comparing sequential and concurrent rendering
+func parallelTestInput(count int) (map[string]*tfjson.ProviderSchema, []tmcgParsing.Resource) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resourceSchemas := make(map[string]*tfjson.Schema, count)
+	resources := make([]tmcgParsing.Resource, 0, count)
+	for i := 0; i < count; i++ {
+		name := fmt.Sprintf("aws_resource_%03d", i)
+		resourceSchemas[name] = &tfjson.Schema{Block: &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"name": {AttributeType: cty.String, Required: true, Description: "Name of the resource"},
+				"tags": {AttributeType: cty.Map(cty.String), Optional: true},
+			},
+			NestedBlocks: map[string]*tfjson.SchemaBlockType{
+				"rule": {NestingMode: tfjson.SchemaNestingModeList, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"port": {AttributeType: cty.Number, Required: true},
+				}}},
+			},
+		}}
+		mode := "multiple"
+		if i%2 == 0 {
+			mode = "single"
+		}
+		resources = append(resources, tmcgParsing.Resource{Name: name, Mode: mode, Provider: aws})
+	}
+	return map[string]*tfjson.ProviderSchema{"registry.terraform.io/hashicorp/aws": {ResourceSchemas: resourceSchemas}}, resources
+}
+
+// TestRenderParallelResources tests that rendering resources concurrently produces the same
+// main.tf and variables.tf as rendering them in order.
+func TestRenderParallelResources(t *testing.T) {
+	cleanedSchema, resources := parallelTestInput(64)
+
+	sequential := NewTfWithOptions(&MockLogger{}, Options{})
+	parallel := NewTfWithOptions(&MockLogger{}, Options{ParallelResources: 8})
+
+	expectedMain, err := sequential.RenderMainTF(cleanedSchema, resources)
+	require.NoError(t, err)
+	actualMain, err := parallel.RenderMainTF(cleanedSchema, resources)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedMain), string(actualMain))
+
+	expectedVariables, err := sequential.RenderVariablesTF(cleanedSchema, resources, true)
+	require.NoError(t, err)
+	actualVariables, err := parallel.RenderVariablesTF(cleanedSchema, resources, true)
+	require.NoError(t, err)
+	assert.Equal(t, string(expectedVariables), string(actualVariables))
+}
+
+// TestRenderResourcesConcurrency tests that Concurrency caps the number of resources rendered at once.
+func TestRenderResourcesConcurrency(t *testing.T) {
+	_, resources := parallelTestInput(32)
+
+	for _, tc := range []struct {
+		concurrency int
+		expected    int
+	}{
+		{concurrency: 0, expected: 8},
+		{concurrency: 2, expected: 2},
+		{concurrency: 1, expected: 1},
+	} {
+		var active, peak atomic.Int32
+		tf := NewTfWithOptions(&MockLogger{}, Options{ParallelResources: 8, Concurrency: tc.concurrency})
+		tf.renderResources(resources, func(_ int, _ tmcgParsing.Resource) []byte {
+			current := active.Add(1)
+			for {
+				previous := peak.Load()
+				if current <= previous || peak.CompareAndSwap(previous, current) {
+					break
+				}
+			}
+			time.Sleep(5 * time.Millisecond)
+			active.Add(-1)
+			return nil
+		})
+		assert.LessOrEqual(t, peak.Load(), int32(tc.expected), "concurrency %d", tc.concurrency)
+		assert.Positive(t, peak.Load())
+	}
+}
+
+// BenchmarkRenderMainTF measures rendering many resources in order and with a worker pool
+func BenchmarkRenderMainTF(b *testing.B) {
+	cleanedSchema, resources := parallelTestInput(200)
+
+	for _, workers := range []int{0, 8} {
+		tf := NewTfWithOptions(&MockLogger{}, Options{ParallelResources: workers})
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				if _, err := tf.RenderMainTF(cleanedSchema, resources); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// TestAutoTags tests that the common tags are merged into the tag attribute each provider uses, tags for
+// aws and labels for google, and declared once, while resources without a tag attribute are left alone.
+func TestAutoTags(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	google := tmcgParsing.Provider{Namespace: "hashicorp", Name: "google", NamespaceLower: "hashicorp", NameLower: "google"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_eip_association", Mode: "multiple", Provider: aws},
+		{Name: "google_storage_bucket", Mode: "multiple", Provider: google},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":      {AttributeType: cty.String, Required: true},
+					"tags":     {AttributeType: cty.Map(cty.String), Optional: true},
+					"tags_all": {AttributeType: cty.Map(cty.String), Optional: true, Computed: true},
+				}}},
+				"aws_eip_association": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"instance_id": {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+		"registry.terraform.io/hashicorp/google": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"google_storage_bucket": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name":   {AttributeType: cty.String, Required: true},
+					"labels": {AttributeType: cty.Map(cty.String), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{AutoTags: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "tags     = merge(var.common_tags, var.tags)\n")
+	assert.Contains(t, mainContent, "tags_all = var.tags_all\n")
+	assert.Contains(t, mainContent, "labels   = merge(var.common_tags, each.value.labels)\n")
+	assert.NotContains(t, mainContent[strings.Index(mainContent, `resource "aws_eip_association"`):strings.Index(mainContent, `resource "google_storage_bucket"`)], "common_tags")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Equal(t, 1, strings.Count(variablesContent, `variable "common_tags"`))
+	assert.Contains(t, variablesContent, "variable \"common_tags\" {\n  description = \"Tags merged into the tags (or labels) of every resource that supports them\"\n  type        = map(string)\n  default     = {}\n}")
+
+	// Grouped files declare the variable in only one of them
+	dir = t.TempDir()
+	require.NoError(t, tf.CreateProviderGroupedTF(dir, cleanedSchema, resources, false))
+	awsVariables := readFormatted(t, filepath.Join(dir, "aws_variables.tf"))
+	googleVariables := readFormatted(t, filepath.Join(dir, "google_variables.tf"))
+	assert.Equal(t, 1, strings.Count(awsVariables+googleVariables, `variable "common_tags"`))
+	assert.Contains(t, readFormatted(t, filepath.Join(dir, "google.tf")), "merge(var.common_tags, each.value.labels)")
+
+	// Without --auto-tags, the tag attributes are left alone
+	dir = t.TempDir()
+	require.NoError(t, testTerraform.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, testTerraform.CreateVariablesTF(dir, cleanedSchema, resources, false))
+	assert.NotContains(t, readFormatted(t, filepath.Join(dir, "main.tf")), "common_tags")
+	assert.NotContains(t, readFormatted(t, filepath.Join(dir, "variables.tf")), "common_tags")
+}
+
+// TestSectionHeaders tests that each resource's section in main.tf and variables.tf starts with a header
+// comment naming the resource and its label, in resource order.
+func TestSectionHeaders(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_eip", Mode: "multiple", Label: "public", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+				"aws_eip": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"domain": {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{SectionHeaders: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	for _, file := range []string{"main.tf", "variables.tf"} {
+		content := readFormatted(t, filepath.Join(dir, file))
+		first := strings.Index(content, "# --- aws_instance ---\n")
+		second := strings.Index(content, "# --- aws_eip.public ---\n")
+		require.NotEqual(t, -1, first, file)
+		require.NotEqual(t, -1, second, file)
+		assert.Less(t, first, second, file)
+		assert.Contains(t, content[first:second], "ami", file)
+		assert.NotContains(t, content[first:second], "domain", file)
+		assert.Contains(t, content[second:], "domain", file)
+	}
+	assert.True(t, strings.HasPrefix(readFormatted(t, filepath.Join(dir, "main.tf")), "# --- aws_instance ---\n"))
+
+	// Without --section-headers, no dividers are written
+	dir = t.TempDir()
+	require.NoError(t, testTerraform.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, testTerraform.CreateVariablesTF(dir, cleanedSchema, resources, false))
+	assert.NotContains(t, readFormatted(t, filepath.Join(dir, "main.tf")), "# ---")
+	assert.NotContains(t, readFormatted(t, filepath.Join(dir, "variables.tf")), "# ---")
+}
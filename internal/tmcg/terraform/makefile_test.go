@@ -0,0 +1,38 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestCreateMakefile tests that the Makefile runs the configured binary and is never overwritten.
+func TestCreateMakefile(t *testing.T) {
+	dir := t.TempDir()
+	tf := NewTf(&MockLogger{})
+
+	require.NoError(t, tf.CreateMakefile(dir, "/opt/bin/tofu"))
+	content, err := os.ReadFile(filepath.Join(dir, "Makefile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "TERRAFORM ?= '/opt/bin/tofu'\n")
+	for _, target := range []string{"init:\n\t$(TERRAFORM) init\n", "plan: init\n\t$(TERRAFORM) plan\n", "validate: init\n\t$(TERRAFORM) validate\n", "fmt:\n\t$(TERRAFORM) fmt -recursive\n"} {
+		assert.Contains(t, string(content), target)
+	}
+
+	// Paths with spaces, quotes or dollar signs stay one shell word
+	dir = t.TempDir()
+	require.NoError(t, tf.CreateMakefile(dir, "/Applications/My Tools/it's $HOME/terraform"))
+	content, err = os.ReadFile(filepath.Join(dir, "Makefile"))
+	require.NoError(t, err)
+	assert.Contains(t, string(content), "TERRAFORM ?= '/Applications/My Tools/it'\\''s $$HOME/terraform'\n")
+
+	existing := filepath.Join(t.TempDir(), "Makefile")
+	require.NoError(t, os.WriteFile(existing, []byte("custom:\n"), 0644))
+	require.NoError(t, tf.CreateMakefile(filepath.Dir(existing), "terraform"))
+	content, err = os.ReadFile(existing)
+	require.NoError(t, err)
+	assert.Equal(t, "custom:\n", string(content))
+}
@@ -0,0 +1,91 @@
+package terraform
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testState is a small terraform show -json state with resources at their own addresses
+const testState = `{
+  "format_version": "1.0",
+  "terraform_version": "1.9.0",
+  "values": {
+    "root_module": {
+      "resources": [
+        {"address": "aws_instance.web[0]", "mode": "managed", "type": "aws_instance", "name": "web", "index": 0},
+        {"address": "aws_vpc.main[\"a\"]", "mode": "managed", "type": "aws_vpc", "name": "main", "index": "a"},
+        {"address": "aws_vpc.main[\"b\"]", "mode": "managed", "type": "aws_vpc", "name": "main", "index": "b"},
+        {"address": "aws_vpc.legacy", "mode": "managed", "type": "aws_vpc", "name": "legacy"},
+        {"address": "aws_s3_bucket.logs", "mode": "managed", "type": "aws_s3_bucket", "name": "logs"},
+        {"address": "data.aws_ami.ubuntu", "mode": "data", "type": "aws_ami", "name": "ubuntu"}
+      ]
+    }
+  }
+}`
+
+// TestCreateMovedTF tests moved blocks from state addresses to the generated ones, and removed blocks for
+// state resources of generated types that nothing moves to.
+func TestCreateMovedTF(t *testing.T) {
+	dir := t.TempDir()
+	statePath := filepath.Join(dir, "state.json")
+	require.NoError(t, os.WriteFile(statePath, []byte(testState), 0644))
+	stateResources, err := tmcgParsing.NewParser(&MockLogger{}).ParseStateFile(statePath)
+	require.NoError(t, err)
+	assert.Len(t, stateResources, 5)
+
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+
+	logger := &MockLogger{}
+	tf := NewTfWithOptions(logger, Options{StateResources: stateResources})
+	require.NoError(t, tf.CreateMovedTF(dir, resources))
+
+	assert.Equal(t, `moved {
+  from = aws_instance.web[0]
+  to   = aws_instance.this
+}
+
+moved {
+  from = aws_vpc.main
+  to   = aws_vpc.this
+}
+
+removed {
+  from = aws_vpc.legacy
+
+  lifecycle {
+    destroy = false
+  }
+}
+`, readFormatted(t, filepath.Join(dir, "moved.tf")))
+	assert.Contains(t, logger.Messages, "[warn] State resource aws_vpc.legacy has no generated counterpart; added a removed block that keeps its objects")
+
+	// Resources already at their generated addresses need no moved.tf
+	dir = t.TempDir()
+	tf = NewTfWithOptions(&MockLogger{}, Options{StateResources: []tmcgParsing.StateResource{{Type: "aws_instance", Name: "this"}}})
+	require.NoError(t, tf.CreateMovedTF(dir, resources))
+	assert.NoFileExists(t, filepath.Join(dir, "moved.tf"))
+}
+
+// TestInstanceKey tests the index suffixes of state instance addresses, including counts too large for %v.
+func TestInstanceKey(t *testing.T) {
+	for index, expected := range map[interface{}]string{
+		nil:           "",
+		"web":         `["web"]`,
+		float64(0):    "[0]",
+		float64(1e6):  "[1000000]",
+		float64(2e21): "[2000000000000000000000]",
+		7:             "[7]",
+	} {
+		assert.Equal(t, expected, instanceKey(index), "%v", index)
+	}
+}
@@ -0,0 +1,94 @@
+package terraform
+
+import (
+	"path/filepath"
+	"testing"
+
+	tmcgParsing "tmcg/internal/tmcg/parsing"
+
+	tfjson "github.com/hashicorp/terraform-json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
+)
+
+// TestCreateOutputsTF tests id outputs for single and multiple mode and skipping resources without an id.
+func TestCreateOutputsTF(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+		{Name: "aws_iam_policy_attachment", Mode: "multiple", Provider: aws},
+	}
+	schemas := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"id": {AttributeType: cty.String, Computed: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"id": {AttributeType: cty.String, Computed: true},
+				}}},
+				"aws_iam_policy_attachment": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	mockLogger := &MockLogger{}
+	dir := t.TempDir()
+	require.NoError(t, NewTf(mockLogger).CreateOutputsTF(dir, schemas, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "outputs.tf"))
+	assert.Contains(t, content, "output \"aws_instance_id\" {\n  description = \"The id of the aws_instance resource\"\n  value       = aws_instance.this.id\n}")
+	assert.Contains(t, content, "output \"aws_vpc_ids\" {\n  description = \"The ids of the aws_vpc resources, keyed by name\"\n  value       = { for k, v in aws_vpc.this : k => v.id }\n}")
+	assert.NotContains(t, content, "aws_iam_policy_attachment")
+	assert.Contains(t, mockLogger.Messages, "[warn] Resource aws_iam_policy_attachment has no id attribute. Skipping its id output.")
+}
+
+// TestCreateOutputsTFComputedOutputs tests that ComputedOutputs exposes the top-level computed-only
+// attributes of each resource next to its id, leaving settable and nested attributes out.
+func TestCreateOutputsTFComputedOutputs(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+		{Name: "aws_subnet", Mode: "multiple", Iteration: tmcgParsing.IterationCount, Provider: aws},
+	}
+	block := func() *tfjson.SchemaBlock {
+		return &tfjson.SchemaBlock{
+			Attributes: map[string]*tfjson.SchemaAttribute{
+				"id":   {AttributeType: cty.String, Computed: true},
+				"arn":  {AttributeType: cty.String, Computed: true},
+				"name": {AttributeType: cty.String, Optional: true, Computed: true},
+			},
+			NestedBlocks: map[string]*tfjson.SchemaBlockType{
+				"timeouts": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"create_duration": {AttributeType: cty.String, Computed: true},
+				}}},
+			},
+		}
+	}
+	schemas := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: block()},
+				"aws_vpc":      {Block: block()},
+				"aws_subnet":   {Block: block()},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{ComputedOutputs: true}).CreateOutputsTF(dir, schemas, resources))
+
+	content := readFormatted(t, filepath.Join(dir, "outputs.tf"))
+	assert.Contains(t, content, "output \"aws_instance_id\" {\n  description = \"The id of the aws_instance resource\"\n  value       = aws_instance.this.id\n}")
+	assert.Contains(t, content, "output \"aws_instance_arn\" {\n  description = \"The arn of the aws_instance resource\"\n  value       = aws_instance.this.arn\n}")
+	assert.Contains(t, content, "output \"aws_vpc_arn\" {\n  description = \"The arn values of the aws_vpc resources, keyed by name\"\n  value       = { for k, v in aws_vpc.this : k => v.arn }\n}")
+	assert.Contains(t, content, "output \"aws_subnet_arn\" {\n  description = \"The arn values of the aws_subnet resources, in list order\"\n  value       = aws_subnet.this[*].arn\n}")
+	assert.Contains(t, content, "output \"aws_subnet_ids\"")
+	assert.NotContains(t, content, "_name")
+	assert.NotContains(t, content, "create_duration")
+}
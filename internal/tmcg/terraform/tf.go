@@ -3,6 +3,7 @@
 package terraform
 
 import (
+	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
@@ -10,13 +11,18 @@ import (
 	"os/exec"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"unicode"
 
 	"tmcg/internal/tmcg/logging"
 	tmcgParsing "tmcg/internal/tmcg/parsing"
 
 	"github.com/gertd/go-pluralize"
+	"github.com/hashicorp/hcl/v2"
 	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/hcl/v2/hclwrite"
 	"github.com/hashicorp/terraform-exec/tfexec"
@@ -24,14 +30,193 @@ import (
 	"github.com/zclconf/go-cty/cty"
 )
 
+// Options controls optional generation behavior of Tf
+type Options struct {
+	// Defaults maps "resource.attribute" to the default value of optional single-mode variables
+	Defaults map[string]cty.Value
+
+	// TypeOverrides maps "resource.attribute" (or "resource.block.attribute") to a variable type expression
+	TypeOverrides map[string]string
+
+	// Descriptions maps "resource.attribute" (or "resource.block.attribute") to a description replacing the schema's
+	Descriptions map[string]string
+
+	// RequiredMultiple leaves out the default of multiple-mode variables, so callers must set them
+	RequiredMultiple bool
+
+	// RegistryHost is the registry provider sources resolve to, for schema lookups and versions.tf sources;
+	// empty means the public registry
+	RegistryHost string
+
+	// StateResources are the resources of an existing state that CreateMovedTF moves to the generated addresses
+	StateResources []tmcgParsing.StateResource
+
+	// Append keeps an existing main.tf and variables.tf, adding only the resource and variable blocks they lack
+	Append bool
+
+	// HeredocThreshold writes string defaults longer than this many characters, or spanning several lines,
+	// as heredocs; zero keeps them quoted
+	HeredocThreshold int
+
+	// Backend selects the backend or cloud block skeleton written to versions.tf, if any
+	Backend string
+
+	// BackendFile is the file the backend block is written to in a terraform block of its own; empty
+	// keeps it in versions.tf
+	BackendFile string
+
+	// BackendConfig maps attributes of the backend or cloud block to the HCL expressions replacing their
+	// placeholders, or added after them; an object expression for a nested block such as workspaces
+	// replaces the content of that block
+	BackendConfig map[string]string
+
+	// ParallelResources is the number of workers rendering resources concurrently; zero or one renders them in order
+	ParallelResources int
+
+	// Concurrency caps the workers of every pool, such as ParallelResources; zero leaves them uncapped
+	Concurrency int
+
+	// BlockDescComments writes nested block descriptions as comments even without the description comments flag
+	BlockDescComments bool
+
+	// MultilineDescriptions keeps newlines in single-mode descriptions by writing them as heredocs
+	MultilineDescriptions bool
+
+	// EmptyCollectionDefaults gives optional single-mode list, set and map variables, and optional repeated
+	// nested blocks, an empty default instead of null
+	EmptyCollectionDefaults bool
+
+	// ForEachMap lists multiple-mode resources whose variable is a map keyed by instance name instead of a list
+	ForEachMap map[string]bool
+
+	// ResourceProviders maps resource names to the aliased provider set in their provider meta-argument
+	ResourceProviders map[string]string
+
+	// PerKeyProviders maps multiple mode resources to a provider alias indexed by each.key
+	PerKeyProviders map[string]string
+
+	// DefaultsLocal moves the defaults of optional single-mode attributes into a locals block that main.tf
+	// falls back to, leaving the variables themselves with a null default
+	DefaultsLocal bool
+
+	// Preconditions maps resource addresses (type.label) to lifecycle preconditions added to their blocks
+	Preconditions map[string][]tmcgParsing.Precondition
+
+	// BlocksRequiredFirst orders the content of dynamic blocks with required attributes and blocks ahead of
+	// optional ones instead of purely alphabetically
+	BlocksRequiredFirst bool
+
+	// SingleRefStyle selects how single-mode resources reference their variables (bare, prefixed or object)
+	SingleRefStyle string
+
+	// NonNullable sets nullable = false on multiple-mode variables, which then default to empty, and on
+	// required single-mode variables. Attributes inside object types cannot carry nullable.
+	NonNullable bool
+
+	// KeyMode selects how list variables are keyed in for_each: by name (default), by index, or by name
+	// falling back to the index when the name is null
+	KeyMode string
+
+	// ComputedOutputs makes CreateOutputsTF also expose the top-level computed-only attributes of each
+	// resource, which cannot be set and so have no variables
+	ComputedOutputs bool
+
+	// KeyVar makes every multiple-mode variable a map keyed externally, keeping name as a regular field
+	KeyVar bool
+
+	// ShortIterators names dynamic block iterators it, it2, it3, ... by nesting level instead of after the block
+	ShortIterators bool
+
+	// NoCoalesce drops the coalesce and can guards from for_each expressions, which is only safe
+	// when the referenced variables and nested values are never null
+	NoCoalesce bool
+
+	// TrimProviderPrefix names bare single-mode block variables after the resource without its
+	// provider prefix, e.g. instance_root_block_device for aws_instance
+	TrimProviderPrefix bool
+
+	// ExtraHCL maps resource names to raw HCL snippets appended verbatim to their resource blocks
+	ExtraHCL map[string][]string
+
+	// NullDefaultTypes maps primitive types (string, number, bool) to the literal used as the default
+	// of optional single-mode attributes instead of null, and as the optional() default of nested ones
+	NullDefaultTypes map[string]string
+
+	// Toggleable lists single-mode resources created conditionally with count = var.<resource>_enabled ? 1 : 0
+	Toggleable map[string]bool
+
+	// ToggleOnNull creates the single-mode resources of the object reference style with
+	// count = var.<resource> == null ? 0 : 1, so a null object disables them
+	ToggleOnNull bool
+
+	// OptionalBlockDefault selects whether optional single-mode nested block variables default to null
+	// (OptionalBlockDefaultNull, the default when empty) or have no default (OptionalBlockDefaultNone)
+	OptionalBlockDefault string
+
+	// SimplePlural names multiple-mode variables with a plain suffix rule (s, es, or y to ies) instead of
+	// the pluralize client, for predictable names
+	SimplePlural bool
+
+	// AutoTags merges var.common_tags into the tag attribute of every resource that has one, found by
+	// the first of TagAttributes the resource schema declares as a map of strings
+	AutoTags bool
+
+	// TagAttributes are the candidate tag attribute names for AutoTags, in order; empty means tags, labels
+	TagAttributes []string
+
+	// ResourceAliases maps resource names to an alias their variables are named after instead: the multiple-mode
+	// variable, and the prefix of prefixed, object and enabled single-mode variables. Labels are unaffected.
+	ResourceAliases map[string]string
+
+	// GlobalVarSort orders the variables of a file by required first, then name, across all resources
+	// instead of grouping them by resource
+	GlobalVarSort bool
+
+	// AttributeCase selects whether the names of single-mode variables derived from attribute and block
+	// names are normalized to snake case (AttributeCaseSnake) or kept as in the schema (AttributeCasePreserve,
+	// the default when empty). The HCL attribute names always match the schema.
+	AttributeCase string
+
+	// SectionHeaders divides main.tf and variables.tf with a "# --- <resource> ---" comment before the
+	// blocks of each resource
+	SectionHeaders bool
+
+	// FileMode is the permission mode of the generated files; zero means 0644
+	FileMode os.FileMode
+}
+
 // Tf encapsulates tf logic with logging
 type Tf struct {
 	logger logging.Logger
+	opts   Options
+
+	// sharedSingleTypes holds the resource types declared more than once in single mode
+	sharedSingleTypes map[string]bool
+
+	// snakeCollisions holds the "type.item" paths whose snake-cased variable names collide with those of
+	// other items of their resource, which keep the schema's spelling instead
+	snakeCollisions map[string]bool
+
+	// ambiguousTypes holds the resource types that more than one provider schema exposes
+	ambiguousTypes map[string]bool
+
+	// skipped records the resources and blocks left out of the generated files, guarded by mu
+	// as resources may be rendered concurrently
+	skipped []string
+	mu      sync.Mutex
+
+	// appendBases holds the content files had before this run first wrote them in append mode
+	appendBases map[string][]byte
 }
 
 // NewParser creates a new Tf instance
 func NewTf(logger logging.Logger) *Tf {
-	return &Tf{logger: logger}
+	return NewTfWithOptions(logger, Options{})
+}
+
+// NewTfWithOptions creates a new Tf instance with the given generation options
+func NewTfWithOptions(logger logging.Logger, opts Options) *Tf {
+	return &Tf{logger: logger, opts: opts}
 }
 
 // ValidateTerraformBinary ensures the Terraform binary is available
@@ -45,10 +230,38 @@ func (t *Tf) ValidateTerraformBinary(binary string) (string, error) {
 	return path, nil
 }
 
+// backendSkeletons holds the placeholder backend and cloud blocks for remote state, keyed by backend name
+var backendSkeletons = map[string]string{
+	tmcgParsing.BackendLocal: `  backend "local" {
+    path = "terraform.tfstate"
+  }
+`,
+	tmcgParsing.BackendS3: `  backend "s3" {
+    bucket = "REPLACE_WITH_STATE_BUCKET"
+    key    = "REPLACE_WITH_STATE_KEY/terraform.tfstate"
+    region = "REPLACE_WITH_REGION"
+  }
+`,
+	tmcgParsing.BackendCloud: `  cloud {
+    organization = "REPLACE_WITH_ORGANIZATION"
+
+    workspaces {
+      name = "REPLACE_WITH_WORKSPACE"
+    }
+  }
+`,
+}
+
 // CreateVersionsTF generates a versions.tf file with the required provider definitions
 func (t *Tf) CreateVersionsTF(workingDir string, providers map[string]tmcgParsing.Provider) error {
 	t.logger.Log("info", "Creating versions.tf...")
 
+	// Validate inputs
+	if len(providers) == 0 {
+		t.logger.Log("warn", "No providers specified. Skipping versions.tf generation.")
+		return nil
+	}
+
 	// Collect keys for sorting
 	keys := make([]string, 0, len(providers))
 	for key := range providers {
@@ -61,336 +274,2135 @@ func (t *Tf) CreateVersionsTF(workingDir string, providers map[string]tmcgParsin
 	builder.WriteString("terraform {\n  required_providers {\n")
 	for _, key := range keys {
 		provider := providers[key]
-		builder.WriteString(fmt.Sprintf("    %s = {\n", provider.NameLower))
-		builder.WriteString(fmt.Sprintf("      source  = \"%s/%s\"\n", provider.NamespaceLower, provider.NameLower))
-		builder.WriteString(fmt.Sprintf("      version = \"%s\"\n", provider.Version))
+		builder.WriteString(fmt.Sprintf("    %s = {\n", provider.Local()))
+		source := provider.SourceKey()
+		if host := t.registryHost(); host != tmcgParsing.DefaultRegistryHost {
+			source = provider.Address(host)
+		}
+		builder.WriteString(fmt.Sprintf("      source  = \"%s\"\n", source))
+		if provider.Version != "" {
+			builder.WriteString(fmt.Sprintf("      version = \"%s\"\n", provider.Version))
+		}
+		if len(provider.ConfigurationAliases) > 0 {
+			references := make([]string, 0, len(provider.ConfigurationAliases))
+			for _, alias := range provider.ConfigurationAliases {
+				references = append(references, fmt.Sprintf("%s.%s", provider.Local(), alias))
+			}
+			builder.WriteString(fmt.Sprintf("      configuration_aliases = [%s]\n", strings.Join(references, ", ")))
+		}
 		builder.WriteString("    }\n")
 	}
-	builder.WriteString("  }\n}\n")
-
-	// Write to file
-	filePath := filepath.Join(workingDir, "versions.tf")
-	return os.WriteFile(filePath, []byte(builder.String()), 0644)
+	builder.WriteString("  }\n")
+	skeleton, hasBackend := backendSkeletons[t.opts.Backend]
+	if hasBackend && t.opts.BackendFile == "" {
+		builder.WriteString("\n" + skeleton)
+		t.logger.Log("debug", "Added %s backend skeleton to versions.tf", t.opts.Backend)
+	}
+	builder.WriteString("}\n")
+
+	content := []byte(builder.String())
+	if hasBackend && t.opts.BackendFile == "" {
+		var err error
+		if content, err = t.configureBackend(content); err != nil {
+			return err
+		}
+	}
+
+	// Write to file
+	filePath := filepath.Join(workingDir, "versions.tf")
+	if err := t.writeGeneratedFile(filePath, content); err != nil {
+		return err
+	}
+	if !hasBackend || t.opts.BackendFile == "" {
+		return nil
+	}
+
+	// Give the backend a terraform block of its own in the backend file
+	content, err := t.configureBackend([]byte("terraform {\n" + skeleton + "}\n"))
+	if err != nil {
+		return err
+	}
+	t.logger.Log("debug", "Added %s backend skeleton to %s", t.opts.Backend, t.opts.BackendFile)
+	return t.writeGeneratedFile(filepath.Join(workingDir, t.opts.BackendFile), content)
+}
+
+// configureBackend sets the BackendConfig attributes on the backend or cloud block inside the terraform
+// block of content, in name order, and the content of its nested blocks
+func (t *Tf) configureBackend(content []byte) ([]byte, error) {
+	if len(t.opts.BackendConfig) == 0 {
+		return content, nil
+	}
+	file, diags := hclwrite.ParseConfig(content, "versions.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse backend block: %s", diags.Error())
+	}
+
+	var backendBody *hclwrite.Body
+	for _, block := range file.Body().Blocks() {
+		if block.Type() != "terraform" {
+			continue
+		}
+		for _, nested := range block.Body().Blocks() {
+			if nested.Type() == "backend" || nested.Type() == "cloud" {
+				backendBody = nested.Body()
+			}
+		}
+	}
+	if backendBody == nil {
+		return nil, fmt.Errorf("no backend block to configure")
+	}
+
+	names := make([]string, 0, len(t.opts.BackendConfig))
+	for name := range t.opts.BackendConfig {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		expression := t.opts.BackendConfig[name]
+		if block := backendBody.FirstMatchingBlock(name, nil); block != nil {
+			if err := setBackendBlock(block.Body(), name, expression); err != nil {
+				return nil, err
+			}
+			t.logger.Log("debug", "Set backend block: %s", name)
+			continue
+		}
+		backendBody.SetAttributeRaw(name, hclwrite.TokensForIdentifier(expression))
+		t.logger.Log("debug", "Set backend attribute: %s", name)
+	}
+	return hclwrite.Format(file.Bytes()), nil
+}
+
+// setBackendBlock replaces the content of a nested backend block, such as the workspaces block of cloud,
+// with the attributes of an object expression
+func setBackendBlock(body *hclwrite.Body, name, expression string) error {
+	expr, diags := hclsyntax.ParseExpression([]byte(expression), "backend-config", hcl.InitialPos)
+	object, ok := expr.(*hclsyntax.ObjectConsExpr)
+	if diags.HasErrors() || !ok {
+		return fmt.Errorf("backend config %s sets a block and needs an object such as { name = \"prod\" }", name)
+	}
+
+	for attribute := range body.Attributes() {
+		body.RemoveAttribute(attribute)
+	}
+	for _, block := range body.Blocks() {
+		body.RemoveBlock(block)
+	}
+	for _, item := range object.Items {
+		attribute := hcl.ExprAsKeyword(item.KeyExpr)
+		if key, diags := item.KeyExpr.Value(nil); attribute == "" && !diags.HasErrors() && key.Type() == cty.String && key.IsKnown() && !key.IsNull() {
+			attribute = key.AsString()
+		}
+		if !hclsyntax.ValidIdentifier(attribute) {
+			return fmt.Errorf("backend config %s has an invalid attribute name", name)
+		}
+		body.SetAttributeRaw(attribute, hclwrite.TokensForIdentifier(string(item.ValueExpr.Range().SliceBytes([]byte(expression)))))
+	}
+	return nil
+}
+
+// CreateProviderTF generates a providers.tf file with one aliased provider block per configuration alias
+func (t *Tf) CreateProviderTF(workingDir string, providers map[string]tmcgParsing.Provider) error {
+	t.logger.Log("info", "Creating providers.tf...")
+
+	// Collect keys for sorting
+	keys := make([]string, 0, len(providers))
+	for key := range providers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	file := hclwrite.NewEmptyFile()
+	blocks := 0
+	for _, key := range keys {
+		provider := providers[key]
+		seen := make(map[string]bool, len(provider.ConfigurationAliases))
+		for _, alias := range provider.ConfigurationAliases {
+			// Aliases must match the configuration_aliases declared in versions.tf exactly once
+			if !hclsyntax.ValidIdentifier(alias) || seen[alias] {
+				return fmt.Errorf("invalid or duplicate configuration alias for provider %s: %s", key, alias)
+			}
+			seen[alias] = true
+
+			if blocks > 0 {
+				file.Body().AppendNewline()
+			}
+			providerBlock := file.Body().AppendNewBlock("provider", []string{provider.Local()})
+			providerBlock.Body().SetAttributeValue("alias", cty.StringVal(alias))
+			blocks++
+			t.logger.Log("debug", "Added provider block: %s.%s", provider.Local(), alias)
+		}
+	}
+
+	if blocks == 0 {
+		t.logger.Log("info", "No provider aliases declared. Skipping providers.tf generation.")
+		return nil
+	}
+
+	filePath := filepath.Join(workingDir, "providers.tf")
+	if err := t.writeGeneratedFile(filePath, file.Bytes()); err != nil {
+		return fmt.Errorf("failed to write providers.tf to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// gitignoreContent is the standard .gitignore written for generated Terraform modules
+const gitignoreContent = `# Local .terraform directories
+.terraform/
+
+# State files
+*.tfstate
+*.tfstate.*
+
+# Crash log files
+crash.log
+crash.*.log
+
+# Override files
+override.tf
+override.tf.json
+*_override.tf
+*_override.tf.json
+
+# CLI configuration files
+.terraformrc
+terraform.rc
+
+# Uncomment to ignore the dependency lock file
+# .terraform.lock.hcl
+`
+
+// CreateGitignore writes a standard Terraform .gitignore unless the directory already has one
+func (t *Tf) CreateGitignore(workingDir string) error {
+	filePath := filepath.Join(workingDir, ".gitignore")
+	if _, err := os.Stat(filePath); err == nil {
+		t.logger.Log("info", ".gitignore already exists, leaving it unchanged: %s", filePath)
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check for .gitignore at %s: %w", filePath, err)
+	}
+
+	t.logger.Log("info", "Writing .gitignore to: %s", filePath)
+	if err := t.writeGeneratedFile(filePath, []byte(gitignoreContent)); err != nil {
+		return fmt.Errorf("failed to write .gitignore to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// makefileTemplate is the Makefile written for generated modules, parameterized by the Terraform binary
+const makefileTemplate = `TERRAFORM ?= %s
+
+.PHONY: init plan validate fmt
+
+init:
+	$(TERRAFORM) init
+
+plan: init
+	$(TERRAFORM) plan
+
+validate: init
+	$(TERRAFORM) validate
+
+fmt:
+	$(TERRAFORM) fmt -recursive
+`
+
+// CreateMakefile writes a Makefile with init, plan, validate and fmt targets that run the given Terraform
+// binary, unless the directory already has a Makefile
+func (t *Tf) CreateMakefile(workingDir string, binaryPath string) error {
+	filePath := filepath.Join(workingDir, "Makefile")
+	if _, err := os.Stat(filePath); err == nil {
+		t.logger.Log("info", "Makefile already exists, leaving it unchanged: %s", filePath)
+		return nil
+	} else if !os.IsNotExist(err) {
+		return fmt.Errorf("failed to check for Makefile at %s: %w", filePath, err)
+	}
+
+	t.logger.Log("info", "Writing Makefile to: %s", filePath)
+	if err := t.writeGeneratedFile(filePath, []byte(fmt.Sprintf(makefileTemplate, makeShellQuote(binaryPath)))); err != nil {
+		return fmt.Errorf("failed to write Makefile to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// makeShellQuote quotes a value for the shell commands of a Makefile recipe, so paths with spaces or
+// quotes stay one word and dollar signs are not expanded by make
+func makeShellQuote(value string) string {
+	quoted := "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+	return strings.ReplaceAll(quoted, "$", "$$")
+}
+
+// CreateCLIConfig writes a Terraform CLI configuration file that installs the given providers from local
+// plugin directories via dev_overrides and every other provider from its usual source. devOverrides is
+// keyed by provider source, which is qualified with the registry host like the sources in versions.tf.
+func (t *Tf) CreateCLIConfig(path string, devOverrides map[string]string) error {
+	keys := make([]string, 0, len(devOverrides))
+	for key := range devOverrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var builder strings.Builder
+	builder.WriteString("provider_installation {\n  dev_overrides {\n")
+	for _, key := range keys {
+		source := key
+		if host := t.registryHost(); host != tmcgParsing.DefaultRegistryHost {
+			source = host + "/" + key
+		}
+		builder.WriteString(fmt.Sprintf("    %q = %s\n", source, hclwrite.TokensForValue(cty.StringVal(devOverrides[key])).Bytes()))
+	}
+	builder.WriteString("  }\n\n  direct {}\n}\n")
+
+	t.logger.Log("info", "Writing Terraform CLI configuration to: %s", path)
+	if err := writeFile(path, []byte(builder.String()), 0644); err != nil {
+		return fmt.Errorf("failed to write Terraform CLI configuration to %s: %w", path, err)
+	}
+	return nil
+}
+
+var writeFile = os.WriteFile
+
+// fileMode returns the permission mode of the generated files
+func (t *Tf) fileMode() os.FileMode {
+	if t.opts.FileMode == 0 {
+		return 0644
+	}
+	return t.opts.FileMode
+}
+
+// writeGeneratedFile writes a generated file with the configured mode, which is applied
+// explicitly so neither the umask nor the mode of a file being regenerated interferes
+func (t *Tf) writeGeneratedFile(path string, data []byte) error {
+	if err := writeFile(path, data, t.fileMode()); err != nil {
+		return err
+	}
+	if t.opts.FileMode == 0 {
+		return nil
+	}
+	return os.Chmod(path, t.opts.FileMode)
+}
+
+// CreateMainTF generates the main.tf file with resource and dynamic blocks
+func (t *Tf) CreateMainTF(dir string, cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) error {
+	t.logger.Log("info", "Starting to generate main.tf in directory: %s", dir)
+
+	// Validate inputs
+	if len(resources) == 0 {
+		t.logger.Log("warn", "No resources specified. Skipping main.tf generation.")
+		return nil
+	}
+
+	// Leave resources that are already present alone when appending
+	filePath := filepath.Join(dir, "main.tf")
+	var existing []byte
+	if t.opts.Append {
+		var err error
+		existing, err = t.existingContent(filePath)
+		if err != nil {
+			return err
+		}
+		present, err := existingBlocks(existing, filePath, "resource")
+		if err != nil {
+			return err
+		}
+		resources = slices.DeleteFunc(slices.Clone(resources), func(resource tmcgParsing.Resource) bool {
+			address := resource.Name + "." + resource.BlockLabel()
+			if present[address] {
+				t.logger.Log("info", "Keeping resource %s already present in main.tf", address)
+			}
+			return present[address]
+		})
+		if len(resources) == 0 {
+			t.logger.Log("info", "main.tf already contains every resource. Skipping main.tf generation.")
+			return nil
+		}
+	}
+
+	content, err := t.RenderMainTF(cleanedSchema, resources)
+	if err != nil {
+		return err
+	}
+	content = appendContent(existing, content)
+
+	// Write the generated file to disk
+	t.logger.Log("info", "Writing main.tf to: %s", filePath)
+	err = t.writeGeneratedFile(filePath, content)
+	if err != nil {
+		t.logger.Log("error", "Failed to write main.tf: %v", err)
+		return fmt.Errorf("failed to write main.tf to %s: %w", filePath, err)
+	}
+
+	t.logger.Log("info", "Successfully generated main.tf in directory: %s", dir)
+	return nil
+}
+
+// existingContent returns the content a file had before this run first wrote it, so regenerating after
+// validation appends to the same base. A missing file has no content.
+func (t *Tf) existingContent(path string) ([]byte, error) {
+	if content, exists := t.appendBases[path]; exists {
+		return content, nil
+	}
+	content, err := os.ReadFile(path)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read existing %s: %w", path, err)
+	}
+	if t.appendBases == nil {
+		t.appendBases = make(map[string][]byte)
+	}
+	t.appendBases[path] = content
+	return content, nil
+}
+
+// existingBlocks returns the dot-joined labels of the blocks of the given type in HCL content,
+// such as "aws_instance.this" for resources
+func existingBlocks(content []byte, path, blockType string) (map[string]bool, error) {
+	file, diags := hclsyntax.ParseConfig(content, path, hcl.InitialPos)
+	if diags.HasErrors() {
+		return nil, fmt.Errorf("failed to parse existing %s: %s", path, diags.Error())
+	}
+
+	present := make(map[string]bool)
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type == blockType {
+			present[strings.Join(block.Labels, ".")] = true
+		}
+	}
+	return present, nil
+}
+
+// appendContent appends generated content to the existing content of a file, separated by a blank line
+func appendContent(existing, generated []byte) []byte {
+	if len(bytes.TrimSpace(existing)) == 0 {
+		return generated
+	}
+	content := append([]byte{}, bytes.TrimRight(existing, "\n")...)
+	return append(append(content, '\n', '\n'), generated...)
+}
+
+// RenderMainTF renders the resource and dynamic blocks for the given resources without writing them to disk
+func (t *Tf) RenderMainTF(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) ([]byte, error) {
+	t.sharedSingleTypes = sharedSingleTypes(resources)
+	t.ambiguousTypes = ambiguousTypes(cleanedSchema)
+	t.snakeCollisions = t.snakeCaseCollisions(cleanedSchema, resources)
+	renderedDefaults := make([][]hclwrite.ObjectAttrTokens, len(resources))
+	file := t.renderResources(resources, func(index int, resource tmcgParsing.Resource) []byte {
+		content, defaults := t.renderMainResource(cleanedSchema, resource)
+		renderedDefaults[index] = defaults
+		return content
+	})
+	localDefaults := slices.Concat(renderedDefaults...)
+	if len(localDefaults) == 0 {
+		return file.Bytes(), nil
+	}
+
+	// Put the centralized defaults ahead of the resources that fall back to them
+	localsFile := hclwrite.NewEmptyFile()
+	localsFile.Body().AppendNewBlock("locals", nil).Body().SetAttributeRaw("defaults", hclwrite.TokensForObject(localDefaults))
+	localsFile.Body().AppendNewline()
+	t.logger.Log("debug", "Added locals block with %d default(s)", len(localDefaults))
+	return append(localsFile.Bytes(), file.Bytes()...), nil
+}
+
+// CreateProviderGroupedTF generates one resource file and one variables file per provider (e.g., aws.tf and aws_variables.tf)
+func (t *Tf) CreateProviderGroupedTF(dir string, cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, descAsCommentsFlag bool) error {
+	t.logger.Log("info", "Starting to generate per-provider files in directory: %s", dir)
+
+	// Validate inputs
+	if len(resources) == 0 {
+		t.logger.Log("warn", "No resources specified. Skipping per-provider file generation.")
+		return nil
+	}
+
+	// Group resources by provider, preserving their order within each group
+	groups := make(map[string][]tmcgParsing.Resource)
+	names := []string{}
+	for _, resource := range resources {
+		name := resource.Provider.Local()
+		if _, exists := groups[name]; !exists {
+			names = append(names, name)
+		}
+		groups[name] = append(groups[name], resource)
+	}
+	sort.Strings(names)
+
+	commonTagsDeclared := false
+	for _, name := range names {
+		mainContent, err := t.RenderMainTF(cleanedSchema, groups[name])
+		if err != nil {
+			return err
+		}
+		variablesContent, tagged := t.renderVariablesTF(cleanedSchema, groups[name], descAsCommentsFlag, !commonTagsDeclared)
+		commonTagsDeclared = commonTagsDeclared || tagged
+
+		for fileName, content := range map[string][]byte{name + ".tf": mainContent, name + "_variables.tf": variablesContent} {
+			filePath := filepath.Join(dir, fileName)
+			t.logger.Log("info", "Writing %s to: %s", fileName, filePath)
+			if err := t.writeGeneratedFile(filePath, content); err != nil {
+				t.logger.Log("error", "Failed to write %s: %v", fileName, err)
+				return fmt.Errorf("failed to write %s to %s: %w", fileName, filePath, err)
+			}
+		}
+	}
+
+	t.logger.Log("info", "Successfully generated per-provider files in directory: %s", dir)
+	return nil
+}
+
+// CreateOutputsTF generates an outputs.tf file exposing the id of each resource, and with ComputedOutputs
+// its other top-level computed-only attributes. The schema must still contain computed attributes, as id is
+// usually computed-only.
+func (t *Tf) CreateOutputsTF(dir string, schemas map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) error {
+	t.logger.Log("info", "Starting to generate outputs.tf in directory: %s", dir)
+
+	file := hclwrite.NewEmptyFile()
+	outputs := 0
+	for _, resource := range resources {
+		resourceSchema, exists := t.lookupResourceSchema(schemas, resource)
+		if !exists {
+			continue
+		}
+		var attributes []string
+		if resourceSchema.Block != nil && resourceSchema.Block.Attributes["id"] != nil {
+			attributes = append(attributes, "id")
+		} else {
+			t.logger.Log("warn", "Resource %s has no id attribute. Skipping its id output.", resource.Name)
+		}
+		if t.opts.ComputedOutputs && resourceSchema.Block != nil {
+			attributes = append(attributes, computedOnlyAttributes(resourceSchema.Block)...)
+		}
+
+		// Include custom labels so outputs of the same resource type do not collide
+		outputName := resource.Name
+		if resource.Label != "" {
+			outputName += "_" + resource.Label
+		}
+		for _, attribute := range attributes {
+			if outputs > 0 {
+				file.Body().AppendNewline()
+			}
+			t.appendAttributeOutput(file.Body(), resource, outputName, attribute)
+			outputs++
+		}
+	}
+
+	if outputs == 0 {
+		t.logger.Log("warn", "No resource exposes an attribute to output. Skipping outputs.tf generation.")
+		return nil
+	}
+
+	t.cleanupHCLFile(file)
+	filePath := filepath.Join(dir, "outputs.tf")
+	t.logger.Log("info", "Writing outputs.tf to: %s", filePath)
+	if err := t.writeGeneratedFile(filePath, file.Bytes()); err != nil {
+		return fmt.Errorf("failed to write outputs.tf to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// computedOnlyAttributes returns the sorted names of the top-level attributes of a block that only the
+// provider sets, other than id
+func computedOnlyAttributes(block *tfjson.SchemaBlock) []string {
+	var names []string
+	for name, attrSchema := range block.Attributes {
+		if name != "id" && attrSchema != nil && attrSchema.Computed && !attrSchema.Optional && !attrSchema.Required {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// appendAttributeOutput appends an output exposing an attribute of a resource: a list in count order for
+// count iteration, a map keyed like the instances for multiple mode, and the value itself for single mode.
+// Outputs of id are named <outputName>_id or <outputName>_ids, and the others <outputName>_<attribute>.
+func (t *Tf) appendAttributeOutput(body *hclwrite.Body, resource tmcgParsing.Resource, outputName, attribute string) {
+	address := resource.Name + "." + resource.BlockLabel()
+	name, plural := outputName+"_"+attribute, attribute+" values"
+	if attribute == "id" {
+		name, plural = outputName+"_ids", "ids"
+	}
+
+	if t.countIteration(resource) {
+		outputBody := body.AppendNewBlock("output", []string{name}).Body()
+		outputBody.SetAttributeValue("description", cty.StringVal(fmt.Sprintf("The %s of the %s resources, in list order", plural, resource.Name)))
+		outputBody.SetAttributeRaw("value", hclwrite.TokensForIdentifier(fmt.Sprintf("%s[*].%s", address, attribute)))
+	} else if resource.Mode == "multiple" {
+		outputBody := body.AppendNewBlock("output", []string{name}).Body()
+		outputBody.SetAttributeValue("description", cty.StringVal(fmt.Sprintf("The %s of the %s resources, keyed by name", plural, resource.Name)))
+		outputBody.SetAttributeRaw("value", hclwrite.TokensForIdentifier(fmt.Sprintf("{ for k, v in %s : k => v.%s }", address, attribute)))
+	} else {
+		outputBody := body.AppendNewBlock("output", []string{outputName + "_" + attribute}).Body()
+		outputBody.SetAttributeValue("description", cty.StringVal(fmt.Sprintf("The %s of the %s resource", attribute, resource.Name)))
+		value := address + "." + attribute
+		if t.toggleable(resource) || t.toggledOnNull(resource) {
+			// A disabled resource has no instances, so fall back to null
+			value = fmt.Sprintf("try(%s[0].%s, null)", address, attribute)
+		}
+		outputBody.SetAttributeRaw("value", hclwrite.TokensForIdentifier(value))
+	}
+}
+
+// registryHost returns the registry host provider sources resolve to
+func (t *Tf) registryHost() string {
+	if t.opts.RegistryHost == "" {
+		return tmcgParsing.DefaultRegistryHost
+	}
+	return t.opts.RegistryHost
+}
+
+// CreateMovedTF generates a moved.tf that carries the resources of an existing state (StateResources) over to
+// the generated addresses. Each generated resource takes the first state resource of its type that no other
+// generated resource has, and state resources of generated types left without one get a removed block that
+// keeps their objects, so nothing is destroyed.
+func (t *Tf) CreateMovedTF(dir string, resources []tmcgParsing.Resource) error {
+	t.logger.Log("info", "Starting to generate moved.tf in directory: %s", dir)
+
+	// Group the state instances by resource address, in state order
+	type stateGroup struct {
+		resourceType string
+		address      string
+		indexes      []interface{}
+	}
+	groups := []*stateGroup{}
+	byAddress := make(map[string]*stateGroup)
+	for _, stateResource := range t.opts.StateResources {
+		group, exists := byAddress[stateResource.Address()]
+		if !exists {
+			group = &stateGroup{resourceType: stateResource.Type, address: stateResource.Address()}
+			byAddress[group.address] = group
+			groups = append(groups, group)
+		}
+		group.indexes = append(group.indexes, stateResource.Index)
+	}
+
+	generated := make(map[string]bool, len(resources))
+	generatedTypes := make(map[string]bool, len(resources))
+	for _, resource := range resources {
+		generated[resource.Name+"."+resource.BlockLabel()] = true
+		generatedTypes[resource.Name] = true
+	}
+
+	file := hclwrite.NewEmptyFile()
+	blocks := 0
+	appendBlock := func(blockType string) *hclwrite.Body {
+		if blocks > 0 {
+			file.Body().AppendNewline()
+		}
+		blocks++
+		return file.Body().AppendNewBlock(blockType, nil).Body()
+	}
+
+	claimed := make(map[*stateGroup]bool)
+	for _, resource := range resources {
+		target := resource.Name + "." + resource.BlockLabel()
+		if byAddress[target] != nil {
+			// The state already uses the generated address
+			continue
+		}
+
+		var source *stateGroup
+		for _, group := range groups {
+			if group.resourceType == resource.Name && !claimed[group] && !generated[group.address] {
+				source = group
+				break
+			}
+		}
+		if source == nil {
+			continue
+		}
+		claimed[source] = true
+
+		from, to := source.address, target
+		if t.countIteration(resource) {
+			// The whole resource moves and keeps its instance keys, which count expects to be indexes
+			for _, index := range source.indexes {
+				if _, ok := index.(string); ok || index == nil {
+					t.logger.Log("warn", "Instances of %s are not keyed by index; check that their keys match the count indexes of %s", source.address, target)
+					break
+				}
+			}
+		} else if resource.Mode == "multiple" {
+			// The whole resource moves and keeps its instance keys, which for_each expects to be names
+			for _, index := range source.indexes {
+				if _, ok := index.(string); !ok {
+					t.logger.Log("warn", "Instances of %s are not keyed by name; check that their keys match the for_each keys of %s", source.address, target)
+					break
+				}
+			}
+		} else {
+			if len(source.indexes) > 1 {
+				t.logger.Log("warn", "State resource %s has %d instances and cannot move to the single resource %s", source.address, len(source.indexes), target)
+				continue
+			}
+			from += instanceKey(source.indexes[0])
+			if t.toggleable(resource) || t.toggledOnNull(resource) {
+				to += "[0]"
+			}
+		}
+
+		movedBody := appendBlock("moved")
+		movedBody.SetAttributeRaw("from", hclwrite.TokensForIdentifier(from))
+		movedBody.SetAttributeRaw("to", hclwrite.TokensForIdentifier(to))
+		t.logger.Log("debug", "Added moved block from %s to %s", from, to)
+	}
+
+	for _, group := range groups {
+		if claimed[group] || generated[group.address] || !generatedTypes[group.resourceType] {
+			continue
+		}
+		removedBody := appendBlock("removed")
+		removedBody.SetAttributeRaw("from", hclwrite.TokensForIdentifier(group.address))
+		removedBody.AppendNewline()
+		removedBody.AppendNewBlock("lifecycle", nil).Body().SetAttributeValue("destroy", cty.False)
+		t.logger.Log("warn", "State resource %s has no generated counterpart; added a removed block that keeps its objects", group.address)
+	}
+
+	if blocks == 0 {
+		t.logger.Log("warn", "No state resource needs to move to a generated address. Skipping moved.tf generation.")
+		return nil
+	}
+
+	filePath := filepath.Join(dir, "moved.tf")
+	if err := t.writeGeneratedFile(filePath, file.Bytes()); err != nil {
+		return fmt.Errorf("failed to write moved.tf to %s: %w", filePath, err)
+	}
+	return nil
+}
+
+// instanceKey returns the index suffix of a state instance address, such as [0] or ["web"]
+func instanceKey(index interface{}) string {
+	switch key := index.(type) {
+	case nil:
+		return ""
+	case string:
+		return fmt.Sprintf("[%q]", key)
+	case float64:
+		// JSON numbers decode as float64, which %v would print in exponent form for large counts
+		return "[" + strconv.FormatFloat(key, 'f', -1, 64) + "]"
+	default:
+		return fmt.Sprintf("[%v]", key)
+	}
+}
+
+// lookupResourceSchema finds the schema of a resource within the cleaned provider schemas
+func (t *Tf) lookupResourceSchema(cleanedSchema map[string]*tfjson.ProviderSchema, resource tmcgParsing.Resource) (*tfjson.Schema, bool) {
+	// Construct the provider key to access the schema
+	providerKey := resource.Provider.Address(t.registryHost())
+	providerSchema, exists := cleanedSchema[providerKey]
+	if !exists {
+		// Fall back to a case-insensitive match for registry keys that preserve their original casing
+		for key, candidate := range cleanedSchema {
+			if strings.EqualFold(key, providerKey) {
+				t.logger.Log("debug", "Using schema key %s for provider: %s", key, providerKey)
+				providerSchema, exists = candidate, true
+				break
+			}
+		}
+	}
+	if !exists || providerSchema == nil {
+		t.skip("No schema found for provider: %s", providerKey)
+		return nil, false
+	}
+
+	resourceSchema, exists := providerSchema.ResourceSchemas[resource.Name]
+	if !exists {
+		t.skip("No schema found for resource: %s with provider: %s/%s", resource.Name, resource.Provider.Namespace, resource.Provider.Name)
+		return nil, false
+	}
+
+	return filterResourceSchema(resourceSchema, resource), true
+}
+
+// filterResourceSchema returns the schema of a resource with only the top-level attributes and blocks its
+// include list names, or without those its exclude list names. The schema itself is left unchanged.
+func filterResourceSchema(resourceSchema *tfjson.Schema, resource tmcgParsing.Resource) *tfjson.Schema {
+	if (len(resource.Include) == 0 && len(resource.Exclude) == 0) || resourceSchema == nil || resourceSchema.Block == nil {
+		return resourceSchema
+	}
+	keep := func(name string) bool {
+		if len(resource.Include) > 0 {
+			return slices.Contains(resource.Include, name)
+		}
+		return !slices.Contains(resource.Exclude, name)
+	}
+
+	block := *resourceSchema.Block
+	block.Attributes = make(map[string]*tfjson.SchemaAttribute, len(resourceSchema.Block.Attributes))
+	for name, attrSchema := range resourceSchema.Block.Attributes {
+		if keep(name) {
+			block.Attributes[name] = attrSchema
+		}
+	}
+	block.NestedBlocks = make(map[string]*tfjson.SchemaBlockType, len(resourceSchema.Block.NestedBlocks))
+	for name, blockSchema := range resourceSchema.Block.NestedBlocks {
+		if keep(name) {
+			block.NestedBlocks[name] = blockSchema
+		}
+	}
+
+	filtered := *resourceSchema
+	filtered.Block = &block
+	return &filtered
+}
+
+// CountResourceBlocks returns the number of resource blocks RenderMainTF emits for the given resources
+func (t *Tf) CountResourceBlocks(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) int {
+	count := 0
+	for _, resource := range resources {
+		if _, exists := t.lookupResourceSchema(cleanedSchema, resource); exists {
+			count++
+		}
+	}
+	return count
+}
+
+// handleAttributesAndNestedBlocks is a recursive function to handle attributes and nested blocks
+// depth is the nesting level of any dynamic blocks created here
+func (t *Tf) handleAttributesAndNestedBlocks(resourceAttrs *hclwrite.Body, attributes map[string]*tfjson.SchemaAttribute, nestedBlocks map[string]*tfjson.SchemaBlockType, prefix string, depth int) {
+	// Collect attributes and nested blocks into a combined map
+	items := make(map[string]interface{}, len(attributes)+len(nestedBlocks))
+	for name, attrSchema := range attributes {
+		items[name] = attrSchema
+	}
+	for name, blockSchema := range nestedBlocks {
+		items[name] = blockSchema
+	}
+
+	// Get a sorted list of item names
+	itemNames := make([]string, 0, len(items))
+	for name := range items {
+		itemNames = append(itemNames, name)
+	}
+	sort.Strings(itemNames)
+	if t.opts.BlocksRequiredFirst {
+		sort.SliceStable(itemNames, func(i, j int) bool {
+			return isRequiredItem(items[itemNames[i]]) && !isRequiredItem(items[itemNames[j]])
+		})
+	}
+
+	// Process each item, maintaining the sorted order of attributes and nested blocks
+	for _, itemName := range itemNames {
+		if _, ok := items[itemName].(*tfjson.SchemaAttribute); ok {
+			// Handle attribute
+			resourceAttrs.SetAttributeRaw(itemName, hclwrite.TokensForIdentifier(fmt.Sprintf("%s.%s", prefix, itemName)))
+			t.logger.Log("debug", "Added attribute: %s.%s", prefix, itemName)
+		} else if blockSchema, ok := items[itemName].(*tfjson.SchemaBlockType); ok && blockSchema != nil {
+			// Handle nested block
+			t.logger.Log("debug", "Processing nested block: %s", itemName)
+			resourceAttrs.AppendNewline()
+			dynamicBlock := hclwrite.NewBlock("dynamic", []string{itemName})
+			dynamicBody := dynamicBlock.Body()
+			dynamicBody.SetAttributeRaw("for_each", hclwrite.TokensForIdentifier(t.dynamicForEach(prefix+"."+itemName)))
+			iterator := t.setIterator(dynamicBody, itemName, depth)
+
+			contentBlock := hclwrite.NewBlock("content", nil)
+			contentBody := contentBlock.Body()
+			t.handleAttributesAndNestedBlocks(contentBody, blockSchema.Block.Attributes, blockSchema.Block.NestedBlocks, fmt.Sprintf("%s.value", iterator), depth+1)
+
+			dynamicBody.AppendBlock(contentBlock)
+			resourceAttrs.AppendBlock(dynamicBlock)
+			resourceAttrs.AppendNewline()
+		}
+	}
+}
+
+// isRequiredItem reports whether a schema attribute is required or a nested block needs at least one item
+func isRequiredItem(item interface{}) bool {
+	switch schema := item.(type) {
+	case *tfjson.SchemaAttribute:
+		return schema != nil && schema.Required
+	case *tfjson.SchemaBlockType:
+		return schema != nil && schema.MinItems > 0
+	}
+	return false
+}
+
+// setIterator returns the iterator name of a dynamic block, setting a short per-level iterator
+// (it, it2, it3, ...) when ShortIterators is enabled so nested blocks never shadow each other
+func (t *Tf) setIterator(dynamicBody *hclwrite.Body, blockName string, depth int) string {
+	if !t.opts.ShortIterators {
+		return blockName
+	}
+	iterator := "it"
+	if depth > 1 {
+		iterator = fmt.Sprintf("it%d", depth)
+	}
+	dynamicBody.SetAttributeRaw("iterator", hclwrite.TokensForIdentifier(iterator))
+	return iterator
+}
+
+// deriveVariableName removes the provider prefix and pluralizes the resource name
+func (t *Tf) deriveVariableName(resource tmcgParsing.Resource) string {
+	if alias, exists := t.opts.ResourceAliases[resource.Name]; exists {
+		return alias
+	}
+	if resourceName, found := strings.CutPrefix(resource.Name, resource.TypePrefix()+"_"); found {
+		if t.opts.SimplePlural {
+			return simplePlural(resourceName)
+		}
+		pluralizer := pluralize.NewClient()
+		return pluralizer.Plural(resourceName)
+	}
+	return resource.Name
+}
+
+// simplePlural pluralizes a name by its ending alone: words ending in s, x, z, ch or sh take es,
+// a consonant followed by y becomes ies, and everything else takes s
+func simplePlural(name string) string {
+	switch {
+	case strings.HasSuffix(name, "s"), strings.HasSuffix(name, "x"), strings.HasSuffix(name, "z"),
+		strings.HasSuffix(name, "ch"), strings.HasSuffix(name, "sh"):
+		return name + "es"
+	case len(name) > 1 && strings.HasSuffix(name, "y") && !strings.ContainsRune("aeiou", rune(name[len(name)-2])):
+		return name[:len(name)-1] + "ies"
+	}
+	return name + "s"
+}
+
+// ValidateKeyAttributes checks the key attribute set in the settings of multiple-mode resources against
+// their schemas, after include and exclude. A list keyed by the attribute needs a required string, unless
+// null keys fall back to the index; a map writes its keys into the attribute, which must be a string. The
+// key attribute is unused with count iteration, by index keys and by --key-var maps.
+func (t *Tf) ValidateKeyAttributes(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) error {
+	for _, resource := range resources {
+		listKeyed := !t.mapVariable(resource) && t.opts.KeyMode != tmcgParsing.KeyModeIndex
+		if resource.KeyAttr == "" || resource.Mode != "multiple" || t.countIteration(resource) || !(listKeyed || t.forEachMap(resource)) {
+			continue
+		}
+		resourceSchema, exists := t.lookupResourceSchema(cleanedSchema, resource)
+		if !exists || resourceSchema.Block == nil {
+			// Resources without a schema are reported as skipped
+			continue
+		}
+
+		attrSchema := resourceSchema.Block.Attributes[resource.KeyAttr]
+		switch {
+		case attrSchema == nil:
+			return fmt.Errorf("key_attr %s of resource %s is not a settable attribute of its schema, or is excluded", resource.KeyAttr, resource.Name)
+		case attrSchema.AttributeType == cty.NilType || !attrSchema.AttributeType.Equals(cty.String):
+			return fmt.Errorf("key_attr %s of resource %s must be a string attribute", resource.KeyAttr, resource.Name)
+		case listKeyed && !attrSchema.Required && t.opts.KeyMode != tmcgParsing.KeyModeCoalesce:
+			return fmt.Errorf("key_attr %s of resource %s is optional, so keys may be null; use a required attribute or --key-mode coalesce", resource.KeyAttr, resource.Name)
+		}
+	}
+	return nil
+}
+
+// CreateVariablesTF generates the variables.tf file based on resource schemas
+func (t *Tf) CreateVariablesTF(dir string, cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, descAsCommentsFlag bool) error {
+	t.logger.Log("info", "Starting to generate variables.tf in directory: %s", dir)
+
+	// Validate inputs
+	if len(resources) == 0 {
+		t.logger.Log("warn", "No resources specified. Skipping variables.tf generation.")
+		return nil
+	}
+
+	content, err := t.RenderVariablesTF(cleanedSchema, resources, descAsCommentsFlag)
+	if err != nil {
+		return err
+	}
+
+	// Leave variables that are already declared alone when appending
+	filePath := filepath.Join(dir, "variables.tf")
+	if t.opts.Append {
+		existing, err := t.existingContent(filePath)
+		if err != nil {
+			return err
+		}
+		present, err := existingBlocks(existing, filePath, "variable")
+		if err != nil {
+			return err
+		}
+		rendered, diags := hclwrite.ParseConfig(content, filePath, hcl.InitialPos)
+		if diags.HasErrors() {
+			return fmt.Errorf("failed to parse generated variables: %s", diags.Error())
+		}
+		added := 0
+		for _, block := range rendered.Body().Blocks() {
+			if present[strings.Join(block.Labels(), ".")] {
+				rendered.Body().RemoveBlock(block)
+				continue
+			}
+			added++
+		}
+		if added == 0 {
+			t.logger.Log("info", "variables.tf already declares every variable. Skipping variables.tf generation.")
+			return nil
+		}
+		t.cleanupHCLFile(rendered)
+		content = appendContent(existing, rendered.Bytes())
+	}
+
+	// Write to disk
+	t.logger.Log("info", "Writing variables.tf to: %s", filePath)
+	err = t.writeGeneratedFile(filePath, content)
+
+	if err != nil {
+		t.logger.Log("error", "Failed to write variables.tf: %v", err)
+		return fmt.Errorf("failed to write variables.tf to %s: %w", filePath, err)
+	}
+
+	t.logger.Log("info", "Successfully generated variables.tf in directory: %s", dir)
+	return nil
+}
+
+// RenderVariablesTF renders the variable blocks for the given resources without writing them to disk
+func (t *Tf) RenderVariablesTF(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, descAsCommentsFlag bool) ([]byte, error) {
+	content, _ := t.renderVariablesTF(cleanedSchema, resources, descAsCommentsFlag, true)
+	return content, nil
+}
+
+// renderVariablesTF does the work of RenderVariablesTF, reporting whether any resource has a tag attribute
+// for AutoTags. The common tags variable is only declared with declareCommonTags, so files rendered
+// for separate groups of resources declare it once.
+func (t *Tf) renderVariablesTF(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, descAsCommentsFlag bool, declareCommonTags bool) ([]byte, bool) {
+	t.sharedSingleTypes = sharedSingleTypes(resources)
+	t.snakeCollisions = t.snakeCaseCollisions(cleanedSchema, resources)
+	collisions := make([]string, 0, len(t.snakeCollisions))
+	for path := range t.snakeCollisions {
+		collisions = append(collisions, path)
+	}
+	sort.Strings(collisions)
+	for _, path := range collisions {
+		t.logger.Log("warn", "Keeping the variable name of %s as in the schema, as its snake case collides with another attribute or block", path)
+	}
+	t.warnUnknownDefaults(cleanedSchema, resources)
+	t.warnUnknownTypeOverrides(cleanedSchema, resources)
+	t.warnUnknownDescriptions(cleanedSchema, resources)
+
+	tagged := make([]bool, len(resources))
+	file := t.renderResources(resources, func(index int, resource tmcgParsing.Resource) []byte {
+		content, tagAttribute := t.renderResourceVariables(cleanedSchema, resource, descAsCommentsFlag)
+		tagged[index] = tagAttribute != ""
+		return content
+	})
+	hasTags := slices.Contains(tagged, true)
+	if hasTags && declareCommonTags {
+		variableBody := file.Body().AppendNewBlock("variable", []string{commonTagsVariable}).Body()
+		variableBody.SetAttributeValue("description", cty.StringVal("Tags merged into the tags (or labels) of every resource that supports them"))
+		variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("map(string)"))
+		variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("{}"))
+		file.Body().AppendNewline()
+	}
+	content := t.orderVariableSettings(file.Bytes())
+	if t.opts.GlobalVarSort {
+		return t.sortVariableBlocks(content), hasTags
+	}
+	return content, hasTags
+}
+
+// sortVariableBlocks reorders the variable blocks of rendered content, with the comments right above them,
+// so required variables (those without a default) come first and each group is sorted by name. Other
+// blocks keep their order after the variables.
+func (t *Tf) sortVariableBlocks(content []byte) []byte {
+	file, diags := hclsyntax.ParseConfig(content, "variables.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.logger.Log("warn", "Leaving variables unsorted, as they failed to parse: %s", diags.Error())
+		return content
+	}
+
+	type chunk struct {
+		name     string
+		required bool
+		content  []byte
+	}
+	var variables, others []chunk
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		start, end := commentsAbove(content, block.Range().Start.Byte, 0), block.Range().End.Byte
+
+		item := chunk{content: bytes.TrimSpace(content[start:end])}
+		if block.Type != "variable" || len(block.Labels) == 0 {
+			others = append(others, item)
+			continue
+		}
+		_, hasDefault := block.Body.Attributes["default"]
+		item.name, item.required = block.Labels[0], !hasDefault
+		variables = append(variables, item)
+	}
+	sort.SliceStable(variables, func(i, j int) bool {
+		if variables[i].required != variables[j].required {
+			return variables[i].required
+		}
+		return variables[i].name < variables[j].name
+	})
+
+	var buffer bytes.Buffer
+	for _, item := range append(variables, others...) {
+		buffer.Write(item.content)
+		buffer.WriteString("\n\n")
+	}
+	return buffer.Bytes()
+}
+
+// commentsAbove moves start, the beginning of a line, up over the comment lines directly above it,
+// stopping at limit
+func commentsAbove(content []byte, start, limit int) int {
+	for start > limit {
+		lineStart := bytes.LastIndexByte(content[:start-1], '\n') + 1
+		if lineStart < limit || !bytes.HasPrefix(bytes.TrimSpace(content[lineStart:start]), []byte("#")) {
+			break
+		}
+		start = lineStart
+	}
+	return start
+}
+
+// variableSettingOrder is the order of the settings within variable blocks
+var variableSettingOrder = []string{"description", "type", "default", "sensitive", "ephemeral", "nullable", "validation"}
+
+// orderVariableSettings rewrites the variable blocks of rendered content so their settings, with the comment
+// lines right above them, follow variableSettingOrder. Unknown settings keep their order after the known
+// ones. Blocks with content between their settings, or settings sharing a line, are left as they are.
+func (t *Tf) orderVariableSettings(content []byte) []byte {
+	file, diags := hclsyntax.ParseConfig(content, "variables.tf", hcl.InitialPos)
+	if diags.HasErrors() {
+		t.logger.Log("warn", "Leaving variable settings unordered, as the variables failed to parse: %s", diags.Error())
+		return content
+	}
+
+	type setting struct {
+		rank       int
+		start, end int
+	}
+	var buffer bytes.Buffer
+	offset := 0
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Type != "variable" {
+			continue
+		}
+		bodyStart := block.OpenBraceRange.End.Byte
+		bodyEnd := bytes.LastIndexByte(content[:block.CloseBraceRange.Start.Byte], '\n') + 1
+
+		var settings []setting
+		add := func(name string, rng hcl.Range) {
+			rank := slices.Index(variableSettingOrder, name)
+			if rank < 0 {
+				rank = len(variableSettingOrder)
+			}
+			start := bytes.LastIndexByte(content[:rng.Start.Byte], '\n') + 1
+			end := rng.End.Byte
+			if newline := bytes.IndexByte(content[end:], '\n'); newline >= 0 {
+				end += newline + 1
+			}
+			settings = append(settings, setting{rank: rank, start: commentsAbove(content, start, bodyStart), end: end})
+		}
+		for name, attribute := range block.Body.Attributes {
+			add(name, attribute.SrcRange)
+		}
+		for _, nested := range block.Body.Blocks {
+			add(nested.Type, nested.Range())
+		}
+		if len(settings) < 2 {
+			continue
+		}
+
+		// Only reorder when the settings, each on lines of its own, cover the whole body
+		sort.Slice(settings, func(i, j int) bool { return settings[i].start < settings[j].start })
+		covered := bodyStart
+		for _, item := range settings {
+			if item.start < covered || len(bytes.TrimSpace(content[covered:item.start])) > 0 {
+				covered = -1
+				break
+			}
+			covered = item.end
+		}
+		if covered < 0 || covered > bodyEnd || len(bytes.TrimSpace(content[covered:bodyEnd])) > 0 {
+			continue
+		}
+
+		sort.SliceStable(settings, func(i, j int) bool { return settings[i].rank < settings[j].rank })
+		buffer.Write(content[offset:bodyStart])
+		buffer.WriteString("\n")
+		for _, item := range settings {
+			buffer.Write(content[item.start:item.end])
+		}
+		offset = bodyEnd
+	}
+	buffer.Write(content[offset:])
+	return buffer.Bytes()
+}
+
+// renderMainResource renders the resource block of one resource as unformatted HCL, together with the
+// centralized defaults it falls back to
+func (t *Tf) renderMainResource(cleanedSchema map[string]*tfjson.ProviderSchema, resource tmcgParsing.Resource) ([]byte, []hclwrite.ObjectAttrTokens) {
+	file := hclwrite.NewEmptyFile()
+	localDefaults := []hclwrite.ObjectAttrTokens{}
+
+	t.logger.Log("debug", "Processing resource: %s with provider: %s/%s", resource.Name, resource.Provider.Namespace, resource.Provider.Name)
+
+	// Get the resource schema
+	resourceSchema, exists := t.lookupResourceSchema(cleanedSchema, resource)
+	if !exists {
+		return nil, nil
+	}
+
+	// Derive the variable name
+	variableName := t.deriveVariableName(resource)
+	t.logger.Log("debug", "Derived variable name for resource: %s", variableName)
+
+	// Create the resource block
+	resourceBlock := file.Body().AppendNewBlock("resource", []string{resource.Name, resource.BlockLabel()})
+	resourceAttrs := resourceBlock.Body()
+
+	// Create toggleable resources only when their enabled variable is set
+	if t.toggleable(resource) {
+		countExpression := fmt.Sprintf("var.%s ? 1 : 0", t.enabledVariableName(resource))
+		resourceAttrs.SetAttributeRaw("count", hclwrite.TokensForIdentifier(countExpression))
+		t.logger.Log("debug", "Added count expression: %s", countExpression)
+	} else if t.toggledOnNull(resource) {
+		// The references into the object are only evaluated while count creates the resource
+		countExpression := fmt.Sprintf("var.%s == null ? 0 : 1", t.resourceVariablePrefix(resource))
+		resourceAttrs.SetAttributeRaw("count", hclwrite.TokensForIdentifier(countExpression))
+		t.logger.Log("debug", "Added count expression: %s", countExpression)
+	}
+
+	// Handle resource mode (single/multiple)
+	forEachMap := t.forEachMap(resource)
+	itemReference := "each.value"
+	if t.countIteration(resource) {
+		// Index the list variable by position
+		countExpression := fmt.Sprintf("length(coalesce(var.%s, []))", variableName)
+		if t.opts.NoCoalesce {
+			countExpression = fmt.Sprintf("length(var.%s)", variableName)
+		}
+		itemReference = fmt.Sprintf("var.%s[count.index]", variableName)
+		resourceAttrs.SetAttributeRaw("count", hclwrite.TokensForIdentifier(countExpression))
+		t.logger.Log("debug", "Added count expression: %s", countExpression)
+	} else if resource.Mode == "multiple" {
+		// Add the `for_each` block using the derived variable name
+		forEachExpression := t.forEachList(resource, fmt.Sprintf("coalesce(var.%s, [])", variableName))
+		if t.mapVariable(resource) {
+			forEachExpression = fmt.Sprintf("coalesce(var.%s, {})", variableName)
+		}
+		if t.opts.NoCoalesce {
+			forEachExpression = t.forEachList(resource, "var."+variableName)
+			if t.mapVariable(resource) {
+				forEachExpression = "var." + variableName
+			}
+		}
+		resourceAttrs.SetAttributeRaw("for_each", hclwrite.TokensForIdentifier(forEachExpression))
+		t.logger.Log("debug", "Added for_each expression: %s", forEachExpression)
+	}
+
+	// Add the provider meta-argument for resources bound to an aliased provider
+	if providerRef, exists := t.opts.ResourceProviders[resource.Name]; exists {
+		resourceAttrs.SetAttributeRaw("provider", hclwrite.TokensForIdentifier(providerRef))
+		t.logger.Log("debug", "Added provider meta-argument: %s", providerRef)
+	}
+	if _, exists := t.opts.PerKeyProviders[resource.Name]; exists && t.countIteration(resource) {
+		t.logger.Log("warn", "Ignoring the per-key provider of %s, which iterates with count and has no each.key", resource.Name)
+	}
+	if providerRef, exists := t.opts.PerKeyProviders[resource.Name]; exists && resource.Mode == "multiple" && !t.countIteration(resource) {
+		providerExpression := providerRef + "[each.key]"
+		resourceAttrs.SetAttributeRaw("provider", hclwrite.TokensForIdentifier(providerExpression))
+		t.logger.Log("debug", "Added per-key provider meta-argument: %s", providerExpression)
+	} else if (!resource.ImpliedProvider() || t.ambiguousTypes[resource.Name]) && resource.Provider.NameLower != "" && resourceAttrs.GetAttribute("provider") == nil {
+		// Resources of providers like google-beta would otherwise be bound to the provider named by their prefix,
+		// and types that several providers expose name their provider explicitly
+		resourceAttrs.SetAttributeRaw("provider", hclwrite.TokensForIdentifier(resource.Provider.Local()))
+		t.logger.Log("debug", "Added provider meta-argument: %s", resource.Provider.Local())
+	}
+
+	// Collect attributes and nested blocks together
+	totalItems := make([]string, 0, len(resourceSchema.Block.Attributes)+len(resourceSchema.Block.NestedBlocks))
+	for name := range resourceSchema.Block.Attributes {
+		totalItems = append(totalItems, name)
+	}
+	for name := range resourceSchema.Block.NestedBlocks {
+		totalItems = append(totalItems, name)
+	}
+	sort.Strings(totalItems)
+
+	// Process sorted attributes and nested blocks
+	for _, itemName := range totalItems {
+		// Check if the item is an attribute
+		if attrSchema, ok := resourceSchema.Block.Attributes[itemName]; ok {
+			if resource.Mode == "single" {
+				reference := t.singleReference(resource, itemName, false)
+				if defaultTokens, ok := t.localDefault(resource, itemName, attrSchema); ok {
+					key := strings.ReplaceAll(strings.TrimPrefix(reference, "var."), ".", "_")
+					localDefaults = append(localDefaults, hclwrite.ObjectAttrTokens{Name: hclwrite.TokensForIdentifier(key), Value: defaultTokens})
+					reference = localDefaultReference(reference, "local.defaults."+key, t.attributeTypeFor(resource.Name+"."+itemName, attrSchema))
+				}
+				resourceAttrs.SetAttributeRaw(itemName, hclwrite.TokensForIdentifier(reference))
+				t.logger.Log("debug", "Added attribute: %s = %s", itemName, reference)
+			} else if forEachMap && itemName == t.keyAttr(resource) {
+				// The map key supplies the name of each instance
+				resourceAttrs.SetAttributeRaw(itemName, hclwrite.TokensForIdentifier("each.key"))
+				t.logger.Log("debug", "Added attribute: %s = each.key", itemName)
+			} else {
+				t.handleAttributesAndNestedBlocks(resourceAttrs, map[string]*tfjson.SchemaAttribute{itemName: attrSchema}, nil, itemReference, 1)
+			}
+			continue
+		}
+
+		// Otherwise, it must be a nested block
+		blockSchema := resourceSchema.Block.NestedBlocks[itemName]
+		if blockSchema == nil || blockSchema.Block == nil {
+			t.skip("Skipping invalid nested block: %s", resource.Name+"."+itemName)
+			continue
+		}
+
+		resourceBlock.Body().AppendNewline()
+		dynamicBlock := hclwrite.NewBlock("dynamic", []string{itemName})
+		dynamicBody := dynamicBlock.Body()
+
+		// Determine the reference based on the resource mode
+		reference := itemReference + "." + itemName
+		if resource.Mode != "multiple" {
+			reference = t.singleReference(resource, itemName, true)
+		}
+
+		dynamicBody.SetAttributeRaw("for_each", hclwrite.TokensForIdentifier(t.dynamicForEach(reference)))
+		iterator := t.setIterator(dynamicBody, itemName, 1)
+
+		contentBlock := hclwrite.NewBlock("content", nil)
+		contentBody := contentBlock.Body()
+		t.handleAttributesAndNestedBlocks(contentBody, blockSchema.Block.Attributes, blockSchema.Block.NestedBlocks, fmt.Sprintf("%s.value", iterator), 2)
+
+		dynamicBody.AppendBlock(contentBlock)
+		resourceAttrs.AppendBlock(dynamicBlock)
+		resourceBlock.Body().AppendNewline()
+
+		t.logger.Log("debug", "Added dynamic block for nested block: %s", itemName)
+	}
+
+	// Merge the common tags into the resource's own, which merge skips while they are null
+	if tagAttribute := t.tagAttribute(resourceSchema.Block); tagAttribute != "" {
+		if attribute := resourceAttrs.GetAttribute(tagAttribute); attribute != nil {
+			expression := hclwrite.TokensForIdentifier(fmt.Sprintf("merge(var.%s, ", commonTagsVariable))
+			expression = append(expression, attribute.Expr().BuildTokens(nil)...)
+			expression = append(expression, hclwrite.TokensForIdentifier(")")...)
+			resourceAttrs.SetAttributeRaw(tagAttribute, expression)
+			t.logger.Log("debug", "Merged common tags into attribute: %s", tagAttribute)
+		}
+	}
+
+	// Guard the resource with lifecycle preconditions, after the generated attributes
+	if preconditions := t.opts.Preconditions[resource.Name+"."+resource.BlockLabel()]; len(preconditions) > 0 {
+		resourceAttrs.AppendNewline()
+		lifecycleBody := resourceAttrs.AppendNewBlock("lifecycle", nil).Body()
+		for _, precondition := range preconditions {
+			preconditionBody := lifecycleBody.AppendNewBlock("precondition", nil).Body()
+			preconditionBody.SetAttributeRaw("condition", hclwrite.TokensForIdentifier(precondition.Condition))
+			preconditionBody.SetAttributeValue("error_message", cty.StringVal(precondition.ErrorMessage))
+		}
+		t.logger.Log("debug", "Added %d precondition(s) to resource %s", len(preconditions), resource.Name)
+	}
+
+	// Append raw HCL for what the schema does not model, after the generated attributes
+	for _, snippet := range t.opts.ExtraHCL[resource.Name] {
+		resourceAttrs.AppendNewline()
+		resourceAttrs.AppendUnstructuredTokens(hclwrite.TokensForIdentifier(snippet))
+		resourceAttrs.AppendNewline()
+		t.logger.Log("debug", "Added extra HCL to resource %s: %s", resource.Name, snippet)
+	}
+
+	// Add a newline after each resource block
+	file.Body().AppendNewline()
+
+	return file.Body().BuildTokens(nil).Bytes(), localDefaults
+}
+
+// renderResourceVariables renders the variable blocks of one resource as unformatted HCL, together with
+// the tag attribute that AutoTags merges the common tags into, if any
+func (t *Tf) renderResourceVariables(cleanedSchema map[string]*tfjson.ProviderSchema, resource tmcgParsing.Resource, descAsCommentsFlag bool) ([]byte, string) {
+	file := hclwrite.NewEmptyFile()
+	rootBody := file.Body()
+
+	// Retrieve the schema for the resource
+	resourceSchema, exists := t.lookupResourceSchema(cleanedSchema, resource)
+	if !exists {
+		return nil, ""
+	}
+
+	// Derive the variable name
+	variableName := t.deriveVariableName(resource)
+
+	// Add the flag that toggles the resource
+	if t.toggleable(resource) {
+		variableBody := rootBody.AppendNewBlock("variable", []string{t.enabledVariableName(resource)}).Body()
+		variableBody.SetAttributeValue("description", cty.StringVal(fmt.Sprintf("Whether to create the %s resource", t.resourceDisplayName(resource))))
+		variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("bool"))
+		variableBody.SetAttributeValue("default", cty.True)
+		rootBody.AppendNewline()
+	}
+
+	if resource.Mode == "multiple" {
+		// Handle multiple mode, explaining how list items are keyed as that is easy to get wrong
+		if comment := t.forEachKeyComment(resource); comment != "" {
+			if _, exists := t.opts.ResourceAliases[resource.Name]; exists {
+				comment = t.resourceDisplayName(resource) + ": " + comment
+			}
+			rootBody.AppendUnstructuredTokens(hclwrite.Tokens{
+				{Type: hclsyntax.TokenComment, Bytes: []byte("# " + comment + "\n")},
+			})
+		}
+		variableBlock := rootBody.AppendNewBlock("variable", []string{variableName})
+		variableBody := variableBlock.Body()
+		attributes := resourceSchema.Block.Attributes
+		if t.forEachMap(resource) {
+			// The name comes from the map key, so it is not part of the object
+			variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("map(object({"))
+			attributes = make(map[string]*tfjson.SchemaAttribute, len(resourceSchema.Block.Attributes))
+			for name, attrSchema := range resourceSchema.Block.Attributes {
+				if name != t.keyAttr(resource) {
+					attributes[name] = attrSchema
+				}
+			}
+		} else if t.mapVariable(resource) {
+			variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("map(object({"))
+		} else {
+			variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("list(object({"))
+		}
+
+		// Process attributes and nested blocks
+		t.handleAttributesAndNestedBlocksForVariable(variableBody, attributes, resourceSchema.Block.NestedBlocks, resource.Name, 1, true, descAsCommentsFlag)
+
+		// Close the variable type definition
+		variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
+			{Type: hclsyntax.TokenIdent, Bytes: []byte("}))")},
+			{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		})
+
+		// Without coalesce guards, main.tf iterates the variable directly, and a non-nullable variable
+		// cannot default to null, so both default to empty. Required variables have no default at all.
+		defaultValue := "null"
+		if t.opts.NoCoalesce || t.opts.NonNullable {
+			defaultValue = "[]"
+			if t.mapVariable(resource) {
+				defaultValue = "{}"
+			}
+		}
+		if !t.opts.RequiredMultiple {
+			variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier(defaultValue))
+		}
+		if t.opts.NonNullable {
+			variableBody.SetAttributeValue("nullable", cty.False)
+		}
+		rootBody.AppendNewline()
+	} else if t.singleRefStyle() == tmcgParsing.SingleRefObject {
+		// Handle single mode with one object variable per resource
+		variableBlock := rootBody.AppendNewBlock("variable", []string{t.resourceVariablePrefix(resource)})
+		variableBody := variableBlock.Body()
+		variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("object({"))
+		t.handleAttributesAndNestedBlocksForVariable(variableBody, resourceSchema.Block.Attributes, resourceSchema.Block.NestedBlocks, resource.Name, 1, true, descAsCommentsFlag)
+		variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
+			{Type: hclsyntax.TokenIdent, Bytes: []byte("})")},
+			{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+		})
+
+		// The object can only default to empty when none of its attributes are required. A resource
+		// toggled on null is left out until the object is set.
+		required := false
+		for _, attrSchema := range resourceSchema.Block.Attributes {
+			required = required || (attrSchema != nil && attrSchema.Required)
+		}
+		if t.toggledOnNull(resource) {
+			variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("null"))
+		} else if !required {
+			variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("{}"))
+		}
+		rootBody.AppendNewline()
+	} else {
+		// Handle single mode
+		totalItems := make([]string, 0, len(resourceSchema.Block.Attributes)+len(resourceSchema.Block.NestedBlocks))
+		for name := range resourceSchema.Block.Attributes {
+			totalItems = append(totalItems, name)
+		}
+		for name := range resourceSchema.Block.NestedBlocks {
+			totalItems = append(totalItems, name)
+		}
+		sort.Strings(totalItems)
+
+		for _, itemName := range totalItems {
+			// Check if it's an attribute
+			if attrSchema, ok := resourceSchema.Block.Attributes[itemName]; ok {
+				if attrSchema == nil {
+					t.logger.Log("debug", "Skipping attribute: %s", itemName)
+					continue
+				}
+
+				variableBlock := rootBody.AppendNewBlock("variable", []string{t.singleVariableName(resource, itemName, false)})
+				variableBody := variableBlock.Body()
+
+				// Settings are written in variableSettingOrder, which orderVariableSettings enforces
+				// Set description
+				attrDescription := t.descriptionFor(resource.Name+"."+itemName, attrSchema)
+				if t.opts.MultilineDescriptions && strings.Contains(strings.TrimSpace(attrDescription), "\n") {
+					variableBody.SetAttributeRaw("description", heredocTokens(attrDescription))
+				} else if description := strings.ReplaceAll(attrDescription, "\n", " "); description != "" {
+					variableBody.SetAttributeValue("description", cty.StringVal(description))
+				}
+
+				// Set type and default
+				attrTypeStr := t.attributeTypeFor(resource.Name+"."+itemName, attrSchema)
+				if attrTypeStr == "any" && attrSchema.AttributeType.Equals(cty.DynamicPseudoType) {
+					variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
+						{Type: hclsyntax.TokenComment, Bytes: []byte("  # Dynamic attribute: accepts any value (e.g., an object for azapi's body) and is passed through unchanged\n")},
+					})
+				}
+				variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier(attrTypeStr))
+				if attrSchema.Optional {
+					if _, ok := t.localDefault(resource, itemName, attrSchema); ok {
+						// The locals block in main.tf holds the default
+						variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("null"))
+					} else if defaultValue, exists := t.opts.Defaults[resource.Name+"."+itemName]; exists {
+						if t.heredocDefault(defaultValue) {
+							variableBody.SetAttributeRaw("default", heredocValueTokens(defaultValue.AsString()))
+						} else {
+							variableBody.SetAttributeValue("default", defaultValue)
+						}
+					} else {
+						variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier(t.emptyDefault(attrTypeStr)))
+					}
+				}
+
+				// Write-only attributes are never persisted, so their values may come from ephemeral variables
+				if attrSchema.WriteOnly {
+					variableBody.SetAttributeValue("ephemeral", cty.True)
+					t.logger.Log("debug", "Marked variable for write-only attribute %s.%s as ephemeral", resource.Name, itemName)
+				}
+				if t.opts.NonNullable && attrSchema.Required {
+					variableBody.SetAttributeValue("nullable", cty.False)
+				}
+				rootBody.AppendNewline()
+				continue
+			}
+
+			// Handle nested blocks
+			block := resourceSchema.Block.NestedBlocks[itemName]
+			if block == nil || block.Block == nil {
+				t.skip("Skipping invalid nested block: %s", resource.Name+"."+itemName)
+				continue
+			}
+
+			variableBlock := rootBody.AppendNewBlock("variable", []string{t.singleVariableName(resource, itemName, true)})
+			variableBody := variableBlock.Body()
+
+			// Determine block type
+			inconsistent := t.inconsistentItems(block, resource.Name+"."+itemName)
+			typeStr := "object({"
+			if block.MaxItems != 1 || inconsistent {
+				typeStr = "list(object({"
+			}
+			variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier(typeStr))
+
+			// Process nested attributes and blocks
+			t.handleAttributesAndNestedBlocksForVariable(variableBody, block.Block.Attributes, block.Block.NestedBlocks, resource.Name+"."+itemName, 1, true, descAsCommentsFlag)
+
+			// Close block
+			closingString := "})"
+			if block.MaxItems != 1 || inconsistent {
+				closingString = "}))"
+			}
+			variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
+				{Type: hclsyntax.TokenIdent, Bytes: []byte(closingString)},
+				{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
+			})
+			rootBody.AppendNewline()
+
+			// Set default for optional blocks, an empty list for repeated blocks with EmptyCollectionDefaults,
+			// unless optional blocks are configured to go without one
+			if (block.MinItems == 0 || inconsistent) && t.opts.OptionalBlockDefault != tmcgParsing.OptionalBlockDefaultNone {
+				defaultValue := "null"
+				if t.opts.EmptyCollectionDefaults && (block.MaxItems != 1 || inconsistent) {
+					defaultValue = "[]"
+				}
+				variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier(defaultValue))
+			}
+		}
+	}
+
+	return file.Body().BuildTokens(nil).Bytes(), t.tagAttribute(resourceSchema.Block)
+}
+
+// renderResources renders every resource with render and joins the results in resource order into one
+// cleaned-up file. With ParallelResources above one, that many workers (at most Concurrency) render
+// resources concurrently.
+func (t *Tf) renderResources(resources []tmcgParsing.Resource, render func(index int, resource tmcgParsing.Resource) []byte) *hclwrite.File {
+	rendered := make([][]byte, len(resources))
+	if workers := t.workers(t.opts.ParallelResources, len(resources)); workers > 1 {
+		indexes := make(chan int)
+		var wg sync.WaitGroup
+		for range workers {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for index := range indexes {
+					rendered[index] = render(index, resources[index])
+				}
+			}()
+		}
+		for index := range resources {
+			indexes <- index
+		}
+		close(indexes)
+		wg.Wait()
+	} else {
+		for index, resource := range resources {
+			rendered[index] = render(index, resource)
+		}
+	}
+
+	file := hclwrite.NewEmptyFile()
+	first := true
+	for index, content := range rendered {
+		if t.opts.SectionHeaders && len(bytes.TrimSpace(content)) > 0 {
+			header := sectionHeader(resources[index])
+			if first {
+				header = bytes.TrimLeft(header, "\n")
+				first = false
+			}
+			content = append(header, content...)
+		}
+		file.Body().AppendUnstructuredTokens(hclwrite.Tokens{{Type: hclsyntax.TokenIdent, Bytes: content}})
+	}
+	t.cleanupHCLFile(file)
+	return file
+}
+
+// sectionHeader returns the comment dividing a resource's blocks from those of the resource before it,
+// naming the resource and its custom label if any
+func sectionHeader(resource tmcgParsing.Resource) []byte {
+	name := resource.Name
+	if resource.Label != "" {
+		name += "." + resource.Label
+	}
+	return []byte("\n# --- " + name + " ---\n")
+}
+
+// workers returns how many workers a pool of the requested size gets for the given number of jobs,
+// capped by Concurrency
+func (t *Tf) workers(requested, jobs int) int {
+	workers := min(requested, jobs)
+	if t.opts.Concurrency > 0 {
+		workers = min(workers, t.opts.Concurrency)
+	}
+	return workers
+}
+
+// skip logs a warning about an item left out of the generated files and records it for Skipped
+func (t *Tf) skip(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	t.logger.Log("warn", "%s", message)
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if !slices.Contains(t.skipped, message) {
+		t.skipped = append(t.skipped, message)
+	}
+}
+
+// Skipped returns the distinct warnings about resources and blocks left out of the generated files, sorted
+// so the report does not depend on the order parallel rendering recorded them in
+func (t *Tf) Skipped() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	skipped := append([]string{}, t.skipped...)
+	sort.Strings(skipped)
+	return skipped
+}
+
+// inconsistentItems reports whether a nested block's schema has MinItems greater than a nonzero MaxItems,
+// warning that the block is rendered as an optional list instead
+func (t *Tf) inconsistentItems(block *tfjson.SchemaBlockType, path string) bool {
+	if block.MaxItems == 0 || block.MinItems <= block.MaxItems {
+		return false
+	}
+	t.logger.Log("warn", "Nested block %s has min_items %d greater than max_items %d; treating it as an optional list", path, block.MinItems, block.MaxItems)
+	return true
+}
+
+// forEachList returns the for_each expression turning a list variable into a map, keyed as KeyMode selects
+// by the resource's key attribute
+func (t *Tf) forEachList(resource tmcgParsing.Resource, list string) string {
+	key := t.keyAttr(resource)
+	switch t.opts.KeyMode {
+	case tmcgParsing.KeyModeIndex:
+		return fmt.Sprintf("{ for idx, i in %s : idx => i }", list)
+	case tmcgParsing.KeyModeCoalesce:
+		return fmt.Sprintf("{ for idx, i in %s : (i.%s != null ? i.%s : tostring(idx)) => i }", list, key, key)
+	default:
+		return fmt.Sprintf("{ for i in %s : i.%s => i }", list, key)
+	}
+}
+
+// keyAttr returns the object attribute keying a multiple-mode list in for_each, name unless the resource
+// sets its own
+func (t *Tf) keyAttr(resource tmcgParsing.Resource) string {
+	if resource.KeyAttr != "" {
+		return resource.KeyAttr
+	}
+	return "name"
+}
+
+// forEachMap reports whether a multiple-mode resource iterates over a map keyed by instance name, as its
+// own iteration or ForEachMap selects
+func (t *Tf) forEachMap(resource tmcgParsing.Resource) bool {
+	if resource.Mode != "multiple" {
+		return false
+	}
+	if resource.Iteration != "" {
+		return resource.Iteration == tmcgParsing.IterationMap
+	}
+	return t.opts.ForEachMap[resource.Name]
 }
 
-var writeFile = os.WriteFile
+// countIteration reports whether a multiple-mode resource is created with count over its list variable
+func (t *Tf) countIteration(resource tmcgParsing.Resource) bool {
+	return resource.Mode == "multiple" && resource.Iteration == tmcgParsing.IterationCount
+}
 
-// CreateMainTF generates the main.tf file with resource and dynamic blocks
-func (t *Tf) CreateMainTF(dir string, cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) error {
-	t.logger.Log("info", "Starting to generate main.tf in directory: %s", dir)
+// mapVariable reports whether the variable of a multiple-mode resource is a map rather than a list
+func (t *Tf) mapVariable(resource tmcgParsing.Resource) bool {
+	return t.forEachMap(resource) || (t.opts.KeyVar && !t.countIteration(resource))
+}
 
-	// Validate inputs
-	if len(resources) == 0 {
-		t.logger.Log("warn", "No resources specified. Skipping main.tf generation.")
-		return nil
+// dynamicForEach returns the for_each expression of a dynamic block iterating over reference,
+// guarded against null values unless NoCoalesce is set
+func (t *Tf) dynamicForEach(reference string) string {
+	if t.opts.NoCoalesce {
+		return fmt.Sprintf("flatten([%s])", reference)
 	}
+	return fmt.Sprintf("can(coalesce(%s)) ? flatten([%s]) : []", reference, reference)
+}
 
-	// Create a new HCL file
-	file := hclwrite.NewEmptyFile()
+// singleRefStyle returns the configured single-mode reference style, defaulting to bare
+func (t *Tf) singleRefStyle() string {
+	if t.opts.SingleRefStyle == "" {
+		return tmcgParsing.SingleRefBare
+	}
+	return t.opts.SingleRefStyle
+}
 
-	// Iterate over each resource
-	for _, resource := range resources {
-		t.logger.Log("debug", "Processing resource: %s with provider: %s/%s", resource.Name, resource.Provider.Namespace, resource.Provider.Name)
+// singleVariableName returns the name of the variable holding a single-mode attribute or block
+func (t *Tf) singleVariableName(resource tmcgParsing.Resource, itemName string, isBlock bool) string {
+	if t.opts.AttributeCase == tmcgParsing.AttributeCaseSnake && !t.snakeCollisions[resource.Name+"."+itemName] {
+		itemName = snakeCase(itemName)
+	}
+	if t.singleRefStyle() == tmcgParsing.SingleRefPrefixed {
+		return t.resourceVariablePrefix(resource) + "_" + itemName
+	}
+	if resource.Label != "" && t.sharedSingleTypes[resource.Name] {
+		return resource.Label + "_" + itemName
+	}
+	if isBlock && t.opts.TrimProviderPrefix {
+		return trimProviderPrefix(resource) + "_" + itemName
+	}
+	return itemName
+}
 
-		// Construct the provider key to access the schema
-		providerKey := fmt.Sprintf("registry.terraform.io/%s/%s", resource.Provider.NamespaceLower, resource.Provider.NameLower)
-		providerSchema, exists := cleanedSchema[providerKey]
-		if !exists {
-			t.logger.Log("warn", "No schema found for provider: %s", providerKey)
+// snakeCase lower-cases a name and separates its words with single underscores, splitting camel case
+// (including acronyms, as in HTTPEndpoint) and replacing other characters
+func snakeCase(name string) string {
+	runes := []rune(name)
+	var builder strings.Builder
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			builder.WriteRune('_')
 			continue
 		}
-
-		// Get the resource schema
-		resourceSchema, exists := providerSchema.ResourceSchemas[resource.Name]
-		if !exists {
-			t.logger.Log("warn", "No schema found for resource: %s with provider: %s/%s", resource.Name, resource.Provider.Namespace, resource.Provider.Name)
-			continue
+		if unicode.IsUpper(r) && i > 0 {
+			previous := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(previous) || unicode.IsDigit(previous) || (unicode.IsUpper(previous) && nextLower) {
+				builder.WriteRune('_')
+			}
 		}
+		builder.WriteRune(unicode.ToLower(r))
+	}
 
-		// Derive the variable name
-		variableName := t.deriveVariableName(resource.Name)
-		t.logger.Log("debug", "Derived variable name for resource: %s", variableName)
+	// Collapse the separators of adjacent boundaries and trim them from the ends
+	parts := strings.FieldsFunc(builder.String(), func(r rune) bool { return r == '_' })
+	if len(parts) == 0 {
+		return name
+	}
+	return strings.Join(parts, "_")
+}
 
-		// Create the resource block
-		resourceBlock := file.Body().AppendNewBlock("resource", []string{resource.Name, "this"})
-		resourceAttrs := resourceBlock.Body()
+// snakeCaseCollisions returns the "type.item" paths of single-mode attributes and blocks whose snake case
+// is shared with another item of their resource, such as maxConns and max_conns, so they keep their
+// distinct schema names instead of declaring the same variable twice
+func (t *Tf) snakeCaseCollisions(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) map[string]bool {
+	collisions := make(map[string]bool)
+	if t.opts.AttributeCase != tmcgParsing.AttributeCaseSnake {
+		return collisions
+	}
 
-		// Handle resource mode (single/multiple)
-		if resource.Mode == "multiple" {
-			// Add the `for_each` block using the derived variable name
-			forEachExpression := fmt.Sprintf("{ for i in coalesce(var.%s, []) : i.name => i }", variableName)
-			resourceAttrs.SetAttributeRaw("for_each", hclwrite.TokensForIdentifier(forEachExpression))
-			t.logger.Log("debug", "Added for_each expression: %s", forEachExpression)
+	for _, resource := range resources {
+		resourceSchema, exists := t.lookupResourceSchema(cleanedSchema, resource)
+		if resource.Mode != "single" || !exists || resourceSchema.Block == nil {
+			continue
 		}
 
-		// Collect attributes and nested blocks together
-		totalItems := make([]string, 0, len(resourceSchema.Block.Attributes)+len(resourceSchema.Block.NestedBlocks))
+		items := make(map[string][]string)
 		for name := range resourceSchema.Block.Attributes {
-			totalItems = append(totalItems, name)
+			items[snakeCase(name)] = append(items[snakeCase(name)], name)
 		}
 		for name := range resourceSchema.Block.NestedBlocks {
-			totalItems = append(totalItems, name)
+			items[snakeCase(name)] = append(items[snakeCase(name)], name)
 		}
-		sort.Strings(totalItems)
-
-		// Process sorted attributes and nested blocks
-		for _, itemName := range totalItems {
-			// Check if the item is an attribute
-			if attrSchema, ok := resourceSchema.Block.Attributes[itemName]; ok {
-				if resource.Mode == "single" {
-					resourceAttrs.SetAttributeRaw(itemName, hclwrite.TokensForIdentifier(fmt.Sprintf("var.%s", itemName)))
-					t.logger.Log("debug", "Added attribute: %s = var.%s", itemName, itemName)
-				} else {
-					t.handleAttributesAndNestedBlocks(resourceAttrs, map[string]*tfjson.SchemaAttribute{itemName: attrSchema}, nil, "each.value")
-				}
+		for _, names := range items {
+			if len(names) < 2 {
 				continue
 			}
-
-			// Otherwise, it must be a nested block
-			blockSchema := resourceSchema.Block.NestedBlocks[itemName]
-			if blockSchema == nil || blockSchema.Block == nil {
-				t.logger.Log("warn", "Skipping invalid nested block: %s in resource: %s", itemName, resource.Name)
-				continue
+			for _, name := range names {
+				collisions[resource.Name+"."+name] = true
 			}
+		}
+	}
+	return collisions
+}
 
-			resourceBlock.Body().AppendNewline()
-			dynamicBlock := hclwrite.NewBlock("dynamic", []string{itemName})
-			dynamicBody := dynamicBlock.Body()
-
-			// Determine the prefix based on the resource mode
-			prefix := "var."
-			if resource.Mode == "multiple" {
-				prefix = "each.value."
-			}
+// toggleable reports whether a resource is created conditionally through an enabled variable
+func (t *Tf) toggleable(resource tmcgParsing.Resource) bool {
+	return resource.Mode == "single" && t.opts.Toggleable[resource.Name]
+}
 
-			dynamicBody.SetAttributeRaw("for_each", hclwrite.TokensForIdentifier(fmt.Sprintf("can(coalesce(%s%s)) ? flatten([%s%s]) : []", prefix, itemName, prefix, itemName)))
+// toggledOnNull reports whether a single-mode resource is created only while its object variable is not null
+func (t *Tf) toggledOnNull(resource tmcgParsing.Resource) bool {
+	return t.opts.ToggleOnNull && resource.Mode == "single" && t.singleRefStyle() == tmcgParsing.SingleRefObject
+}
 
-			contentBlock := hclwrite.NewBlock("content", nil)
-			contentBody := contentBlock.Body()
-			t.handleAttributesAndNestedBlocks(contentBody, blockSchema.Block.Attributes, blockSchema.Block.NestedBlocks, fmt.Sprintf("%s.value", itemName))
+// commonTagsVariable is the variable AutoTags merges into the tag attribute of every resource
+const commonTagsVariable = "common_tags"
 
-			dynamicBody.AppendBlock(contentBlock)
-			resourceAttrs.AppendBlock(dynamicBlock)
-			resourceBlock.Body().AppendNewline()
+// defaultTagAttributes are the tag attribute names AutoTags looks for without TagAttributes
+var defaultTagAttributes = []string{"tags", "labels"}
 
-			t.logger.Log("debug", "Added dynamic block for nested block: %s", itemName)
+// tagAttribute returns the attribute of a resource block that AutoTags merges the common tags into: the
+// first candidate name declared as a map of strings, or empty when AutoTags is off or none matches
+func (t *Tf) tagAttribute(block *tfjson.SchemaBlock) string {
+	if !t.opts.AutoTags || block == nil {
+		return ""
+	}
+	candidates := t.opts.TagAttributes
+	if len(candidates) == 0 {
+		candidates = defaultTagAttributes
+	}
+	for _, name := range candidates {
+		if attrSchema, exists := block.Attributes[name]; exists && attrSchema != nil && attrSchema.AttributeType.Equals(cty.Map(cty.String)) {
+			return name
 		}
+	}
+	return ""
+}
 
-		// Add a newline after each resource block
-		file.Body().AppendNewline()
+// enabledVariableName returns the name of the boolean variable toggling a resource, e.g. aws_instance_enabled
+func (t *Tf) enabledVariableName(resource tmcgParsing.Resource) string {
+	return t.resourceVariablePrefix(resource) + "_enabled"
+}
+
+// trimProviderPrefix returns the resource name without its provider prefix (aws_instance becomes instance)
+func trimProviderPrefix(resource tmcgParsing.Resource) string {
+	if name, found := strings.CutPrefix(resource.Name, resource.TypePrefix()+"_"); found {
+		return name
 	}
+	return resource.Name
+}
 
-	// Write the generated file to disk
-	filePath := filepath.Join(dir, "main.tf")
-	t.cleanupHCLFile(file)
-	t.logger.Log("info", "Writing main.tf to: %s", filePath)
-	err := writeFile(filePath, file.Bytes(), 0644)
-	if err != nil {
-		t.logger.Log("error", "Failed to write main.tf: %v", err)
-		return fmt.Errorf("failed to write main.tf to %s: %w", filePath, err)
+// sharedSingleTypes returns the resource types declared more than once in single mode,
+// whose labeled instances need label-prefixed bare variables to stay apart
+func sharedSingleTypes(resources []tmcgParsing.Resource) map[string]bool {
+	counts := make(map[string]int)
+	for _, resource := range resources {
+		if resource.Mode == "single" {
+			counts[resource.Name]++
+		}
 	}
 
-	t.logger.Log("info", "Successfully generated main.tf in directory: %s", dir)
-	return nil
+	shared := make(map[string]bool)
+	for name, count := range counts {
+		if count > 1 {
+			shared[name] = true
+		}
+	}
+	return shared
 }
 
-// handleAttributesAndNestedBlocks is a recursive function to handle attributes and nested blocks
-func (t *Tf) handleAttributesAndNestedBlocks(resourceAttrs *hclwrite.Body, attributes map[string]*tfjson.SchemaAttribute, nestedBlocks map[string]*tfjson.SchemaBlockType, prefix string) {
-	// Collect attributes and nested blocks into a combined map
-	items := make(map[string]interface{}, len(attributes)+len(nestedBlocks))
-	for name, attrSchema := range attributes {
-		items[name] = attrSchema
+// forEachKeyComment returns the note above a multiple-mode list variable on how its objects are keyed in
+// for_each; map variables are keyed by their own unique keys and get none
+func (t *Tf) forEachKeyComment(resource tmcgParsing.Resource) string {
+	if t.mapVariable(resource) {
+		return ""
 	}
-	for name, blockSchema := range nestedBlocks {
-		items[name] = blockSchema
+	if t.countIteration(resource) || t.opts.KeyMode == tmcgParsing.KeyModeIndex {
+		return "Each object is keyed by its position in the list; reordering the list replaces resources"
 	}
+	if t.opts.KeyMode == tmcgParsing.KeyModeCoalesce {
+		return fmt.Sprintf("The '%s' field of each object, or its position in the list when null, is used as the for_each key and must be unique", t.keyAttr(resource))
+	}
+	return fmt.Sprintf("The '%s' field of each object is used as the for_each key and must be unique", t.keyAttr(resource))
+}
 
-	// Get a sorted list of item names
-	itemNames := make([]string, 0, len(items))
-	for name := range items {
-		itemNames = append(itemNames, name)
+// ambiguousTypes returns the resource types exposed by more than one of the provider schemas, such as
+// google_compute_instance with both google and google-beta in use
+func ambiguousTypes(cleanedSchema map[string]*tfjson.ProviderSchema) map[string]bool {
+	counts := make(map[string]int)
+	for _, providerSchema := range cleanedSchema {
+		if providerSchema == nil {
+			continue
+		}
+		for name := range providerSchema.ResourceSchemas {
+			counts[name]++
+		}
 	}
-	sort.Strings(itemNames)
 
-	// Process each item, maintaining the sorted order of attributes and nested blocks
-	for _, itemName := range itemNames {
-		if _, ok := items[itemName].(*tfjson.SchemaAttribute); ok {
-			// Handle attribute
-			resourceAttrs.SetAttributeRaw(itemName, hclwrite.TokensForIdentifier(fmt.Sprintf("%s.%s", prefix, itemName)))
-			t.logger.Log("debug", "Added attribute: %s.%s", prefix, itemName)
-		} else if blockSchema, ok := items[itemName].(*tfjson.SchemaBlockType); ok && blockSchema != nil {
-			// Handle nested block
-			t.logger.Log("debug", "Processing nested block: %s", itemName)
-			resourceAttrs.AppendNewline()
-			dynamicBlock := hclwrite.NewBlock("dynamic", []string{itemName})
-			dynamicBody := dynamicBlock.Body()
-			dynamicBody.SetAttributeRaw("for_each", hclwrite.TokensForIdentifier(fmt.Sprintf("can(coalesce(%s.%s)) ? flatten([%s.%s]) : []", prefix, itemName, prefix, itemName)))
+	ambiguous := make(map[string]bool)
+	for name, count := range counts {
+		if count > 1 {
+			ambiguous[name] = true
+		}
+	}
+	return ambiguous
+}
 
-			contentBlock := hclwrite.NewBlock("content", nil)
-			contentBody := contentBlock.Body()
-			t.handleAttributesAndNestedBlocks(contentBody, blockSchema.Block.Attributes, blockSchema.Block.NestedBlocks, fmt.Sprintf("%s.value", itemName))
+// singleReference returns the expression main.tf uses for a single-mode attribute or block
+func (t *Tf) singleReference(resource tmcgParsing.Resource, itemName string, isBlock bool) string {
+	if t.singleRefStyle() == tmcgParsing.SingleRefObject {
+		return fmt.Sprintf("var.%s.%s", t.resourceVariablePrefix(resource), itemName)
+	}
+	return "var." + t.singleVariableName(resource, itemName, isBlock)
+}
 
-			dynamicBody.AppendBlock(contentBlock)
-			resourceAttrs.AppendBlock(dynamicBlock)
-			resourceAttrs.AppendNewline()
-		}
+// resourceVariablePrefix returns the resource's alias, or its name plus its custom label if any, used to
+// namespace variables
+func (t *Tf) resourceVariablePrefix(resource tmcgParsing.Resource) string {
+	if alias, exists := t.opts.ResourceAliases[resource.Name]; exists {
+		return alias
+	}
+	if resource.Label != "" {
+		return resource.Name + "_" + resource.Label
 	}
+	return resource.Name
 }
 
-// deriveVariableName removes the provider prefix and pluralizes the resource name
-func (t *Tf) deriveVariableName(resource string) string {
-	parts := strings.SplitN(resource, "_", 2)
-	if len(parts) > 1 {
-		resourceName := parts[1] // Get the part after provider name
-		pluralizer := pluralize.NewClient()
-		return pluralizer.Plural(resourceName)
+// resourceDisplayName returns how variable descriptions and comments refer to a resource: its type, or its
+// alias followed by its type when it has one
+func (t *Tf) resourceDisplayName(resource tmcgParsing.Resource) string {
+	if alias, exists := t.opts.ResourceAliases[resource.Name]; exists {
+		return fmt.Sprintf("%s (%s)", alias, resource.Name)
 	}
-	return resource
+	return resource.Name
 }
 
-// CreateVariablesTF generates the variables.tf file based on resource schemas
-func (t *Tf) CreateVariablesTF(dir string, cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, descAsCommentsFlag bool) error {
-	t.logger.Log("info", "Starting to generate variables.tf in directory: %s", dir)
+// localDefault returns the locals entry of an optional single-mode attribute when DefaultsLocal is set: the
+// configured default if any, otherwise the zero value of its type. Attributes without a zero value are skipped.
+func (t *Tf) localDefault(resource tmcgParsing.Resource, itemName string, attrSchema *tfjson.SchemaAttribute) (hclwrite.Tokens, bool) {
+	if !t.opts.DefaultsLocal || resource.Mode != "single" || !attrSchema.Optional {
+		return nil, false
+	}
+	if defaultValue, exists := t.opts.Defaults[resource.Name+"."+itemName]; exists {
+		return hclwrite.TokensForValue(defaultValue), true
+	}
 
-	// Validate inputs
-	if len(resources) == 0 {
-		t.logger.Log("warn", "No resources specified. Skipping variables.tf generation.")
-		return nil
+	attrTypeStr := t.attributeTypeFor(resource.Name+"."+itemName, attrSchema)
+	switch {
+	case attrTypeStr == "string":
+		return hclwrite.TokensForValue(cty.StringVal("")), true
+	case attrTypeStr == "number":
+		return hclwrite.TokensForValue(cty.Zero), true
+	case attrTypeStr == "bool":
+		return hclwrite.TokensForValue(cty.False), true
+	case strings.HasPrefix(attrTypeStr, "list("), strings.HasPrefix(attrTypeStr, "set("):
+		return hclwrite.TokensForIdentifier("[]"), true
+	case strings.HasPrefix(attrTypeStr, "map("):
+		return hclwrite.TokensForIdentifier("{}"), true
 	}
+	return nil, false
+}
 
-	// Create a new HCL file
-	file := hclwrite.NewEmptyFile()
-	rootBody := file.Body()
+// localDefaultReference returns the expression that falls back from a variable to its locals default.
+// Strings use a null check because coalesce rejects arguments that are all empty strings.
+func localDefaultReference(reference, localReference, attrTypeStr string) string {
+	if attrTypeStr == "string" {
+		return fmt.Sprintf("%s != null ? %s : %s", reference, reference, localReference)
+	}
+	return fmt.Sprintf("coalesce(%s, %s)", reference, localReference)
+}
 
-	for _, resource := range resources {
-		// Retrieve the schema for the resource
-		providerKey := fmt.Sprintf("registry.terraform.io/%s/%s", resource.Provider.NamespaceLower, resource.Provider.NameLower)
-		providerSchema, exists := cleanedSchema[providerKey]
-		if !exists {
-			t.logger.Log("warn", "No schema found for provider: %s", providerKey)
-			continue
-		}
+// emptyDefault returns the default for an optional variable of the given type: the literal configured
+// in NullDefaultTypes for primitive types, an empty collection for list, set and map types when
+// EmptyCollectionDefaults is enabled, and null otherwise
+func (t *Tf) emptyDefault(attrTypeStr string) string {
+	if literal, exists := t.opts.NullDefaultTypes[attrTypeStr]; exists {
+		return literal
+	}
+	if !t.opts.EmptyCollectionDefaults {
+		return "null"
+	}
+	switch {
+	case strings.HasPrefix(attrTypeStr, "list("), strings.HasPrefix(attrTypeStr, "set("):
+		return "[]"
+	case strings.HasPrefix(attrTypeStr, "map("):
+		return "{}"
+	}
+	return "null"
+}
 
-		resourceSchema, exists := providerSchema.ResourceSchemas[resource.Name]
-		if !exists {
-			t.logger.Log("warn", "No schema found for resource: %s with provider: %s/%s", resource.Name, resource.Provider.Namespace, resource.Provider.Name)
-			continue
-		}
+// heredocDefault reports whether a default is a string that HeredocThreshold turns into a heredoc,
+// being longer than the threshold or spanning several lines
+func (t *Tf) heredocDefault(value cty.Value) bool {
+	if t.opts.HeredocThreshold <= 0 || !value.Type().Equals(cty.String) || value.IsNull() || !value.IsKnown() {
+		return false
+	}
+	text := value.AsString()
+	return len(text) > t.opts.HeredocThreshold || strings.Contains(text, "\n")
+}
 
-		// Derive the variable name
-		variableName := t.deriveVariableName(resource.Name)
+// heredocTokens renders text as an indented heredoc, escaping template sequences and avoiding marker clashes.
+// Surrounding blank lines and trailing whitespace are dropped, which suits descriptions.
+func heredocTokens(text string) hclwrite.Tokens {
+	lines := strings.Split(strings.Trim(text, "\n"), "\n")
+	for i, line := range lines {
+		lines[i] = strings.TrimRight(line, " \t")
+	}
+	return heredocLines(lines)
+}
 
-		if resource.Mode == "multiple" {
-			// Handle multiple mode
-			variableBlock := rootBody.AppendNewBlock("variable", []string{variableName})
-			variableBody := variableBlock.Body()
-			variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier("list(object({"))
+// heredocValueTokens renders a string value as an indented heredoc that keeps its content, apart from
+// the newline a heredoc always ends with
+func heredocValueTokens(text string) hclwrite.Tokens {
+	return heredocLines(strings.Split(strings.TrimSuffix(text, "\n"), "\n"))
+}
 
-			// Process attributes and nested blocks
-			t.handleAttributesAndNestedBlocksForVariable(variableBody, resourceSchema.Block.Attributes, resourceSchema.Block.NestedBlocks, 1, true, descAsCommentsFlag)
+// heredocLines renders lines as the body of an indented heredoc
+func heredocLines(lines []string) hclwrite.Tokens {
+	// Pick a closing marker that does not appear as a line of its own
+	marker := "EOT"
+	for clash := true; clash; {
+		clash = false
+		for _, line := range lines {
+			if strings.TrimSpace(line) == marker {
+				marker += "_"
+				clash = true
+				break
+			}
+		}
+	}
 
-			// Close the variable type definition
-			variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
-				{Type: hclsyntax.TokenIdent, Bytes: []byte("}))")},
-				{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
-			})
+	var content strings.Builder
+	for _, line := range lines {
+		line = strings.ReplaceAll(line, "${", "$${")
+		line = strings.ReplaceAll(line, "%{", "%%{")
+		if line != "" {
+			line = "    " + line
+		}
+		content.WriteString(line + "\n")
+	}
 
-			variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("null"))
-			rootBody.AppendNewline()
-		} else {
-			// Handle single mode
-			totalItems := make([]string, 0, len(resourceSchema.Block.Attributes)+len(resourceSchema.Block.NestedBlocks))
-			for name := range resourceSchema.Block.Attributes {
-				totalItems = append(totalItems, name)
-			}
-			for name := range resourceSchema.Block.NestedBlocks {
-				totalItems = append(totalItems, name)
-			}
-			sort.Strings(totalItems)
-
-			for _, itemName := range totalItems {
-				// Check if it's an attribute
-				if attrSchema, ok := resourceSchema.Block.Attributes[itemName]; ok {
-					if attrSchema == nil {
-						t.logger.Log("debug", "Skipping attribute: %s", itemName)
-						continue
-					}
+	return hclwrite.Tokens{
+		{Type: hclsyntax.TokenOHeredoc, Bytes: []byte("<<-" + marker + "\n")},
+		{Type: hclsyntax.TokenStringLit, Bytes: []byte(content.String())},
+		{Type: hclsyntax.TokenCHeredoc, Bytes: []byte("  " + marker)},
+	}
+}
 
-					variableBlock := rootBody.AppendNewBlock("variable", []string{itemName})
-					variableBody := variableBlock.Body()
+// warnUnknownDefaults warns about configured defaults that cannot be applied to an optional single-mode attribute
+func (t *Tf) warnUnknownDefaults(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) {
+	if len(t.opts.Defaults) == 0 {
+		return
+	}
 
-					// Set description
+	keys := make([]string, 0, len(t.opts.Defaults))
+	for key := range t.opts.Defaults {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-					if description := strings.ReplaceAll(attrSchema.Description, "\n", " "); description != "" {
-						variableBody.SetAttributeValue("description", cty.StringVal(description))
-					}
+	for _, key := range keys {
+		attrSchema, resource, exists := t.findAttribute(cleanedSchema, resources, key)
+		if !exists || resource.Mode != "single" || !attrSchema.Optional || strings.Count(key, ".") != 1 {
+			t.logger.Log("warn", "Default for %s does not match an optional attribute of a single-mode resource and is ignored", key)
+		}
+	}
+}
 
-					// Set type and default
-					attrTypeStr := t.getAttributeType(attrSchema.AttributeType)
-					variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier(attrTypeStr))
-					if attrSchema.Optional {
-						variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("null"))
-					}
-					rootBody.AppendNewline()
-					continue
-				}
+// warnUnknownTypeOverrides warns about type overrides whose attribute path does not exist
+func (t *Tf) warnUnknownTypeOverrides(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) {
+	keys := make([]string, 0, len(t.opts.TypeOverrides))
+	for key := range t.opts.TypeOverrides {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-				// Handle nested blocks
-				block := resourceSchema.Block.NestedBlocks[itemName]
-				if block == nil || block.Block == nil {
-					t.logger.Log("warn", "Skipping invalid nested block: %s", itemName)
-					continue
-				}
+	for _, key := range keys {
+		if _, _, exists := t.findAttribute(cleanedSchema, resources, key); !exists {
+			t.logger.Log("warn", "Type override for %s does not match any attribute and is ignored", key)
+		}
+	}
+}
 
-				variableBlock := rootBody.AppendNewBlock("variable", []string{itemName})
-				variableBody := variableBlock.Body()
+// warnUnknownDescriptions warns about custom descriptions whose attribute path does not exist
+func (t *Tf) warnUnknownDescriptions(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource) {
+	keys := make([]string, 0, len(t.opts.Descriptions))
+	for key := range t.opts.Descriptions {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
 
-				// Determine block type
-				typeStr := "object({"
-				if block.MaxItems != 1 {
-					typeStr = "list(object({"
-				}
-				variableBody.SetAttributeRaw("type", hclwrite.TokensForIdentifier(typeStr))
+	for _, key := range keys {
+		if _, _, exists := t.findAttribute(cleanedSchema, resources, key); !exists {
+			t.logger.Log("warn", "Description for %s does not match any attribute and is ignored", key)
+		}
+	}
+}
 
-				// Process nested attributes and blocks
-				t.handleAttributesAndNestedBlocksForVariable(variableBody, block.Block.Attributes, block.Block.NestedBlocks, 1, true, descAsCommentsFlag)
+// descriptionFor returns the custom description of an attribute path if one is configured, else the schema's
+func (t *Tf) descriptionFor(path string, attrSchema *tfjson.SchemaAttribute) string {
+	if description, exists := t.opts.Descriptions[path]; exists {
+		return description
+	}
+	return attrSchema.Description
+}
 
-				// Close block
-				closingString := "})"
-				if block.MaxItems != 1 {
-					closingString = "}))"
-				}
-				variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
-					{Type: hclsyntax.TokenIdent, Bytes: []byte(closingString)},
-					{Type: hclsyntax.TokenNewline, Bytes: []byte("\n")},
-				})
-				rootBody.AppendNewline()
+// findAttribute resolves a "resource.attribute" or "resource.block.attribute" path against the resources' schemas
+func (t *Tf) findAttribute(cleanedSchema map[string]*tfjson.ProviderSchema, resources []tmcgParsing.Resource, path string) (*tfjson.SchemaAttribute, tmcgParsing.Resource, bool) {
+	for _, resource := range resources {
+		if !strings.HasPrefix(path, resource.Name+".") {
+			continue
+		}
+		for _, providerSchema := range cleanedSchema {
+			resourceSchema, exists := providerSchema.ResourceSchemas[resource.Name]
+			if !exists || resourceSchema == nil {
+				continue
+			}
 
-				// Set default for optional blocks
-				if block.MinItems == 0 {
-					variableBody.SetAttributeRaw("default", hclwrite.TokensForIdentifier("null"))
+			// Walk nested blocks down to the attribute
+			block := resourceSchema.Block
+			parts := strings.Split(strings.TrimPrefix(path, resource.Name+"."), ".")
+			for _, blockName := range parts[:len(parts)-1] {
+				if block == nil || block.NestedBlocks[blockName] == nil {
+					block = nil
+					break
 				}
+				block = block.NestedBlocks[blockName].Block
+			}
+			if block == nil {
+				continue
+			}
+			if attrSchema, exists := block.Attributes[parts[len(parts)-1]]; exists && attrSchema != nil {
+				return attrSchema, resource, true
 			}
 		}
 	}
+	return nil, tmcgParsing.Resource{}, false
+}
 
-	// Write to disk
-	filePath := filepath.Join(dir, "variables.tf")
-	t.cleanupHCLFile(file)
-	t.logger.Log("info", "Writing variables.tf to: %s", filePath)
-	err := writeFile(filePath, file.Bytes(), 0644)
-
-	if err != nil {
-		t.logger.Log("error", "Failed to write variables.tf: %v", err)
-		return fmt.Errorf("failed to write variables.tf to %s: %w", filePath, err)
+// attributeTypeFor returns the variable type of an attribute, honoring any configured type override
+func (t *Tf) attributeTypeFor(path string, attrSchema *tfjson.SchemaAttribute) string {
+	if override, exists := t.opts.TypeOverrides[path]; exists {
+		t.logger.Log("debug", "Using type override for %s: %s", path, override)
+		return override
 	}
-
-	t.logger.Log("info", "Successfully generated variables.tf in directory: %s", dir)
-	return nil
+	if !representableType(attrSchema.AttributeType) {
+		t.logger.Log("warn", "Type of %s cannot be fully represented as a type constraint; using any", path)
+	}
+	return t.getAttributeType(attrSchema.AttributeType)
 }
 
 // handleAttributesAndNestedBlocksForVariable is a recursive function to handle attributes and nested blocks for variable definitions
-func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.Body, attributes map[string]*tfjson.SchemaAttribute, nestedBlocks map[string]*tfjson.SchemaBlockType, indentLevel int, isNested bool, descAsCommentsFlag bool) {
+func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.Body, attributes map[string]*tfjson.SchemaAttribute, nestedBlocks map[string]*tfjson.SchemaBlockType, path string, indentLevel int, isNested bool, descAsCommentsFlag bool) {
 	indent := strings.Repeat("  ", indentLevel)
 
 	type schemaItem struct {
@@ -418,11 +2430,11 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 			attrSchema := attributes[attrName]
 
 			// Resolve attribute type
-			attrTypeStr := t.getAttributeType(attrSchema.AttributeType)
+			attrTypeStr := t.attributeTypeFor(path+"."+attrName, attrSchema)
 
 			// Add description comment if available
-			if attrSchema.Description != "" && descAsCommentsFlag {
-				escapedDescription := strings.ReplaceAll(attrSchema.Description, `"`, `\"`)
+			if attrDescription := t.descriptionFor(path+"."+attrName, attrSchema); attrDescription != "" && descAsCommentsFlag {
+				escapedDescription := strings.ReplaceAll(attrDescription, `"`, `\"`)
 				singleLineDescription := strings.ReplaceAll(escapedDescription, "\n", " ")
 				variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
 					{Type: hclsyntax.TokenComment, Bytes: []byte(fmt.Sprintf("%s// %s", indent, singleLineDescription))},
@@ -437,6 +2449,12 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 			if !attrSchema.Required && isNested {
 				optionalPrefix = "optional("
 				optionalSuffix = ")"
+
+				// Primitives take the configured default as optional()'s second argument; object and
+				// collection types are never listed in NullDefaultTypes, so they stay without one
+				if literal, exists := t.opts.NullDefaultTypes[attrTypeStr]; exists && literal != "null" {
+					optionalSuffix = ", " + literal + ")"
+				}
 			}
 
 			// Format the attribute
@@ -453,6 +2471,10 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 			variableBody.AppendNewline()
 			blockName := item.Name
 			blockSchema := nestedBlocks[blockName]
+			if blockSchema == nil || blockSchema.Block == nil {
+				t.skip("Skipping invalid nested block: %s", path+"."+blockName)
+				continue
+			}
 
 			// Determine block type
 			var blockTypeStr string
@@ -464,14 +2486,18 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 			case "set":
 				blockTypeStr = "set(object({"
 			default:
-				t.logger.Log("warn", "Unknown nesting_mode for block %s. Skipping.", blockName)
+				t.skip("Unknown nesting_mode for block %s. Skipping.", path+"."+blockName)
 				continue
 			}
+			inconsistent := t.inconsistentItems(blockSchema, path+"."+blockName)
+			if inconsistent {
+				blockTypeStr = "list(object({"
+			}
 
 			t.logger.Log("debug", "Processing nested block: %s", blockName)
 
 			// Add block type and optionality
-			isOptional := blockSchema.MinItems == 0
+			isOptional := blockSchema.MinItems == 0 || inconsistent
 			if isOptional {
 				variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
 					{Type: hclsyntax.TokenIdent, Bytes: []byte(fmt.Sprintf("%s%s = optional(%s", indent, blockName, blockTypeStr))},
@@ -485,7 +2511,7 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 			}
 
 			// Add description comment if available
-			if blockSchema.Block.Description != "" && descAsCommentsFlag {
+			if blockSchema.Block.Description != "" && (descAsCommentsFlag || t.opts.BlockDescComments) {
 				escapedDescription := strings.ReplaceAll(blockSchema.Block.Description, `"`, `\"`)
 				singleLineDescription := strings.ReplaceAll(escapedDescription, "\n", " ")
 				variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
@@ -499,6 +2525,7 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 				variableBody,
 				blockSchema.Block.Attributes,
 				blockSchema.Block.NestedBlocks,
+				path+"."+blockName,
 				indentLevel+1,
 				true,
 				descAsCommentsFlag,
@@ -506,13 +2533,18 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 
 			// Close block type
 			closeStr := fmt.Sprintf("%s}))", indent)
-			if blockSchema.NestingMode == "single" {
+			if blockSchema.NestingMode == "single" && !inconsistent {
 				closeStr = fmt.Sprintf("%s})", indent)
 			}
 
-			// Add closing parentheses for optional blocks
+			// Add closing parentheses for optional blocks, defaulting repeated blocks to an empty
+			// collection with EmptyCollectionDefaults
 			if isOptional {
-				closeStr += ")"
+				if t.opts.EmptyCollectionDefaults && (blockSchema.NestingMode != "single" || inconsistent) {
+					closeStr += ", [])"
+				} else {
+					closeStr += ")"
+				}
 			}
 
 			variableBody.AppendUnstructuredTokens(hclwrite.Tokens{
@@ -524,29 +2556,83 @@ func (t *Tf) handleAttributesAndNestedBlocksForVariable(variableBody *hclwrite.B
 	}
 }
 
-// getAttributeType returns the Terraform type string representation for a given cty.Type
+// getAttributeType returns the Terraform type string representation for a given cty.Type, falling back
+// to any for the whole type when part of it cannot be represented, so no partial type is emitted
 func (t *Tf) getAttributeType(attrType cty.Type) string {
+	if !representableType(attrType) {
+		return "any"
+	}
+	return t.renderAttributeType(attrType, 0)
+}
+
+// representableType reports whether every part of a cty.Type can be written as a type constraint,
+// which rules out capsule types and object attributes whose names are not valid identifiers
+func representableType(attrType cty.Type) bool {
+	switch {
+	case attrType.Equals(cty.DynamicPseudoType), attrType.IsPrimitiveType():
+		return true
+	case attrType.IsListType(), attrType.IsSetType(), attrType.IsMapType():
+		return representableType(attrType.ElementType())
+	case attrType.IsObjectType():
+		for key, attributeType := range attrType.AttributeTypes() {
+			if !hclsyntax.ValidIdentifier(key) || !representableType(attributeType) {
+				return false
+			}
+		}
+		return true
+	case attrType.IsTupleType():
+		for _, elementType := range attrType.TupleElementTypes() {
+			if !representableType(elementType) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
+
+// renderAttributeType renders a cty.Type, indenting object attributes relative to the given depth
+func (t *Tf) renderAttributeType(attrType cty.Type, depth int) string {
 	switch {
+	case attrType.Equals(cty.DynamicPseudoType):
+		// Dynamic attributes such as azapi's body accept any value and are passed through unchanged
+		return "any"
 	case attrType.IsPrimitiveType():
 		return attrType.FriendlyName()
 	case attrType.IsListType():
-		elementType := t.getAttributeType(attrType.ElementType())
+		elementType := t.renderAttributeType(attrType.ElementType(), depth)
 		return fmt.Sprintf("list(%s)", elementType)
 	case attrType.IsSetType():
-		elementType := t.getAttributeType(attrType.ElementType())
+		elementType := t.renderAttributeType(attrType.ElementType(), depth)
 		return fmt.Sprintf("set(%s)", elementType)
 	case attrType.IsMapType():
-		mapElementType := t.getAttributeType(*attrType.MapElementType())
+		mapElementType := t.renderAttributeType(*attrType.MapElementType(), depth)
 		return fmt.Sprintf("map(%s)", mapElementType)
 	case attrType.IsObjectType():
+		// Sort the keys so the rendered type is deterministic
+		attributeTypes := attrType.AttributeTypes()
+		keys := make([]string, 0, len(attributeTypes))
+		for key := range attributeTypes {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		indent := strings.Repeat("  ", depth)
 		var builder strings.Builder
 		builder.WriteString("object({\n")
-		for key, val := range attrType.AttributeTypes() {
-			attributeType := t.getAttributeType(val)
-			builder.WriteString(fmt.Sprintf("  %s = %s\n", key, attributeType))
+		for _, key := range keys {
+			attributeType := t.renderAttributeType(attributeTypes[key], depth+1)
+			builder.WriteString(fmt.Sprintf("%s  %s = %s\n", indent, key, attributeType))
 		}
-		builder.WriteString("})")
+		builder.WriteString(indent + "})")
 		return builder.String()
+	case attrType.IsTupleType():
+		elementTypes := []string{}
+		for _, elementType := range attrType.TupleElementTypes() {
+			elementTypes = append(elementTypes, t.renderAttributeType(elementType, depth))
+		}
+		return fmt.Sprintf("tuple([%s])", strings.Join(elementTypes, ", "))
 	default:
 		return "any"
 	}
@@ -5,10 +5,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 
 	tmcgParsing "tmcg/internal/tmcg/parsing"
 
+	"github.com/hashicorp/hcl/v2"
+	"github.com/hashicorp/hcl/v2/ext/typeexpr"
+	"github.com/hashicorp/hcl/v2/hclsyntax"
 	"github.com/hashicorp/terraform-exec/tfexec"
 	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/stretchr/testify/assert"
@@ -470,3 +475,1026 @@ func TestCreateVariablesTF(t *testing.T) {
 		})
 	}
 }
+
+// TestGetAttributeType tests rendering of nested collection and object types.
+func TestGetAttributeType(t *testing.T) {
+	tests := []struct {
+		name     string
+		attrType cty.Type
+		expected string
+	}{
+		{"primitive", cty.String, "string"},
+		{"list of numbers", cty.List(cty.Number), "list(number)"},
+		{
+			name:     "map of object",
+			attrType: cty.Map(cty.Object(map[string]cty.Type{"b": cty.Number, "a": cty.String})),
+			expected: "map(object({\n  a = string\n  b = number\n}))",
+		},
+		{
+			name: "map of object with nested map of object",
+			attrType: cty.Map(cty.Object(map[string]cty.Type{
+				"inner": cty.Map(cty.Object(map[string]cty.Type{"y": cty.Bool, "x": cty.String})),
+				"id":    cty.String,
+			})),
+			expected: "map(object({\n  id = string\n  inner = map(object({\n    x = string\n    y = bool\n  }))\n}))",
+		},
+		{"tuple", cty.Tuple([]cty.Type{cty.String, cty.List(cty.Number)}), "tuple([string, list(number)])"},
+		{
+			name:     "capsule nested in object",
+			attrType: cty.List(cty.Object(map[string]cty.Type{"a": cty.String, "b": cty.Capsule("handle", reflect.TypeOf(0))})),
+			expected: "any",
+		},
+		{
+			name:     "object with invalid attribute name",
+			attrType: cty.Map(cty.Object(map[string]cty.Type{"a": cty.String, "1st": cty.String})),
+			expected: "any",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.expected, testTerraform.getAttributeType(tt.attrType))
+		})
+	}
+}
+
+// TestCreateVariablesTFDefaults tests applying a configured default to one of several optional attributes.
+func TestCreateVariablesTFDefaults(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":           {AttributeType: cty.String, Required: true},
+					"instance_type": {AttributeType: cty.String, Optional: true},
+					"monitoring":    {AttributeType: cty.Bool, Optional: true},
+				}}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+
+	mockLogger := &MockLogger{}
+	tf := NewTfWithOptions(mockLogger, Options{Defaults: map[string]cty.Value{
+		"aws_instance.instance_type": cty.StringVal("t3.micro"),
+		"aws_instance.unknown":       cty.StringVal("ignored"),
+	}})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "variable \"instance_type\" {\n  type    = string\n  default = \"t3.micro\"\n}")
+	assert.Contains(t, content, "variable \"monitoring\" {\n  type    = bool\n  default = null\n}")
+	assert.Contains(t, content, "variable \"ami\" {\n  type = string\n}")
+	assert.Contains(t, mockLogger.Messages, "[warn] Default for aws_instance.unknown does not match an optional attribute of a single-mode resource and is ignored")
+}
+
+// TestCreateVariablesTFTypeOverrides tests overriding the type of a single attribute.
+func TestCreateVariablesTFTypeOverrides(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":  {AttributeType: cty.String, Required: true},
+					"tags": {AttributeType: cty.Map(cty.String), Optional: true},
+				}}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+
+	mockLogger := &MockLogger{}
+	tf := NewTfWithOptions(mockLogger, Options{TypeOverrides: map[string]string{
+		"aws_instance.tags":    "map(any)",
+		"aws_instance.missing": "string",
+	}})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "variable \"tags\" {\n  type    = map(any)\n  default = null\n}")
+	assert.Contains(t, content, "variable \"ami\" {\n  type = string\n}")
+	assert.Contains(t, mockLogger.Messages, "[warn] Type override for aws_instance.missing does not match any attribute and is ignored")
+}
+
+// TestCreateVariablesTFMultilineDescriptions tests writing multi-line descriptions as heredocs.
+func TestCreateVariablesTFMultilineDescriptions(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"user_data": {
+						AttributeType: cty.String,
+						Optional:      true,
+						Description:   "User data to provide.\n\n- Supports `${var}` references\n  - nested item\nEOT",
+					},
+					"ami": {AttributeType: cty.String, Required: true, Description: "AMI to use."},
+				}}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{MultilineDescriptions: true})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "  description = <<-EOT_\n    User data to provide.\n\n    - Supports `$${var}` references\n      - nested item\n    EOT\n  EOT_\n")
+	assert.Contains(t, content, "description = \"AMI to use.\"")
+
+	// The heredoc must parse back to the original description
+	file, diags := hclsyntax.ParseConfig([]byte(content), "variables.tf", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Labels[0] == "user_data" {
+			value, diags := block.Body.Attributes["description"].Expr.Value(nil)
+			require.False(t, diags.HasErrors(), diags.Error())
+			assert.Equal(t, "User data to provide.\n\n- Supports `${var}` references\n  - nested item\nEOT\n", value.AsString())
+		}
+	}
+}
+
+// TestCreateVariablesTFHeredocDefaults tests that string defaults above the heredoc threshold or spanning
+// several lines become heredocs that parse back to the original value, while short ones stay quoted.
+func TestCreateVariablesTFHeredocDefaults(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"instance_type": {AttributeType: cty.String, Optional: true},
+					"user_data":     {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	userData := "#!/bin/bash\necho \"${HOSTNAME}\"\n\n  indented\nEOT\n"
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{
+		HeredocThreshold: 20,
+		Defaults: map[string]cty.Value{
+			"aws_instance.instance_type": cty.StringVal("t3.micro"),
+			"aws_instance.user_data":     cty.StringVal(userData),
+		},
+	})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, `default = "t3.micro"`)
+	assert.Contains(t, content, "  default = <<-EOT_\n    #!/bin/bash\n    echo \"$${HOSTNAME}\"\n\n      indented\n    EOT\n  EOT_\n")
+
+	// The heredoc must parse back to the original value
+	file, diags := hclsyntax.ParseConfig([]byte(content), "variables.tf", hcl.InitialPos)
+	require.False(t, diags.HasErrors(), diags.Error())
+	for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+		if block.Labels[0] == "user_data" {
+			value, diags := block.Body.Attributes["default"].Expr.Value(nil)
+			require.False(t, diags.HasErrors(), diags.Error())
+			assert.Equal(t, userData, value.AsString())
+		}
+	}
+}
+
+// TestCreateVariablesTFEmptyCollectionDefaults tests empty collection defaults for optional single-mode attributes.
+func TestCreateVariablesTFEmptyCollectionDefaults(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"security_groups": {AttributeType: cty.List(cty.String), Optional: true},
+					"ipv6_addresses":  {AttributeType: cty.Set(cty.String), Optional: true},
+					"tags":            {AttributeType: cty.Map(cty.String), Optional: true},
+					"instance_type":   {AttributeType: cty.String, Optional: true},
+					"subnet_ids":      {AttributeType: cty.List(cty.String), Required: true},
+				}}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{EmptyCollectionDefaults: true})
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "variable \"security_groups\" {\n  type    = list(string)\n  default = []\n}")
+	assert.Contains(t, content, "variable \"ipv6_addresses\" {\n  type    = set(string)\n  default = []\n}")
+	assert.Contains(t, content, "variable \"tags\" {\n  type    = map(string)\n  default = {}\n}")
+	assert.Contains(t, content, "variable \"instance_type\" {\n  type    = string\n  default = null\n}")
+	assert.Contains(t, content, "variable \"subnet_ids\" {\n  type = list(string)\n}")
+}
+
+// TestCreateVariablesTFDynamicAttribute tests that an azapi-like dynamic body is typed as any and passed through.
+func TestCreateVariablesTFDynamicAttribute(t *testing.T) {
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/azure/azapi": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"azapi_resource": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"type": {AttributeType: cty.String, Required: true},
+					"body": {AttributeType: cty.DynamicPseudoType, Optional: true},
+				}}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{{
+		Name:     "azapi_resource",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "Azure", Name: "azapi", NamespaceLower: "azure", NameLower: "azapi"},
+	}}
+
+	tf := NewTf(&MockLogger{})
+	assert.Equal(t, "any", tf.getAttributeType(cty.DynamicPseudoType))
+	assert.Equal(t, "map(any)", tf.getAttributeType(cty.Map(cty.DynamicPseudoType)))
+
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "variable \"body\" {\n  # Dynamic attribute: accepts any value (e.g., an object for azapi's body) and is passed through unchanged\n  type    = any\n  default = null\n}")
+	assert.Contains(t, readFormatted(t, filepath.Join(dir, "main.tf")), "body = var.body")
+}
+
+// TestCreateVariablesTFAttributeOrder compares single-mode variable blocks against a golden file
+// to pin the order of their settings: description, type, default. Sensitive attributes are not marked.
+func TestCreateVariablesTFAttributeOrder(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_db_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_db_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"engine":   {AttributeType: cty.String, Required: true, Description: "The database engine."},
+					"password": {AttributeType: cty.String, Optional: true, Sensitive: true, Description: "The master password."},
+					"tags":     {AttributeType: cty.Map(cty.String), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, NewTf(&MockLogger{}).CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	golden, err := os.ReadFile(filepath.Join("testdata", "variable_order.golden"))
+	require.NoError(t, err)
+	assert.Equal(t, strings.TrimSpace(string(golden)), strings.TrimSpace(readFormatted(t, filepath.Join(dir, "variables.tf"))))
+}
+
+// TestOrderVariableSettings tests that the settings of variable blocks are rewritten in a fixed order,
+// keeping the comments above them, and that blocks with stray content are left alone.
+func TestOrderVariableSettings(t *testing.T) {
+	content := `variable "subnets" {
+  validation {
+    condition     = length(var.subnets) > 0
+    error_message = "At least one subnet is required."
+  }
+  nullable = false
+  default  = []
+  # Subnets of the VPC
+  type = list(object({
+    cidr = string
+  }))
+  description = "The subnets."
+}
+
+resource "null_resource" "this" {
+  triggers = {}
+  count    = 1
+}
+
+variable "stray" {
+  default = null
+  type    = string
+  # Left over
+}
+`
+	ordered := string(testTerraform.orderVariableSettings([]byte(content)))
+	assert.Equal(t, `variable "subnets" {
+  description = "The subnets."
+  # Subnets of the VPC
+  type = list(object({
+    cidr = string
+  }))
+  default  = []
+  nullable = false
+  validation {
+    condition     = length(var.subnets) > 0
+    error_message = "At least one subnet is required."
+  }
+}
+
+resource "null_resource" "this" {
+  triggers = {}
+  count    = 1
+}
+
+variable "stray" {
+  default = null
+  type    = string
+  # Left over
+}
+`, ordered)
+}
+
+// TestCreateVariablesTFNullDefaultTypes tests per-type defaults for optional single-mode attributes.
+func TestCreateVariablesTFNullDefaultTypes(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"instance_type":  {AttributeType: cty.String, Optional: true},
+					"cpu_core_count": {AttributeType: cty.Number, Optional: true},
+					"monitoring":     {AttributeType: cty.Bool, Optional: true},
+					"tags":           {AttributeType: cty.Map(cty.String), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{NullDefaultTypes: map[string]string{"string": `""`, "number": "0"}})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "variable \"instance_type\" {\n  type    = string\n  default = \"\"\n}")
+	assert.Contains(t, content, "variable \"cpu_core_count\" {\n  type    = number\n  default = 0\n}")
+	assert.Contains(t, content, "variable \"monitoring\" {\n  type    = bool\n  default = null\n}")
+	assert.Contains(t, content, "variable \"tags\" {\n  type    = map(string)\n  default = null\n}")
+}
+
+// TestCreateVariablesTFOptionalDefaults tests that nested optional primitives take a default as optional()'s
+// second argument while nested objects do not.
+func TestCreateVariablesTFOptionalDefaults(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "aws_instance",
+		Mode:     "multiple",
+		Provider: tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"name": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"root_block_device": {NestingMode: tfjson.SchemaNestingModeSingle, Block: &tfjson.SchemaBlock{
+							Attributes: map[string]*tfjson.SchemaAttribute{
+								"volume_type": {AttributeType: cty.String, Optional: true},
+								"volume_size": {AttributeType: cty.Number, Optional: true},
+								"encrypted":   {AttributeType: cty.Bool, Optional: true},
+								"throughput":  {AttributeType: cty.Object(map[string]cty.Type{"mbps": cty.Number}), Optional: true},
+							},
+							NestedBlocks: map[string]*tfjson.SchemaBlockType{
+								"snapshot": {NestingMode: tfjson.SchemaNestingModeSingle, Block: &tfjson.SchemaBlock{
+									Attributes: map[string]*tfjson.SchemaAttribute{
+										"id": {AttributeType: cty.String, Optional: true},
+									},
+								}},
+							},
+						}},
+					},
+				}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{NullDefaultTypes: map[string]string{"string": `""`, "number": "0", "bool": "null"}})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, `volume_type = optional(string, "")`)
+	assert.Contains(t, content, "volume_size = optional(number, 0)")
+	assert.Contains(t, content, "encrypted = optional(bool)\n")
+	assert.Contains(t, content, "throughput = optional(object({\n        mbps = number\n      }))")
+	assert.Contains(t, content, "snapshot = optional(object({")
+	assert.Contains(t, content, `id = optional(string, "")`)
+	assert.Contains(t, content, "name = string")
+}
+
+// TestCreateVariablesTFInconsistentItems tests that a block with MinItems greater than MaxItems is
+// rendered as an optional list with a warning.
+func TestCreateVariablesTFInconsistentItems(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+	malformedBlock := &tfjson.SchemaBlockType{
+		NestingMode: tfjson.SchemaNestingModeSingle,
+		MinItems:    2,
+		MaxItems:    1,
+		Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+			"size": {AttributeType: cty.Number, Optional: true},
+		}},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{"root_block_device": malformedBlock},
+				}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"name": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{"ipam": malformedBlock},
+				}},
+			},
+		},
+	}
+
+	logger := &MockLogger{}
+	tf := NewTfWithOptions(logger, Options{})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Regexp(t, `variable "root_block_device" \{\n  type = list\(object\(\{\n    size = optional\(number\)\n  \}\)\)\n  default = null\n\}`, content)
+	assert.Contains(t, content, "ipam = optional(list(object({")
+	assert.Contains(t, logger.Messages, "[warn] Nested block aws_instance.root_block_device has min_items 2 greater than max_items 1; treating it as an optional list")
+	assert.Contains(t, logger.Messages, "[warn] Nested block aws_vpc.ipam has min_items 2 greater than max_items 1; treating it as an optional list")
+}
+
+// TestCreateVariablesTFEmptyBlockDefaults tests that optional repeated blocks default to an empty
+// collection with EmptyCollectionDefaults while optional single blocks stay null.
+func TestCreateVariablesTFEmptyBlockDefaults(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	blockAttributes := map[string]*tfjson.SchemaAttribute{
+		"port": {AttributeType: cty.Number, Required: true},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{NestedBlocks: map[string]*tfjson.SchemaBlockType{
+					"ingress": {NestingMode: tfjson.SchemaNestingModeSet, Block: &tfjson.SchemaBlock{Attributes: blockAttributes}},
+					"timeouts": {NestingMode: tfjson.SchemaNestingModeSingle, MaxItems: 1, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+						"create": {AttributeType: cty.String, Optional: true},
+					}}},
+				}}},
+				"aws_security_group": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"name": {AttributeType: cty.String, Required: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"egress":  {NestingMode: tfjson.SchemaNestingModeSet, Block: &tfjson.SchemaBlock{Attributes: blockAttributes}},
+						"ingress": {NestingMode: tfjson.SchemaNestingModeList, MinItems: 1, Block: &tfjson.SchemaBlock{Attributes: blockAttributes}},
+						"timeouts": {NestingMode: tfjson.SchemaNestingModeSingle, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+							"create": {AttributeType: cty.String, Optional: true},
+						}}},
+					},
+				}},
+			},
+		},
+	}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_security_group", Mode: "multiple", Provider: aws},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{EmptyCollectionDefaults: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Regexp(t, `variable "ingress" \{\n  type = list\(object\(\{\n    port = number\n  \}\)\)\n  default = \[\]\n\}`, content)
+	assert.Regexp(t, `variable "timeouts" \{\n  type = object\(\{\n    create = optional\(string\)\n  \}\)\n  default = null\n\}`, content)
+	assert.Contains(t, content, "egress = optional(set(object({\n      port = number\n    })), [])")
+	assert.Contains(t, content, "ingress = list(object({\n      port = number\n    }))\n")
+	assert.Contains(t, content, "timeouts = optional(object({\n      create = optional(string)\n    }))\n")
+
+	// The variable type must stay valid HCL
+	_, diags := hclsyntax.ParseConfig([]byte(content), "variables.tf", hcl.InitialPos)
+	assert.False(t, diags.HasErrors(), diags.Error())
+}
+
+// TestCreateVariablesTFDescriptions tests that custom descriptions override or supply variable
+// descriptions in single mode and description comments in multiple mode.
+func TestCreateVariablesTFDescriptions(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":           {AttributeType: cty.String, Required: true, Description: "AMI to use"},
+					"instance_type": {AttributeType: cty.String, Optional: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"cidr_block": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	logger := &MockLogger{}
+	tf := NewTfWithOptions(logger, Options{Descriptions: map[string]string{
+		"aws_instance.ami":           "Image ID approved by the platform team",
+		"aws_instance.instance_type": "Instance size",
+		"aws_vpc.cidr_block":         "Primary CIDR range",
+		"aws_instance.unknown":       "Ignored",
+	}})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, true))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, `description = "Image ID approved by the platform team"`)
+	assert.NotContains(t, content, "AMI to use")
+	assert.Contains(t, content, `description = "Instance size"`)
+	assert.Contains(t, content, "// Primary CIDR range")
+	assert.Contains(t, logger.Messages, "[warn] Description for aws_instance.unknown does not match any attribute and is ignored")
+}
+
+// TestCreateVariablesTFBlockDescComments tests that nested block descriptions become comments
+// with BlockDescComments while attribute descriptions stay out without the description comments flag.
+func TestCreateVariablesTFBlockDescComments(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "multiple", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{NestedBlocks: map[string]*tfjson.SchemaBlockType{
+					"root_block_device": {NestingMode: tfjson.SchemaNestingModeList, Block: &tfjson.SchemaBlock{
+						Description: "Root volume settings",
+						Attributes: map[string]*tfjson.SchemaAttribute{
+							"volume_size": {AttributeType: cty.Number, Optional: true, Description: "Size in GiB"},
+						},
+					}},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{BlockDescComments: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "// Root volume settings")
+	assert.NotContains(t, content, "Size in GiB")
+}
+
+// TestCreateVariablesTFUnrepresentableType tests that an attribute whose type cannot be fully
+// represented is typed any as a whole, with a warning, instead of a partial type.
+func TestCreateVariablesTFUnrepresentableType(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "single", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"settings": {AttributeType: cty.Object(map[string]cty.Type{
+						"name":   cty.String,
+						"nested": cty.List(cty.Object(map[string]cty.Type{"handle": cty.Capsule("handle", reflect.TypeOf(0))})),
+					}), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	logger := &MockLogger{}
+	tf := NewTfWithOptions(logger, Options{})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Regexp(t, `variable "settings" \{\n  type    = any\n  default = null\n\}`, content)
+	assert.Contains(t, logger.Messages, "[warn] Type of aws_instance.settings cannot be fully represented as a type constraint; using any")
+}
+
+// TestCreateVariablesTFNonNullable tests that NonNullable marks multiple-mode variables, which then
+// default to an empty list, and required single-mode variables as non-nullable.
+func TestCreateVariablesTFNonNullable(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":           {AttributeType: cty.String, Required: true},
+					"instance_type": {AttributeType: cty.String, Optional: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"cidr_block": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{NonNullable: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "variable \"vpcs\" {\n  type = list(object({\n    cidr_block = string\n  }))\n  default  = []\n  nullable = false\n}")
+	assert.Contains(t, content, "variable \"ami\" {\n  type     = string\n  nullable = false\n}")
+	assert.Contains(t, content, "variable \"instance_type\" {\n  type    = string\n  default = null\n}")
+}
+
+// TestCreateVariablesTFRequiredMultiple tests that multiple-mode variables have no default with RequiredMultiple,
+// while their object fields stay optional() and single-mode variables are unaffected.
+func TestCreateVariablesTFRequiredMultiple(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"instance_type": {AttributeType: cty.String, Optional: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"cidr_block": {AttributeType: cty.String, Required: true},
+					"tags":       {AttributeType: cty.Map(cty.String), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{RequiredMultiple: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, content, "variable \"vpcs\" {\n  type = list(object({\n    cidr_block = string\n    tags       = optional(map(string))\n  }))\n}")
+	assert.Contains(t, content, "variable \"instance_type\" {\n  type    = string\n  default = null\n}")
+}
+
+// TestCreateVariablesTFNoTopLevelOptional tests that optional() only appears on object attributes, never
+// at the top level of a variable's type, for single-mode optional blocks with max_items 1 in particular.
+func TestCreateVariablesTFNoTopLevelOptional(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	blockSchema := &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"size": {AttributeType: cty.Number, Optional: true},
+			"type": {AttributeType: cty.String, Required: true},
+		},
+		NestedBlocks: map[string]*tfjson.SchemaBlockType{
+			"encryption": {NestingMode: tfjson.SchemaNestingModeSingle, MaxItems: 1, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+				"kms_key_id": {AttributeType: cty.String, Optional: true},
+			}}},
+		},
+	}
+	resourceSchema := &tfjson.Schema{Block: &tfjson.SchemaBlock{
+		Attributes: map[string]*tfjson.SchemaAttribute{
+			"ami":  {AttributeType: cty.String, Required: true},
+			"tags": {AttributeType: cty.Map(cty.String), Optional: true},
+		},
+		NestedBlocks: map[string]*tfjson.SchemaBlockType{
+			"root_block_device": {NestingMode: tfjson.SchemaNestingModeList, MaxItems: 1, Block: blockSchema},
+			"ebs_block_device":  {NestingMode: tfjson.SchemaNestingModeSet, Block: blockSchema},
+		},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{"aws_instance": resourceSchema, "aws_launch_template": resourceSchema},
+		},
+	}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_launch_template", Mode: "multiple", Provider: aws},
+	}
+
+	for _, style := range []string{tmcgParsing.SingleRefBare, tmcgParsing.SingleRefObject} {
+		t.Run(style, func(t *testing.T) {
+			tf := NewTfWithOptions(&MockLogger{}, Options{SingleRefStyle: style})
+			dir := t.TempDir()
+			require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+			content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+
+			if style == tmcgParsing.SingleRefBare {
+				assert.Regexp(t, `variable "root_block_device" \{\n  type = object\(\{\n\n    encryption = optional\(object\(\{\n      kms_key_id = optional\(string\)\n    \}\)\)\n\n    size = optional\(number\)\n    type = string\n  \}\)\n  default = null\n\}`, content)
+			}
+
+			// Every type must be a valid type constraint, which rules out a top-level optional()
+			file, diags := hclsyntax.ParseConfig([]byte(content), "variables.tf", hcl.InitialPos)
+			require.False(t, diags.HasErrors(), diags.Error())
+			for _, block := range file.Body.(*hclsyntax.Body).Blocks {
+				typeAttr, exists := block.Body.Attributes["type"]
+				require.True(t, exists, "variable %s has no type", block.Labels[0])
+				_, _, diags := typeexpr.TypeConstraintWithDefaults(typeAttr.Expr)
+				assert.False(t, diags.HasErrors(), "variable %s: %s", block.Labels[0], diags.Error())
+			}
+		})
+	}
+}
+
+// TestCreateVariablesTFWriteOnly tests that single-mode variables of attributes the schema marks write-only
+// are ephemeral, while other attributes are not.
+func TestCreateVariablesTFWriteOnly(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_db_instance", Mode: "single", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_db_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"password_wo": {AttributeType: cty.String, Optional: true, Sensitive: true, WriteOnly: true},
+					"username":    {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	dir := t.TempDir()
+	require.NoError(t, NewTf(&MockLogger{}).CreateVariablesTF(dir, cleanedSchema, resources, false))
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Regexp(t, `variable "password_wo" \{\n  type      = string\n  default   = null\n  ephemeral = true\n\}`, content)
+	assert.Equal(t, 1, strings.Count(content, "ephemeral"))
+}
+
+// TestCreateVariablesTFForEachKeyComment tests the note above multiple-mode list variables on how their
+// objects are keyed, for each key mode, and that map variables go without one.
+func TestCreateVariablesTFForEachKeyComment(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "multiple", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"name": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		opts     Options
+		expected string
+	}{
+		{Options{}, "# The 'name' field of each object is used as the for_each key and must be unique\nvariable \"instances\" {"},
+		{Options{KeyMode: tmcgParsing.KeyModeIndex}, "# Each object is keyed by its position in the list; reordering the list replaces resources\nvariable \"instances\" {"},
+		{Options{KeyMode: tmcgParsing.KeyModeCoalesce}, "# The 'name' field of each object, or its position in the list when null, is used as the for_each key and must be unique\nvariable \"instances\" {"},
+		{Options{KeyVar: true}, ""},
+	} {
+		dir := t.TempDir()
+		require.NoError(t, NewTfWithOptions(&MockLogger{}, tc.opts).CreateVariablesTF(dir, cleanedSchema, resources, false))
+		content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+		if tc.expected == "" {
+			assert.NotContains(t, content, "#", "key mode %q", tc.opts.KeyMode)
+			continue
+		}
+		assert.True(t, strings.HasPrefix(content, tc.expected), "key mode %q:\n%s", tc.opts.KeyMode, content)
+	}
+}
+
+// TestCreateVariablesTFOptionalBlockDefault tests that optional single-mode nested block variables default
+// to null, or go without a default when configured so, while optional attributes keep theirs.
+func TestCreateVariablesTFOptionalBlockDefault(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "single", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{
+					Attributes: map[string]*tfjson.SchemaAttribute{
+						"instance_type": {AttributeType: cty.String, Optional: true},
+					},
+					NestedBlocks: map[string]*tfjson.SchemaBlockType{
+						"root_block_device": {NestingMode: tfjson.SchemaNestingModeList, MaxItems: 1, Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+							"volume_size": {AttributeType: cty.Number, Optional: true},
+						}}},
+					},
+				}},
+			},
+		},
+	}
+
+	for _, tc := range []struct {
+		blockDefault string
+		expected     string
+	}{
+		{"", "variable \"root_block_device\" {\n  type = object({\n    volume_size = optional(number)\n  })\n  default = null\n}"},
+		{tmcgParsing.OptionalBlockDefaultNull, "variable \"root_block_device\" {\n  type = object({\n    volume_size = optional(number)\n  })\n  default = null\n}"},
+		{tmcgParsing.OptionalBlockDefaultNone, "variable \"root_block_device\" {\n  type = object({\n    volume_size = optional(number)\n  })\n}"},
+	} {
+		tf := NewTfWithOptions(&MockLogger{}, Options{OptionalBlockDefault: tc.blockDefault})
+		dir := t.TempDir()
+		require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+		content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+		assert.Contains(t, content, tc.expected, tc.blockDefault)
+		assert.Contains(t, content, "variable \"instance_type\" {\n  type    = string\n  default = null\n}", tc.blockDefault)
+	}
+}
+
+// TestDeriveVariableNameSimplePlural tests that the simple plural rule names variables like the pluralize
+// client for regular words, and differs only where English has irregular plurals.
+func TestDeriveVariableNameSimplePlural(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	google := tmcgParsing.Provider{Namespace: "hashicorp", Name: "google", NamespaceLower: "hashicorp", NameLower: "google"}
+	pluralizeTf := NewTfWithOptions(&MockLogger{}, Options{})
+	simpleTf := NewTfWithOptions(&MockLogger{}, Options{SimplePlural: true})
+
+	for _, tc := range []struct {
+		resource  tmcgParsing.Resource
+		pluralize string
+		simple    string
+	}{
+		{tmcgParsing.Resource{Name: "aws_security_group", Provider: aws}, "security_groups", "security_groups"},
+		{tmcgParsing.Resource{Name: "aws_iam_policy", Provider: aws}, "iam_policies", "iam_policies"},
+		{tmcgParsing.Resource{Name: "google_compute_address", Provider: google}, "compute_addresses", "compute_addresses"},
+		{tmcgParsing.Resource{Name: "aws_cloudsearch_domain_index", Provider: aws}, "cloudsearch_domain_indices", "cloudsearch_domain_indexes"},
+		{tmcgParsing.Resource{Name: "aws_s3_bucket_metadata", Provider: aws}, "s3_bucket_metadata", "s3_bucket_metadatas"},
+		{tmcgParsing.Resource{Name: "aws_eip_association_key", Provider: aws}, "eip_association_keys", "eip_association_keys"},
+	} {
+		assert.Equal(t, tc.pluralize, pluralizeTf.deriveVariableName(tc.resource), tc.resource.Name)
+		assert.Equal(t, tc.simple, simpleTf.deriveVariableName(tc.resource), tc.resource.Name)
+	}
+}
+
+// TestResourceAliases tests that aliased resources name their variables, and the comments describing them,
+// after the alias while keeping their labels, in main.tf as in variables.tf.
+func TestResourceAliases(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Label: "web", Provider: aws},
+		{Name: "aws_vpc", Mode: "multiple", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"cidr_block": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{
+		SingleRefStyle:  tmcgParsing.SingleRefPrefixed,
+		Toggleable:      map[string]bool{"aws_instance": true},
+		ResourceAliases: map[string]string{"aws_instance": "frontend", "aws_vpc": "networks"},
+	})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "resource \"aws_instance\" \"web\" {\n  count = var.frontend_enabled ? 1 : 0\n  ami   = var.frontend_ami\n}")
+	assert.Contains(t, mainContent, "resource \"aws_vpc\" \"this\" {\n  for_each   = { for i in coalesce(var.networks, []) : i.name => i }")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, "variable \"frontend_enabled\" {\n  description = \"Whether to create the frontend (aws_instance) resource\"")
+	assert.Contains(t, variablesContent, "variable \"frontend_ami\" {")
+	assert.Contains(t, variablesContent, "# networks (aws_vpc): The 'name' field of each object is used as the for_each key and must be unique\nvariable \"networks\" {")
+	assert.NotContains(t, variablesContent, "aws_instance_web")
+	assert.NotContains(t, variablesContent, "vpcs")
+}
+
+// TestGlobalVarSort tests that the variables of several resources are sorted by required first, then name,
+// with the comments above them moving along.
+func TestGlobalVarSort(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{
+		{Name: "aws_instance", Mode: "single", Provider: aws},
+		{Name: "aws_eip", Mode: "multiple", Provider: aws},
+		{Name: "aws_vpc", Mode: "single", Provider: aws},
+	}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami":        {AttributeType: cty.String, Required: true},
+					"monitoring": {AttributeType: cty.Bool, Optional: true},
+				}}},
+				"aws_eip": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"domain": {AttributeType: cty.String, Optional: true},
+				}}},
+				"aws_vpc": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"cidr_block": {AttributeType: cty.String, Required: true},
+					"tags":       {AttributeType: cty.Map(cty.String), Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{SingleRefStyle: tmcgParsing.SingleRefPrefixed, GlobalVarSort: true})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+	content := readFormatted(t, filepath.Join(dir, "variables.tf"))
+
+	order := []string{
+		`variable "aws_instance_ami"`,
+		`variable "aws_vpc_cidr_block"`,
+		`variable "aws_instance_monitoring"`,
+		`variable "aws_vpc_tags"`,
+		"# The 'name' field of each object is used as the for_each key and must be unique\nvariable \"eips\"",
+	}
+	previous := -1
+	for _, block := range order {
+		index := strings.Index(content, block)
+		require.NotEqual(t, -1, index, block)
+		assert.Greater(t, index, previous, block)
+		previous = index
+	}
+	assert.Equal(t, 5, strings.Count(content, "variable \""))
+}
+
+// TestAttributeCase tests that snake case normalizes the variable names derived from mixed-case attribute
+// names, while the resource keeps the schema's attribute names.
+func TestAttributeCase(t *testing.T) {
+	resources := []tmcgParsing.Resource{{
+		Name:     "example_server",
+		Mode:     "single",
+		Provider: tmcgParsing.Provider{Namespace: "example", Name: "example", NamespaceLower: "example", NameLower: "example"},
+	}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/example/example": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"example_server": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"HTTPEndpoint": {AttributeType: cty.String, Required: true},
+					"maxConns":     {AttributeType: cty.Number, Optional: true},
+					"name":         {AttributeType: cty.String, Optional: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{AttributeCase: tmcgParsing.AttributeCaseSnake})
+	dir := t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent := readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "HTTPEndpoint = var.http_endpoint\n")
+	assert.Contains(t, mainContent, "maxConns     = var.max_conns\n")
+	assert.Contains(t, mainContent, "name         = var.name\n")
+
+	variablesContent := readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Contains(t, variablesContent, `variable "http_endpoint"`)
+	assert.Contains(t, variablesContent, `variable "max_conns"`)
+	assert.NotContains(t, variablesContent, "HTTPEndpoint")
+
+	// Preserving keeps the schema's spelling
+	dir = t.TempDir()
+	require.NoError(t, testTerraform.CreateMainTF(dir, cleanedSchema, resources))
+	assert.Contains(t, readFormatted(t, filepath.Join(dir, "main.tf")), "HTTPEndpoint = var.HTTPEndpoint\n")
+
+	// Items whose snake case collides keep the schema's spelling, so each declares its own variable
+	cleanedSchema["registry.terraform.io/example/example"].ResourceSchemas["example_server"].Block.Attributes["max_conns"] = &tfjson.SchemaAttribute{AttributeType: cty.Number, Optional: true}
+	mockLogger := &MockLogger{}
+	tf = NewTfWithOptions(mockLogger, Options{AttributeCase: tmcgParsing.AttributeCaseSnake})
+	dir = t.TempDir()
+	require.NoError(t, tf.CreateMainTF(dir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(dir, cleanedSchema, resources, false))
+
+	mainContent = readFormatted(t, filepath.Join(dir, "main.tf"))
+	assert.Contains(t, mainContent, "maxConns     = var.maxConns\n")
+	assert.Contains(t, mainContent, "max_conns    = var.max_conns\n")
+	assert.Contains(t, mainContent, "HTTPEndpoint = var.http_endpoint\n")
+
+	variablesContent = readFormatted(t, filepath.Join(dir, "variables.tf"))
+	assert.Equal(t, 1, strings.Count(variablesContent, `variable "max_conns"`))
+	assert.Contains(t, variablesContent, `variable "maxConns"`)
+	assert.Contains(t, mockLogger.Messages, "[warn] Keeping the variable name of example_server.maxConns as in the schema, as its snake case collides with another attribute or block")
+
+	for name, expected := range map[string]string{"HTTPEndpoint": "http_endpoint", "maxConns": "max_conns", "ipv4Address": "ipv4_address", "Tags-Map": "tags_map", "already_snake": "already_snake"} {
+		assert.Equal(t, expected, snakeCase(name), name)
+	}
+}
@@ -7,7 +7,10 @@ import (
 
 	tmcgParsing "tmcg/internal/tmcg/parsing"
 
+	tfjson "github.com/hashicorp/terraform-json"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/zclconf/go-cty/cty"
 )
 
 // TestCreateVersionsTF tests the CreateVersionsTF function for generating versions.tf.
@@ -43,3 +46,295 @@ func TestCreateVersionsTF(t *testing.T) {
 		assert.Contains(t, string(content), part, "Generated versions.tf is missing expected content")
 	}
 }
+
+// TestCreateVersionsTFExclusions tests that a constraint with disjoint ranges and an exclusion is written verbatim.
+func TestCreateVersionsTFExclusions(t *testing.T) {
+	providers := map[string]tmcgParsing.Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 3.0, < 4.0, != 3.5.0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+
+	workingDir := t.TempDir()
+	require.NoError(t, testTerraform.CreateVersionsTF(workingDir, providers))
+	assert.Contains(t, readFormatted(t, filepath.Join(workingDir, "versions.tf")), `version = ">= 3.0, < 4.0, != 3.5.0"`)
+}
+
+// TestCreateVersionsTFSharedName tests that providers sharing a name in different namespaces are declared
+// under their distinct local names.
+func TestCreateVersionsTFSharedName(t *testing.T) {
+	providers := map[string]tmcgParsing.Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws", LocalName: "hashicorp-aws"},
+		"someorg/aws":   {Namespace: "someorg", Name: "aws", NamespaceLower: "someorg", NameLower: "aws", LocalName: "someorg-aws", ConfigurationAliases: []string{"west"}},
+	}
+
+	workingDir := t.TempDir()
+	require.NoError(t, testTerraform.CreateVersionsTF(workingDir, providers))
+	assert.Equal(t, `terraform {
+  required_providers {
+    hashicorp-aws = {
+      source = "hashicorp/aws"
+    }
+    someorg-aws = {
+      source                = "someorg/aws"
+      configuration_aliases = [someorg-aws.west]
+    }
+  }
+}
+`, readFormatted(t, filepath.Join(workingDir, "versions.tf")))
+
+	require.NoError(t, testTerraform.CreateProviderTF(workingDir, providers))
+	assert.Equal(t, "provider \"someorg-aws\" {\n  alias = \"west\"\n}\n", readFormatted(t, filepath.Join(workingDir, "providers.tf")))
+}
+
+// TestCreateVersionsTFBackend tests the backend and cloud block skeletons inside the terraform block.
+func TestCreateVersionsTFBackend(t *testing.T) {
+	providers := map[string]tmcgParsing.Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 3.0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+
+	workingDir := t.TempDir()
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendS3}).CreateVersionsTF(workingDir, providers))
+	content := readFormatted(t, filepath.Join(workingDir, "versions.tf"))
+	assert.Contains(t, content, `  }
+
+  backend "s3" {
+    bucket = "REPLACE_WITH_STATE_BUCKET"
+    key    = "REPLACE_WITH_STATE_KEY/terraform.tfstate"
+    region = "REPLACE_WITH_REGION"
+  }
+}
+`)
+
+	workingDir = t.TempDir()
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendCloud}).CreateVersionsTF(workingDir, providers))
+	content = readFormatted(t, filepath.Join(workingDir, "versions.tf"))
+	assert.Contains(t, content, `  cloud {
+    organization = "REPLACE_WITH_ORGANIZATION"
+
+    workspaces {
+      name = "REPLACE_WITH_WORKSPACE"
+    }
+  }
+}
+`)
+	assert.NotContains(t, content, "backend")
+}
+
+// TestCreateVersionsTFBackendFile tests the backend block in versions.tf and in a file of its own, with
+// configured attributes replacing placeholders and added after them.
+func TestCreateVersionsTFBackendFile(t *testing.T) {
+	providers := map[string]tmcgParsing.Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 3.0", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+	config := map[string]string{"bucket": `"my-state"`, "encrypt": "true"}
+
+	// In versions.tf
+	workingDir := t.TempDir()
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendS3, BackendConfig: config}).CreateVersionsTF(workingDir, providers))
+	content := readFormatted(t, filepath.Join(workingDir, "versions.tf"))
+	assert.Contains(t, content, `  backend "s3" {
+    bucket  = "my-state"
+    key     = "REPLACE_WITH_STATE_KEY/terraform.tfstate"
+    region  = "REPLACE_WITH_REGION"
+    encrypt = true
+  }
+}
+`)
+	assert.NoFileExists(t, filepath.Join(workingDir, "backend.tf"))
+
+	// In a file of its own
+	workingDir = t.TempDir()
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendS3, BackendFile: "backend.tf", BackendConfig: config}).CreateVersionsTF(workingDir, providers))
+	assert.NotContains(t, readFormatted(t, filepath.Join(workingDir, "versions.tf")), "backend")
+	assert.Equal(t, `terraform {
+  backend "s3" {
+    bucket  = "my-state"
+    key     = "REPLACE_WITH_STATE_KEY/terraform.tfstate"
+    region  = "REPLACE_WITH_REGION"
+    encrypt = true
+  }
+}
+`, readFormatted(t, filepath.Join(workingDir, "backend.tf")))
+
+	// An object replaces the content of a nested block
+	workingDir = t.TempDir()
+	cloudConfig := map[string]string{"organization": `"acme"`, "workspaces": `{ tags = ["app", "prod"], project = "web" }`}
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendCloud, BackendFile: "backend.tf", BackendConfig: cloudConfig}).CreateVersionsTF(workingDir, providers))
+	assert.Equal(t, `terraform {
+  cloud {
+    organization = "acme"
+
+    workspaces {
+      tags    = ["app", "prod"]
+      project = "web"
+    }
+  }
+}
+`, readFormatted(t, filepath.Join(workingDir, "backend.tf")))
+
+	// A nested block needs an object
+	err := NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendCloud, BackendConfig: map[string]string{"workspaces": `"prod"`}}).CreateVersionsTF(t.TempDir(), providers)
+	assert.ErrorContains(t, err, "backend config workspaces sets a block and needs an object")
+
+	// Without config, the skeleton keeps its placeholders
+	workingDir = t.TempDir()
+	require.NoError(t, NewTfWithOptions(&MockLogger{}, Options{Backend: tmcgParsing.BackendCloud, BackendFile: "backend.tf"}).CreateVersionsTF(workingDir, providers))
+	assert.Contains(t, readFormatted(t, filepath.Join(workingDir, "backend.tf")), `organization = "REPLACE_WITH_ORGANIZATION"`)
+}
+
+// TestProviderSource tests that a provider published under a different source is written to versions.tf
+// by that source, while its resources are matched and looked up by the local provider name.
+func TestProviderSource(t *testing.T) {
+	fork := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", Version: ">= 1.0", NamespaceLower: "hashicorp", NameLower: "aws", Source: "myorg/aws-fork"}
+	providers := map[string]tmcgParsing.Provider{"hashicorp/aws": fork}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "single", Provider: fork}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/myorg/aws-fork": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	workingDir := t.TempDir()
+	require.NoError(t, testTerraform.CreateVersionsTF(workingDir, providers))
+	require.NoError(t, testTerraform.CreateMainTF(workingDir, cleanedSchema, resources))
+
+	versionsContent := readFormatted(t, filepath.Join(workingDir, "versions.tf"))
+	assert.Contains(t, versionsContent, "    aws = {\n      source  = \"myorg/aws-fork\"\n")
+	assert.NotContains(t, versionsContent, "hashicorp/aws")
+
+	mainContent := readFormatted(t, filepath.Join(workingDir, "main.tf"))
+	assert.Contains(t, mainContent, "ami = var.ami")
+	assert.NotContains(t, mainContent, "provider")
+}
+
+// TestRegistryHost tests that a custom registry host is used for the schema lookups of main.tf and
+// variables.tf and written into the versions.tf sources, while the public registry keeps short sources.
+func TestRegistryHost(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", Version: ">= 3.0", NamespaceLower: "hashicorp", NameLower: "aws"}
+	providers := map[string]tmcgParsing.Provider{"hashicorp/aws": aws}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "multiple", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"terraform.example.com/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{RegistryHost: "terraform.example.com"})
+	workingDir := t.TempDir()
+	require.NoError(t, tf.CreateVersionsTF(workingDir, providers))
+	require.NoError(t, tf.CreateMainTF(workingDir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(workingDir, cleanedSchema, resources, false))
+	assert.Empty(t, tf.Skipped())
+
+	assert.Contains(t, readFormatted(t, filepath.Join(workingDir, "versions.tf")), `source  = "terraform.example.com/hashicorp/aws"`)
+	assert.Contains(t, readFormatted(t, filepath.Join(workingDir, "main.tf")), "each.value.ami")
+	assert.Contains(t, readFormatted(t, filepath.Join(workingDir, "variables.tf")), "ami = string")
+
+	// The public registry's schema key does not match a custom host
+	tf = NewTfWithOptions(&MockLogger{}, Options{})
+	workingDir = t.TempDir()
+	require.NoError(t, tf.CreateVersionsTF(workingDir, providers))
+	require.NoError(t, tf.CreateMainTF(workingDir, cleanedSchema, resources))
+	assert.Contains(t, readFormatted(t, filepath.Join(workingDir, "versions.tf")), `source  = "hashicorp/aws"`)
+	assert.Contains(t, tf.Skipped(), "No schema found for provider: registry.terraform.io/hashicorp/aws")
+}
+
+// TestFileMode tests that main.tf, variables.tf and versions.tf are written with the configured mode,
+// including when they already exist with another one.
+func TestFileMode(t *testing.T) {
+	aws := tmcgParsing.Provider{Namespace: "hashicorp", Name: "aws", Version: ">= 3.0", NamespaceLower: "hashicorp", NameLower: "aws"}
+	resources := []tmcgParsing.Resource{{Name: "aws_instance", Mode: "multiple", Provider: aws}}
+	cleanedSchema := map[string]*tfjson.ProviderSchema{
+		"registry.terraform.io/hashicorp/aws": {
+			ResourceSchemas: map[string]*tfjson.Schema{
+				"aws_instance": {Block: &tfjson.SchemaBlock{Attributes: map[string]*tfjson.SchemaAttribute{
+					"ami": {AttributeType: cty.String, Required: true},
+				}}},
+			},
+		},
+	}
+
+	workingDir := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(workingDir, "main.tf"), nil, 0600))
+
+	tf := NewTfWithOptions(&MockLogger{}, Options{FileMode: 0640})
+	require.NoError(t, tf.CreateVersionsTF(workingDir, map[string]tmcgParsing.Provider{"hashicorp/aws": aws}))
+	require.NoError(t, tf.CreateMainTF(workingDir, cleanedSchema, resources))
+	require.NoError(t, tf.CreateVariablesTF(workingDir, cleanedSchema, resources, false))
+
+	for _, name := range []string{"main.tf", "variables.tf", "versions.tf"} {
+		info, err := os.Stat(filepath.Join(workingDir, name))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(0640), info.Mode().Perm(), name)
+	}
+}
+
+// TestCreateVersionsTFNoProviders tests that no versions.tf is written without providers.
+func TestCreateVersionsTFNoProviders(t *testing.T) {
+	mockLogger := &MockLogger{}
+	workingDir := t.TempDir()
+
+	err := NewTf(mockLogger).CreateVersionsTF(workingDir, map[string]tmcgParsing.Provider{})
+	assert.NoError(t, err)
+	assert.NoFileExists(t, filepath.Join(workingDir, "versions.tf"))
+	assert.Contains(t, mockLogger.Messages, "[warn] No providers specified. Skipping versions.tf generation.")
+}
+
+// TestCreateCLIConfig tests the dev_overrides CLI configuration and the unpinned versions.tf entry.
+func TestCreateCLIConfig(t *testing.T) {
+	workingDir := t.TempDir()
+	tf := NewTf(&MockLogger{})
+
+	configPath := filepath.Join(workingDir, "terraformrc")
+	require.NoError(t, tf.CreateCLIConfig(configPath, map[string]string{"hashicorp/aws": "/plugins/aws"}))
+	content, err := os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Equal(t, "provider_installation {\n  dev_overrides {\n    \"hashicorp/aws\" = \"/plugins/aws\"\n  }\n\n  direct {}\n}\n", string(content))
+
+	providers := map[string]tmcgParsing.Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", NamespaceLower: "hashicorp", NameLower: "aws"},
+	}
+	require.NoError(t, tf.CreateVersionsTF(workingDir, providers))
+	versions := readFormatted(t, filepath.Join(workingDir, "versions.tf"))
+	assert.Contains(t, versions, `source = "hashicorp/aws"`)
+	assert.NotContains(t, versions, "version")
+
+	// Sources on another registry host are qualified with it
+	tf = NewTfWithOptions(&MockLogger{}, Options{RegistryHost: "terraform.example.com"})
+	require.NoError(t, tf.CreateCLIConfig(configPath, map[string]string{"myorg/aws-fork": "/plugins/aws"}))
+	content, err = os.ReadFile(configPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(content), `"terraform.example.com/myorg/aws-fork" = "/plugins/aws"`)
+}
+
+// TestCreateProviderTF tests that each configuration alias produces a provider block.
+func TestCreateProviderTF(t *testing.T) {
+	providers := map[string]tmcgParsing.Provider{
+		"hashicorp/aws": {Namespace: "hashicorp", Name: "aws", Version: ">= 3.0", NamespaceLower: "hashicorp", NameLower: "aws", ConfigurationAliases: []string{"west", "east"}},
+	}
+
+	workingDir := t.TempDir()
+	assert.NoError(t, testTerraform.CreateVersionsTF(workingDir, providers))
+	assert.NoError(t, testTerraform.CreateProviderTF(workingDir, providers))
+
+	versions, err := os.ReadFile(filepath.Join(workingDir, "versions.tf"))
+	assert.NoError(t, err)
+	assert.Contains(t, string(versions), "configuration_aliases = [aws.west, aws.east]")
+
+	content, err := os.ReadFile(filepath.Join(workingDir, "providers.tf"))
+	assert.NoError(t, err)
+	assert.Equal(t, "provider \"aws\" {\n  alias = \"west\"\n}\n\nprovider \"aws\" {\n  alias = \"east\"\n}\n", string(content))
+
+	// Providers without aliases produce no providers.tf
+	emptyDir := t.TempDir()
+	assert.NoError(t, testTerraform.CreateProviderTF(emptyDir, map[string]tmcgParsing.Provider{"hashicorp/aws": {NameLower: "aws"}}))
+	_, err = os.Stat(filepath.Join(emptyDir, "providers.tf"))
+	assert.True(t, os.IsNotExist(err))
+}